@@ -49,24 +49,34 @@ func MakePeerIdentity(home string) (empty PeerIdentity, err error) {
 
 func generatePeerIdentity(kpath string) (empty PeerIdentity, err error) {
 	log.Printf("Generating new node identity")
-	// RSA keys for interop with js
-	privk, pubk, err := GenerateRSAKeyPair()
+	id, err := NewPeerIdentity()
 	if err != nil {
 		return
 	}
 
-	id, err := p2p_peer.IDFromPublicKey(pubk)
+	log.Printf("Saving key to %s", kpath)
+	err = saveKey(id.PrivKey, kpath)
 	if err != nil {
 		return
 	}
 
-	log.Printf("Saving key to %s", kpath)
-	err = saveKey(privk, kpath)
+	log.Printf("Peer ID: %s", id.Pretty())
+	return id, nil
+}
+
+// NewPeerIdentity generates an ephemeral peer identity, which is not saved
+func NewPeerIdentity() (empty PeerIdentity, err error) {
+	// RSA keys for interop with js
+	privk, pubk, err := GenerateRSAKeyPair()
+	if err != nil {
+		return
+	}
+
+	id, err := p2p_peer.IDFromPublicKey(pubk)
 	if err != nil {
 		return
 	}
 
-	log.Printf("Peer ID: %s", id.Pretty())
 	return PeerIdentity{ID: id, PrivKey: privk}, nil
 }
 
@@ -101,26 +111,35 @@ func MakePublisherIdentity(home string) (empty PublisherIdentity, err error) {
 
 func generatePublisherIdentity(kpath string) (empty PublisherIdentity, err error) {
 	log.Printf("Generating new publisher identity")
+	id, err := NewPublisherIdentity()
+	if err != nil {
+		return
+	}
 
-	privk, pubk, err := GenerateECCKeyPair()
+	log.Printf("Saving key to %s", kpath)
+	err = saveKey(id.PrivKey, kpath)
 	if err != nil {
 		return
 	}
 
-	id58, err := PublisherID58(pubk)
+	log.Printf("Publisher ID: %s", id.ID58)
+	return id, nil
+}
+
+// NewPublisherIdentity generates an ephemeral publisher identity, which is
+// not saved
+func NewPublisherIdentity() (empty PublisherIdentity, err error) {
+	privk, pubk, err := GenerateECCKeyPair()
 	if err != nil {
 		return
 	}
 
-	log.Printf("Saving key to %s", kpath)
-	err = saveKey(privk, kpath)
+	id58, err := PublisherID58(pubk)
 	if err != nil {
 		return
 	}
 
-	log.Printf("Publisher ID: %s", id58)
 	return PublisherIdentity{id58, privk}, nil
-
 }
 
 func loadPublisherIdentity(kpath string) (empty PublisherIdentity, err error) {
@@ -0,0 +1,82 @@
+package mc
+
+import (
+	"bytes"
+	"errors"
+	ggproto "github.com/gogo/protobuf/proto"
+	p2p_crypto "github.com/libp2p/go-libp2p-crypto"
+	pb "github.com/mediachain/concat/proto"
+	multihash "github.com/multiformats/go-multihash"
+	"time"
+)
+
+var (
+	ManifestExpired = errors.New("Manifest expired")
+	ManifestRevoked = errors.New("Manifest revoked")
+)
+
+// Manifest expiry and revocation
+// Manifests may carry an expiration time, after which they are no longer
+// valid. A signed manifest can be revoked by its entity with a signed
+// revocation, which identifies the manifest by its hash; this allows nodes
+// to be moved between hosts without the old manifests claiming the old peer.
+
+// IsManifestExpired checks whether a manifest has expired by now
+func IsManifestExpired(mf *pb.Manifest, now time.Time) bool {
+	return mf.Expires > 0 && now.Unix() >= mf.Expires
+}
+
+// HashManifest computes the multihash of a signed manifest, as referenced by
+// revocations
+func HashManifest(mf *pb.Manifest) (multihash.Multihash, error) {
+	data, err := ggproto.Marshal(mf)
+	if err != nil {
+		return nil, err
+	}
+
+	return Hash(data), nil
+}
+
+// VerifyManifest checks the signature of a manifest against the entity key
+func VerifyManifest(mf *pb.Manifest, pubk p2p_crypto.PubKey) (bool, error) {
+	sig := mf.Signature
+	mf.Signature = nil
+	data, err := ggproto.Marshal(mf)
+	mf.Signature = sig
+
+	if err != nil {
+		return false, err
+	}
+
+	return pubk.Verify(data, sig)
+}
+
+// VerifyManifestRevocation checks the signature of a revocation against the
+// entity key
+func VerifyManifestRevocation(rev *pb.ManifestRevocation, pubk p2p_crypto.PubKey) (bool, error) {
+	sig := rev.Signature
+	rev.Signature = nil
+	data, err := ggproto.Marshal(rev)
+	rev.Signature = sig
+
+	if err != nil {
+		return false, err
+	}
+
+	return pubk.Verify(data, sig)
+}
+
+// RevokesManifest checks whether a revocation applies to a manifest; the
+// signature of the revocation must be verified separately.
+func RevokesManifest(rev *pb.ManifestRevocation, mf *pb.Manifest) bool {
+	if rev.Entity != mf.Entity {
+		return false
+	}
+
+	mfh, err := HashManifest(mf)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(rev.Manifest, mfh)
+}
@@ -6,6 +6,7 @@ import (
 	p2p_pstore "github.com/libp2p/go-libp2p-peerstore"
 	pb "github.com/mediachain/concat/proto"
 	multiaddr "github.com/multiformats/go-multiaddr"
+	"sort"
 )
 
 const MaxMessageSize = 2 << 20 // 1 MB
@@ -35,6 +36,34 @@ func PBToPeerInfo(pbpi *pb.PeerInfo) (empty p2p_pstore.PeerInfo, err error) {
 	return p2p_pstore.PeerInfo{pid, addrs}, nil
 }
 
+// SortNamespacePeers ranks the peers holding a namespace by completeness:
+// statement count and then last update
+func SortNamespacePeers(peers []*pb.NamespacePeer) {
+	sort.Sort(namespacePeers(peers))
+}
+
+type namespacePeers []*pb.NamespacePeer
+
+func (peers namespacePeers) Len() int {
+	return len(peers)
+}
+
+func (peers namespacePeers) Less(i, j int) bool {
+	a, b := peers[i], peers[j]
+	switch {
+	case a.Statements != b.Statements:
+		return a.Statements > b.Statements
+	case a.LastUpdate != b.LastUpdate:
+		return a.LastUpdate > b.LastUpdate
+	default:
+		return a.Id < b.Id
+	}
+}
+
+func (peers namespacePeers) Swap(i, j int) {
+	peers[i], peers[j] = peers[j], peers[i]
+}
+
 type ValueError string
 
 func (v ValueError) Error() string {
@@ -604,4 +604,5 @@ func collectIndexCriteriaTables(tabs map[string]string, c QueryCriteria) error {
 }
 
 var indexCriteriaTableNames = map[string]string{
-	"wki": "Refs"}
+	"wki":      "Refs",
+	"retracts": "Retracts"}
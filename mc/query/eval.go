@@ -132,6 +132,10 @@ func wkiCriteriaFilter(stmt *pb.Statement) []string {
 	return StatementRefs(stmt).List()
 }
 
+func retractsCriteriaFilter(stmt *pb.Statement) []string {
+	return StatementRetracts(stmt)
+}
+
 func indexCriteriaContains(keys []string, val string) bool {
 	for _, key := range keys {
 		if key == val {
@@ -142,7 +146,8 @@ func indexCriteriaContains(keys []string, val string) bool {
 }
 
 var indexCriteriaFilterSelect = map[string]IndexCriteriaFilterSelect{
-	"wki": wkiCriteriaFilter}
+	"wki":      wkiCriteriaFilter,
+	"retracts": retractsCriteriaFilter}
 
 func compoundCriteriaAND(stmt *pb.Statement, left, right StatementFilter) bool {
 	return left(stmt) && right(stmt)
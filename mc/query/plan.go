@@ -0,0 +1,471 @@
+package query
+
+import (
+	"fmt"
+	pb "github.com/mediachain/concat/proto"
+	"sort"
+	"strings"
+)
+
+// Query plans: compiled evaluation of queries for statement dbs that don't
+// have an sql engine.
+// Unlike EvalQuery, plans operate on statement records carrying the envelope
+// counter and follow the semantics of compiled sql queries: simple
+// namespace, publisher and source selectors are DISTINCT, ORDER BY is
+// supported, and LIMIT applies to the final result.
+
+// StatementRecord is a statement together with its envelope counter
+type StatementRecord struct {
+	Counter int64
+	Stmt    *pb.Statement
+}
+
+type RecordFilter func(*StatementRecord) bool
+type RecordSelector func(*StatementRecord) interface{}
+type RecordCompare func(a, b *StatementRecord) int
+
+type QueryPlan struct {
+	query  *Query
+	filter RecordFilter
+	getf   RecordSelector
+	keyf   RecordSelector // distinct key
+	fun    string
+	order  []RecordCompare
+}
+
+// CompileQueryPlan compiles a query to a plan for evaluation over a stream
+// of statement records.
+func CompileQueryPlan(q *Query) (*QueryPlan, error) {
+	nsfilter := makeNamespaceFilter(q)
+
+	var cfilter RecordFilter
+	if q.criteria == nil {
+		cfilter = emptyRecordFilter
+	} else {
+		var err error
+		cfilter, err = makeRecordFilter(q.criteria)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &QueryPlan{query: q}
+	plan.filter = func(rec *StatementRecord) bool {
+		return nsfilter(rec.Stmt) && cfilter(rec)
+	}
+
+	switch sel := q.selector.(type) {
+	case SimpleSelector:
+		getf, err := makeRecordSelector(sel)
+		if err != nil {
+			return nil, err
+		}
+		plan.getf = getf
+		if recordSelectorDistinct[string(sel)] {
+			plan.keyf = getf
+		}
+
+	case CompoundSelector:
+		sels := make([]SimpleSelector, len(sel))
+		getfs := make([]RecordSelector, len(sel))
+		for x, ssel := range sel {
+			getf, err := makeRecordSelector(ssel)
+			if err != nil {
+				return nil, err
+			}
+			sels[x] = ssel
+			getfs[x] = getf
+		}
+
+		plan.getf = makeCompoundRecordSelector(sels, getfs)
+		if len(sels) == 1 && recordSelectorDistinct[string(sels[0])] {
+			// single element compound selectors compile as simple selectors
+			plan.keyf = getfs[0]
+		}
+
+	case *FunctionSelector:
+		if !checkFunctionSelector(sel) {
+			return nil, QueryCompileError(fmt.Sprintf("Illegal selector: %s(%s)", sel.op, sel.sel))
+		}
+
+		getf, err := makeRecordSelector(sel.sel)
+		if err != nil {
+			return nil, err
+		}
+		plan.getf = getf
+		if recordSelectorDistinct[string(sel.sel)] {
+			plan.keyf = getf
+		}
+		plan.fun = sel.op
+
+	default:
+		return nil, QueryCompileError(fmt.Sprintf("Unexpected selector type: %T", sel))
+	}
+
+	if q.order != nil && plan.fun == "" {
+		plan.order = make([]RecordCompare, len(q.order))
+		for x, spec := range q.order {
+			cmpf, err := makeRecordCompare(spec)
+			if err != nil {
+				return nil, err
+			}
+			plan.order[x] = cmpf
+		}
+	}
+
+	return plan, nil
+}
+
+// ScanPrefix returns a namespace prefix that all matching statements share,
+// for use as an index hint.
+// If exact is true, then the prefix is the complete namespace.
+// An empty prefix with exact false means that all statements must be scanned.
+func (plan *QueryPlan) ScanPrefix() (prefix string, exact bool) {
+	ns := plan.query.namespace
+	switch {
+	case ns == "*":
+		return "", false
+	case ns[len(ns)-1] == '*':
+		return ns[:len(ns)-2], false
+	default:
+		return ns, true
+	}
+}
+
+// Begin starts the evaluation of a plan; results are passed to emit,
+// which can stop the evaluation by returning false.
+func (plan *QueryPlan) Begin(emit func(interface{}) bool) *QueryEvaluator {
+	ev := &QueryEvaluator{plan: plan, emit: emit}
+	if plan.keyf != nil {
+		ev.seen = make(map[interface{}]bool)
+	}
+	return ev
+}
+
+type QueryEvaluator struct {
+	plan  *QueryPlan
+	emit  func(interface{}) bool
+	seen  map[interface{}]bool
+	buf   []*StatementRecord
+	count int
+	min   int64
+	max   int64
+	done  bool
+}
+
+// Add feeds the next statement record to the evaluator.
+// Returns false when the evaluator doesn't need any more records.
+func (ev *QueryEvaluator) Add(rec *StatementRecord) bool {
+	if ev.done {
+		return false
+	}
+
+	if !ev.plan.filter(rec) {
+		return true
+	}
+
+	switch {
+	case ev.plan.fun != "":
+		ev.aggregate(rec)
+
+	case ev.plan.order != nil:
+		ev.buf = append(ev.buf, rec)
+
+	default:
+		ev.output(rec)
+	}
+
+	return !ev.done
+}
+
+// End completes the evaluation, emitting any pending results.
+func (ev *QueryEvaluator) End() {
+	switch {
+	case ev.plan.fun != "":
+		if ev.done {
+			return
+		}
+		ev.done = true
+
+		switch ev.plan.fun {
+		case "COUNT":
+			ev.emit(ev.count)
+		case "MIN":
+			ev.emitAggregate(ev.min)
+		case "MAX":
+			ev.emitAggregate(ev.max)
+		}
+
+	case ev.plan.order != nil:
+		sort.Stable(&recordSort{ev.buf, ev.plan.order})
+		for _, rec := range ev.buf {
+			ev.output(rec)
+			if ev.done {
+				break
+			}
+		}
+		ev.buf = nil
+	}
+
+	ev.done = true
+}
+
+func (ev *QueryEvaluator) output(rec *StatementRecord) {
+	if !ev.distinct(rec) {
+		return
+	}
+
+	ev.count++
+	if !ev.emit(ev.plan.getf(rec)) {
+		ev.done = true
+		return
+	}
+
+	limit := ev.plan.query.limit
+	if limit > 0 && ev.count >= limit {
+		ev.done = true
+	}
+}
+
+func (ev *QueryEvaluator) aggregate(rec *StatementRecord) {
+	if !ev.distinct(rec) {
+		return
+	}
+
+	if ev.plan.fun != "COUNT" {
+		xval := ev.plan.getf(rec).(int64)
+		if ev.count == 0 || xval < ev.min {
+			ev.min = xval
+		}
+		if ev.count == 0 || xval > ev.max {
+			ev.max = xval
+		}
+	}
+
+	ev.count++
+}
+
+func (ev *QueryEvaluator) distinct(rec *StatementRecord) bool {
+	if ev.seen == nil {
+		return true
+	}
+
+	key := ev.plan.keyf(rec)
+	if ev.seen[key] {
+		return false
+	}
+
+	ev.seen[key] = true
+	return true
+}
+
+// MIN and MAX have 0 as NULL semantics
+func (ev *QueryEvaluator) emitAggregate(val int64) {
+	if ev.count == 0 {
+		ev.emit(0)
+	} else {
+		ev.emit(val)
+	}
+}
+
+func emptyRecordFilter(*StatementRecord) bool {
+	return true
+}
+
+func makeRecordFilter(c QueryCriteria) (RecordFilter, error) {
+	switch c := c.(type) {
+	case *ValueCriteria:
+		getf, ok := recordValueSelect[c.sel]
+		if !ok {
+			return nil, QueryEvalError(fmt.Sprintf("Unexpected criteria selector: %s", c.sel))
+		}
+
+		cmpf, ok := valueCriteriaFilterCompare[c.op]
+		if !ok {
+			return nil, QueryEvalError(fmt.Sprintf("Unexpected criteria operator: %s", c.op))
+		}
+
+		return func(rec *StatementRecord) bool {
+			return cmpf(getf(rec.Stmt), c.val)
+		}, nil
+
+	case *RangeCriteria:
+		if c.sel != "counter" {
+			return makeStatementRecordFilter(c)
+		}
+
+		filter, ok := rangeCriteriaFilterCompare[c.op]
+		if !ok {
+			return nil, QueryEvalError(fmt.Sprintf("Unexpected criteria range op: %s", c.op))
+		}
+
+		return func(rec *StatementRecord) bool {
+			return filter(rec.Counter, c.val)
+		}, nil
+
+	case *CompoundCriteria:
+		left, err := makeRecordFilter(c.left)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := makeRecordFilter(c.right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch c.op {
+		case "AND":
+			return func(rec *StatementRecord) bool {
+				return left(rec) && right(rec)
+			}, nil
+
+		case "OR":
+			return func(rec *StatementRecord) bool {
+				return left(rec) || right(rec)
+			}, nil
+
+		default:
+			return nil, QueryEvalError(fmt.Sprintf("Unexpected criteria combinator: %s", c.op))
+		}
+
+	case *NegatedCriteria:
+		filter, err := makeRecordFilter(c.e)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(rec *StatementRecord) bool {
+			return !filter(rec)
+		}, nil
+
+	default:
+		return makeStatementRecordFilter(c)
+	}
+}
+
+func makeStatementRecordFilter(c QueryCriteria) (RecordFilter, error) {
+	filter, err := makeCriteriaFilterF(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rec *StatementRecord) bool {
+		return filter(rec.Stmt)
+	}, nil
+}
+
+func recordSelectorCounter(rec *StatementRecord) interface{} {
+	return rec.Counter
+}
+
+func makeRecordSelector(sel SimpleSelector) (RecordSelector, error) {
+	if sel == "counter" {
+		return recordSelectorCounter, nil
+	}
+
+	getf, ok := simpleSelectors[string(sel)]
+	if !ok {
+		return nil, QueryEvalError(fmt.Sprintf("Unexpected selector: %s", sel))
+	}
+
+	return func(rec *StatementRecord) interface{} {
+		return getf(rec.Stmt)
+	}, nil
+}
+
+func makeCompoundRecordSelector(sels []SimpleSelector, getfs []RecordSelector) RecordSelector {
+	return func(rec *StatementRecord) interface{} {
+		val := make(map[string]interface{})
+		for x, sel := range sels {
+			val[string(sel)] = getfs[x](rec)
+		}
+		return val
+	}
+}
+
+// same as the DISTINCT columns in selectorColumnSimple
+var recordSelectorDistinct = map[string]bool{
+	"namespace": true,
+	"publisher": true,
+	"source":    true}
+
+func makeRecordCompare(spec *QueryOrderSpec) (RecordCompare, error) {
+	var cmpf RecordCompare
+	switch spec.sel {
+	case "counter":
+		cmpf = func(a, b *StatementRecord) int {
+			return compareInt64(a.Counter, b.Counter)
+		}
+
+	case "timestamp":
+		cmpf = func(a, b *StatementRecord) int {
+			return compareInt64(a.Stmt.Timestamp, b.Stmt.Timestamp)
+		}
+
+	default:
+		getf, ok := recordValueSelect[spec.sel]
+		if !ok {
+			return nil, QueryEvalError(fmt.Sprintf("Unexpected order selector: %s", spec.sel))
+		}
+
+		cmpf = func(a, b *StatementRecord) int {
+			return strings.Compare(getf(a.Stmt), getf(b.Stmt))
+		}
+	}
+
+	if spec.dir == "DESC" {
+		return func(a, b *StatementRecord) int {
+			return cmpf(b, a)
+		}, nil
+	}
+
+	return cmpf, nil
+}
+
+func namespaceCriteriaFilter(stmt *pb.Statement) string {
+	return stmt.Namespace
+}
+
+// the source column of compiled queries is the actual statement source
+var recordValueSelect = map[string]ValueCriteriaFilterSelect{
+	"id":        idCriteriaFilter,
+	"namespace": namespaceCriteriaFilter,
+	"publisher": publisherCriteriaFilter,
+	"source":    StatementSource}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type recordSort struct {
+	recs  []*StatementRecord
+	order []RecordCompare
+}
+
+func (rs *recordSort) Len() int {
+	return len(rs.recs)
+}
+
+func (rs *recordSort) Swap(i, j int) {
+	rs.recs[i], rs.recs[j] = rs.recs[j], rs.recs[i]
+}
+
+func (rs *recordSort) Less(i, j int) bool {
+	for _, cmpf := range rs.order {
+		switch cmpf(rs.recs[i], rs.recs[j]) {
+		case -1:
+			return true
+		case 1:
+			return false
+		}
+	}
+	return false
+}
@@ -47,6 +47,7 @@ const (
 	ruleComparisonOp
 	ruleIndexCriteria
 	ruleWKICriteria
+	ruleRetractsCriteria
 	ruleOrder
 	ruleOrderSpec
 	ruleOrderSelectorSpec
@@ -98,6 +99,8 @@ const (
 	ruleAction30
 	ruleAction31
 	ruleAction32
+	ruleAction33
+	ruleAction34
 
 	rulePre
 	ruleIn
@@ -139,6 +142,7 @@ var rul3s = [...]string{
 	"ComparisonOp",
 	"IndexCriteria",
 	"WKICriteria",
+	"RetractsCriteria",
 	"Order",
 	"OrderSpec",
 	"OrderSelectorSpec",
@@ -190,6 +194,8 @@ var rul3s = [...]string{
 	"Action30",
 	"Action31",
 	"Action32",
+	"Action33",
+	"Action34",
 
 	"Pre_",
 	"_In_",
@@ -485,7 +491,7 @@ type QueryParser struct {
 
 	Buffer string
 	buffer []rune
-	rules  [85]func() bool
+	rules  [88]func() bool
 	Parse  func(rule ...int) error
 	Reset  func()
 	Pretty bool
@@ -624,16 +630,20 @@ func (p *QueryParser) Execute() {
 		case ruleAction26:
 			p.push(text)
 		case ruleAction27:
-			p.setOrder()
+			p.push(text)
 		case ruleAction28:
-			p.addOrderSelector()
+			p.push(text)
 		case ruleAction29:
-			p.setOrderDir()
+			p.setOrder()
 		case ruleAction30:
-			p.push(text)
+			p.addOrderSelector()
 		case ruleAction31:
-			p.push(text)
+			p.setOrderDir()
 		case ruleAction32:
+			p.push(text)
+		case ruleAction33:
+			p.push(text)
+		case ruleAction34:
 			p.setLimit(text)
 
 		}
@@ -985,7 +995,7 @@ func (p *QueryParser) Init() {
 									add(ruleOrderSpec, position26)
 								}
 								{
-									add(ruleAction27, position)
+									add(ruleAction29, position)
 								}
 								depth--
 								add(ruleOrder, position25)
@@ -1679,7 +1689,7 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position77, tokenIndex77, depth77
 			return false
 		},
-		/* 16 CompoundCriteria <- <((&('N') ('N' 'O' 'T' WS CompoundCriteria Action10)) | (&('(') ('(' MultiCriteria ')')) | (&('c' | 'i' | 'p' | 's' | 't' | 'w') SimpleCriteria))> */
+		/* 16 CompoundCriteria <- <((&('N') ('N' 'O' 'T' WS CompoundCriteria Action10)) | (&('(') ('(' MultiCriteria ')')) | (&('c' | 'i' | 'p' | 'r' | 's' | 't' | 'w') SimpleCriteria))> */
 		func() bool {
 			position88, tokenIndex88, depth88 := position, tokenIndex, depth
 			{
@@ -1729,174 +1739,317 @@ func (p *QueryParser) Init() {
 							depth++
 							{
 								switch buffer[position] {
-								case 'w':
+								case 'r', 'w':
 									{
 										position94 := position
 										depth++
 										{
-											position95 := position
-											depth++
-											{
-												position96 := position
-												depth++
-												if buffer[position] != rune('w') {
-													goto l88
-												}
-												position++
-												if buffer[position] != rune('k') {
-													goto l88
-												}
-												position++
-												if buffer[position] != rune('i') {
-													goto l88
-												}
-												position++
-												depth--
-												add(rulePegText, position96)
-											}
-											{
-												add(ruleAction25, position)
-											}
-											if !_rules[ruleWSX]() {
-												goto l88
-											}
-											if buffer[position] != rune('=') {
-												goto l88
-											}
-											position++
-											if !_rules[ruleWSX]() {
-												goto l88
-											}
-											{
-												position98 := position
-												depth++
+											switch buffer[position] {
+											case 'r':
 												{
-													position99 := position
+													position225 := position
 													depth++
 													{
-														switch buffer[position] {
-														case '.':
-															if buffer[position] != rune('.') {
-																goto l88
-															}
-															position++
-															break
-														case '/':
-															if buffer[position] != rune('/') {
-																goto l88
-															}
-															position++
-															break
-														case '_':
-															if buffer[position] != rune('_') {
-																goto l88
-															}
-															position++
-															break
-														case ':':
-															if buffer[position] != rune(':') {
-																goto l88
-															}
-															position++
-															break
-														case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-															if c := buffer[position]; c < rune('0') || c > rune('9') {
-																goto l88
-															}
-															position++
-															break
-														case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
-															if c := buffer[position]; c < rune('A') || c > rune('Z') {
-																goto l88
-															}
-															position++
-															break
-														case '-':
-															if buffer[position] != rune('-') {
-																goto l88
-															}
-															position++
-															break
-														default:
-															if c := buffer[position]; c < rune('a') || c > rune('z') {
-																goto l88
-															}
-															position++
-															break
+														position226 := position
+														depth++
+														if buffer[position] != rune('r') {
+															goto l88
 														}
+														position++
+														if buffer[position] != rune('e') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('t') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('r') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('a') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('c') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('t') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('s') {
+															goto l88
+														}
+														position++
+														depth--
+														add(rulePegText, position226)
 													}
-
-												l100:
 													{
-														position101, tokenIndex101, depth101 := position, tokenIndex, depth
+														add(ruleAction27, position)
+													}
+													if !_rules[ruleWSX]() {
+														goto l88
+													}
+													if buffer[position] != rune('=') {
+														goto l88
+													}
+													position++
+													if !_rules[ruleWSX]() {
+														goto l88
+													}
+													{
+														position227 := position
+														depth++
 														{
-															switch buffer[position] {
-															case '.':
-																if buffer[position] != rune('.') {
-																	goto l101
-																}
-																position++
-																break
-															case '/':
-																if buffer[position] != rune('/') {
-																	goto l101
-																}
-																position++
-																break
-															case '_':
-																if buffer[position] != rune('_') {
-																	goto l101
-																}
-																position++
-																break
-															case ':':
-																if buffer[position] != rune(':') {
-																	goto l101
-																}
-																position++
-																break
-															case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-																if c := buffer[position]; c < rune('0') || c > rune('9') {
-																	goto l101
+															position228 := position
+															depth++
+															{
+																switch buffer[position] {
+																case ':':
+																	if buffer[position] != rune(':') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+																	if c := buffer[position]; c < rune('0') || c > rune('9') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
+																	if c := buffer[position]; c < rune('A') || c > rune('Z') {
+																		goto l88
+																	}
+																	position++
+																	break
+																default:
+																	if c := buffer[position]; c < rune('a') || c > rune('z') {
+																		goto l88
+																	}
+																	position++
+																	break
 																}
-																position++
-																break
-															case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
-																if c := buffer[position]; c < rune('A') || c > rune('Z') {
-																	goto l101
+															}
+
+														l229:
+															{
+																position230, tokenIndex230, depth230 := position, tokenIndex, depth
+																{
+																	switch buffer[position] {
+																	case ':':
+																		if buffer[position] != rune(':') {
+																			goto l230
+																		}
+																		position++
+																		break
+																	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+																		if c := buffer[position]; c < rune('0') || c > rune('9') {
+																			goto l230
+																		}
+																		position++
+																		break
+																	case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
+																		if c := buffer[position]; c < rune('A') || c > rune('Z') {
+																			goto l230
+																		}
+																		position++
+																		break
+																	default:
+																		if c := buffer[position]; c < rune('a') || c > rune('z') {
+																			goto l230
+																		}
+																		position++
+																		break
+																	}
 																}
-																position++
-																break
-															case '-':
-																if buffer[position] != rune('-') {
-																	goto l101
+
+																goto l229
+															l230:
+																position, tokenIndex, depth = position230, tokenIndex230, depth230
+															}
+															depth--
+															add(rulePegText, position228)
+														}
+														depth--
+														add(ruleStatementId, position227)
+													}
+													{
+														add(ruleAction28, position)
+													}
+													depth--
+													add(ruleRetractsCriteria, position225)
+												}
+											default:
+												{
+													position95 := position
+													depth++
+													{
+														position96 := position
+														depth++
+														if buffer[position] != rune('w') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('k') {
+															goto l88
+														}
+														position++
+														if buffer[position] != rune('i') {
+															goto l88
+														}
+														position++
+														depth--
+														add(rulePegText, position96)
+													}
+													{
+														add(ruleAction25, position)
+													}
+													if !_rules[ruleWSX]() {
+														goto l88
+													}
+													if buffer[position] != rune('=') {
+														goto l88
+													}
+													position++
+													if !_rules[ruleWSX]() {
+														goto l88
+													}
+													{
+														position98 := position
+														depth++
+														{
+															position99 := position
+															depth++
+															{
+																switch buffer[position] {
+																case '.':
+																	if buffer[position] != rune('.') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case '/':
+																	if buffer[position] != rune('/') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case '_':
+																	if buffer[position] != rune('_') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case ':':
+																	if buffer[position] != rune(':') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+																	if c := buffer[position]; c < rune('0') || c > rune('9') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
+																	if c := buffer[position]; c < rune('A') || c > rune('Z') {
+																		goto l88
+																	}
+																	position++
+																	break
+																case '-':
+																	if buffer[position] != rune('-') {
+																		goto l88
+																	}
+																	position++
+																	break
+																default:
+																	if c := buffer[position]; c < rune('a') || c > rune('z') {
+																		goto l88
+																	}
+																	position++
+																	break
 																}
-																position++
-																break
-															default:
-																if c := buffer[position]; c < rune('a') || c > rune('z') {
-																	goto l101
+															}
+
+														l100:
+															{
+																position101, tokenIndex101, depth101 := position, tokenIndex, depth
+																{
+																	switch buffer[position] {
+																	case '.':
+																		if buffer[position] != rune('.') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	case '/':
+																		if buffer[position] != rune('/') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	case '_':
+																		if buffer[position] != rune('_') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	case ':':
+																		if buffer[position] != rune(':') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+																		if c := buffer[position]; c < rune('0') || c > rune('9') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z':
+																		if c := buffer[position]; c < rune('A') || c > rune('Z') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	case '-':
+																		if buffer[position] != rune('-') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	default:
+																		if c := buffer[position]; c < rune('a') || c > rune('z') {
+																			goto l101
+																		}
+																		position++
+																		break
+																	}
 																}
-																position++
-																break
+
+																goto l100
+															l101:
+																position, tokenIndex, depth = position101, tokenIndex101, depth101
 															}
+															depth--
+															add(rulePegText, position99)
 														}
-
-														goto l100
-													l101:
-														position, tokenIndex, depth = position101, tokenIndex101, depth101
+														depth--
+														add(ruleWKI, position98)
+													}
+													{
+														add(ruleAction26, position)
 													}
 													depth--
-													add(rulePegText, position99)
+													add(ruleWKICriteria, position95)
 												}
-												depth--
-												add(ruleWKI, position98)
-											}
-											{
-												add(ruleAction26, position)
 											}
-											depth--
-											add(ruleWKICriteria, position95)
 										}
 										depth--
 										add(ruleIndexCriteria, position94)
@@ -2372,7 +2525,7 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position88, tokenIndex88, depth88
 			return false
 		},
-		/* 17 SimpleCriteria <- <((&('w') (IndexCriteria Action13)) | (&('c' | 't') (RangeCriteria Action12)) | (&('i' | 'p' | 's') (ValueCriteria Action11)))> */
+		/* 17 SimpleCriteria <- <((&('r' | 'w') (IndexCriteria Action13)) | (&('c' | 't') (RangeCriteria Action12)) | (&('i' | 'p' | 's') (ValueCriteria Action11)))> */
 		nil,
 		/* 18 ValueCriteria <- <((&('s') SourceCriteria) | (&('p') PublisherCriteria) | (&('i') IdCriteria))> */
 		nil,
@@ -2446,15 +2599,17 @@ func (p *QueryParser) Init() {
 		nil,
 		/* 30 ComparisonOp <- <(('<' '=') / ('>' '=') / ((&('>') '>') | (&('!') ('!' '=')) | (&('=') '=') | (&('<') '<')))> */
 		nil,
-		/* 31 IndexCriteria <- <WKICriteria> */
+		/* 31 IndexCriteria <- <((&('r') RetractsCriteria) | (&('w') WKICriteria))> */
 		nil,
 		/* 32 WKICriteria <- <(<('w' 'k' 'i')> Action25 WSX '=' WSX WKI Action26)> */
 		nil,
-		/* 33 Order <- <('O' 'R' 'D' 'E' 'R' WS ('B' 'Y') WS OrderSpec Action27)> */
+		/* 33 RetractsCriteria <- <(<('r' 'e' 't' 'r' 'a' 'c' 't' 's')> Action27 WSX '=' WSX StatementId Action28)> */
+		nil,
+		/* 34 Order <- <('O' 'R' 'D' 'E' 'R' WS ('B' 'Y') WS OrderSpec Action29)> */
 		nil,
-		/* 34 OrderSpec <- <(OrderSelectorSpec (',' WSX OrderSelectorSpec)*)> */
+		/* 35 OrderSpec <- <(OrderSelectorSpec (',' WSX OrderSelectorSpec)*)> */
 		nil,
-		/* 35 OrderSelectorSpec <- <(OrderSelector Action28 (WS OrderDir Action29)?)> */
+		/* 36 OrderSelectorSpec <- <(OrderSelector Action30 (WS OrderDir Action31)?)> */
 		func() bool {
 			position168, tokenIndex168, depth168 := position, tokenIndex, depth
 			{
@@ -2661,13 +2816,13 @@ func (p *QueryParser) Init() {
 						add(rulePegText, position171)
 					}
 					{
-						add(ruleAction30, position)
+						add(ruleAction32, position)
 					}
 					depth--
 					add(ruleOrderSelector, position170)
 				}
 				{
-					add(ruleAction28, position)
+					add(ruleAction30, position)
 				}
 				{
 					position176, tokenIndex176, depth176 := position, tokenIndex, depth
@@ -2725,13 +2880,13 @@ func (p *QueryParser) Init() {
 							add(rulePegText, position179)
 						}
 						{
-							add(ruleAction31, position)
+							add(ruleAction33, position)
 						}
 						depth--
 						add(ruleOrderDir, position178)
 					}
 					{
-						add(ruleAction29, position)
+						add(ruleAction31, position)
 					}
 					goto l177
 				l176:
@@ -2746,15 +2901,15 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position168, tokenIndex168, depth168
 			return false
 		},
-		/* 36 OrderSelector <- <(<OrderSelectorOp> Action30)> */
+		/* 37 OrderSelector <- <(<OrderSelectorOp> Action32)> */
 		nil,
-		/* 37 OrderSelectorOp <- <((&('c') ('c' 'o' 'u' 'n' 't' 'e' 'r')) | (&('t') ('t' 'i' 'm' 'e' 's' 't' 'a' 'm' 'p')) | (&('s') ('s' 'o' 'u' 'r' 'c' 'e')) | (&('p') ('p' 'u' 'b' 'l' 'i' 's' 'h' 'e' 'r')) | (&('n') ('n' 'a' 'm' 'e' 's' 'p' 'a' 'c' 'e')) | (&('i') ('i' 'd')))> */
+		/* 38 OrderSelectorOp <- <((&('c') ('c' 'o' 'u' 'n' 't' 'e' 'r')) | (&('t') ('t' 'i' 'm' 'e' 's' 't' 'a' 'm' 'p')) | (&('s') ('s' 'o' 'u' 'r' 'c' 'e')) | (&('p') ('p' 'u' 'b' 'l' 'i' 's' 'h' 'e' 'r')) | (&('n') ('n' 'a' 'm' 'e' 's' 'p' 'a' 'c' 'e')) | (&('i') ('i' 'd')))> */
 		nil,
-		/* 38 OrderDir <- <(<OrderDirOp> Action31)> */
+		/* 39 OrderDir <- <(<OrderDirOp> Action33)> */
 		nil,
-		/* 39 OrderDirOp <- <(('A' 'S' 'C') / ('D' 'E' 'S' 'C'))> */
+		/* 40 OrderDirOp <- <(('A' 'S' 'C') / ('D' 'E' 'S' 'C'))> */
 		nil,
-		/* 40 Limit <- <('L' 'I' 'M' 'I' 'T' WS UInt Action32)> */
+		/* 41 Limit <- <('L' 'I' 'M' 'I' 'T' WS UInt Action34)> */
 		func() bool {
 			position189, tokenIndex189, depth189 := position, tokenIndex, depth
 			{
@@ -2787,7 +2942,7 @@ func (p *QueryParser) Init() {
 					goto l189
 				}
 				{
-					add(ruleAction32, position)
+					add(ruleAction34, position)
 				}
 				depth--
 				add(ruleLimit, position190)
@@ -2797,9 +2952,9 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position189, tokenIndex189, depth189
 			return false
 		},
-		/* 41 StatementId <- <<((&(':') ':') | (&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))+>> */
+		/* 42 StatementId <- <<((&(':') ':') | (&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))+>> */
 		nil,
-		/* 42 PublisherId <- <<((&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))+>> */
+		/* 43 PublisherId <- <<((&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))+>> */
 		func() bool {
 			position193, tokenIndex193, depth193 := position, tokenIndex, depth
 			{
@@ -2872,9 +3027,9 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position193, tokenIndex193, depth193
 			return false
 		},
-		/* 43 WKI <- <<((&('.') '.') | (&('/') '/') | (&('_') '_') | (&(':') ':') | (&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('-') '-') | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))+>> */
+		/* 44 WKI <- <<((&('.') '.') | (&('/') '/') | (&('_') '_') | (&(':') ':') | (&('0' | '1' | '2' | '3' | '4' | '5' | '6' | '7' | '8' | '9') [0-9]) | (&('A' | 'B' | 'C' | 'D' | 'E' | 'F' | 'G' | 'H' | 'I' | 'J' | 'K' | 'L' | 'M' | 'N' | 'O' | 'P' | 'Q' | 'R' | 'S' | 'T' | 'U' | 'V' | 'W' | 'X' | 'Y' | 'Z') [A-Z]) | (&('-') '-') | (&('a' | 'b' | 'c' | 'd' | 'e' | 'f' | 'g' | 'h' | 'i' | 'j' | 'k' | 'l' | 'm' | 'n' | 'o' | 'p' | 'q' | 'r' | 's' | 't' | 'u' | 'v' | 'w' | 'x' | 'y' | 'z') [a-z]))+>> */
 		nil,
-		/* 44 UInt <- <<[0-9]+>> */
+		/* 45 UInt <- <<[0-9]+>> */
 		func() bool {
 			position201, tokenIndex201, depth201 := position, tokenIndex, depth
 			{
@@ -2909,7 +3064,7 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position201, tokenIndex201, depth201
 			return false
 		},
-		/* 45 WS <- <WhiteSpace+> */
+		/* 46 WS <- <WhiteSpace+> */
 		func() bool {
 			position206, tokenIndex206, depth206 := position, tokenIndex, depth
 			{
@@ -2936,7 +3091,7 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position206, tokenIndex206, depth206
 			return false
 		},
-		/* 46 WSX <- <WhiteSpace*> */
+		/* 47 WSX <- <WhiteSpace*> */
 		func() bool {
 			{
 				position211 := position
@@ -2956,7 +3111,7 @@ func (p *QueryParser) Init() {
 			}
 			return true
 		},
-		/* 47 WhiteSpace <- <((&('\t') '\t') | (&(' ') ' ') | (&('\n' | '\r') EOL))> */
+		/* 48 WhiteSpace <- <((&('\t') '\t') | (&(' ') ' ') | (&('\n' | '\r') EOL))> */
 		func() bool {
 			position214, tokenIndex214, depth214 := position, tokenIndex, depth
 			{
@@ -3021,9 +3176,9 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position214, tokenIndex214, depth214
 			return false
 		},
-		/* 48 EOL <- <(('\r' '\n') / '\n' / '\r')> */
+		/* 49 EOL <- <(('\r' '\n') / '\n' / '\r')> */
 		nil,
-		/* 49 EOF <- <!.> */
+		/* 50 EOF <- <!.> */
 		func() bool {
 			position222, tokenIndex222, depth222 := position, tokenIndex, depth
 			{
@@ -3046,72 +3201,76 @@ func (p *QueryParser) Init() {
 			position, tokenIndex, depth = position222, tokenIndex222, depth222
 			return false
 		},
-		/* 51 Action0 <- <{ p.setSelectOp() }> */
+		/* 52 Action0 <- <{ p.setSelectOp() }> */
+		nil,
+		/* 53 Action1 <- <{ p.setDeleteOp() }> */
+		nil,
+		/* 54 Action2 <- <{ p.setSimpleSelector() }> */
 		nil,
-		/* 52 Action1 <- <{ p.setDeleteOp() }> */
+		/* 55 Action3 <- <{ p.setCompoundSelector() }> */
 		nil,
-		/* 53 Action2 <- <{ p.setSimpleSelector() }> */
+		/* 56 Action4 <- <{ p.setFunctionSelector() }> */
 		nil,
-		/* 54 Action3 <- <{ p.setCompoundSelector() }> */
 		nil,
-		/* 55 Action4 <- <{ p.setFunctionSelector() }> */
+		/* 58 Action5 <- <{ p.push(text) }> */
 		nil,
+		/* 59 Action6 <- <{ p.push(text) }> */
 		nil,
-		/* 57 Action5 <- <{ p.push(text) }> */
+		/* 60 Action7 <- <{ p.setNamespace(text) }> */
 		nil,
-		/* 58 Action6 <- <{ p.push(text) }> */
+		/* 61 Action8 <- <{ p.setCriteria() }> */
 		nil,
-		/* 59 Action7 <- <{ p.setNamespace(text) }> */
+		/* 62 Action9 <- <{ p.addCompoundCriteria() }> */
 		nil,
-		/* 60 Action8 <- <{ p.setCriteria() }> */
+		/* 63 Action10 <- <{ p.addNegatedCriteria() }> */
 		nil,
-		/* 61 Action9 <- <{ p.addCompoundCriteria() }> */
+		/* 64 Action11 <- <{ p.addValueCriteria() }> */
 		nil,
-		/* 62 Action10 <- <{ p.addNegatedCriteria() }> */
+		/* 65 Action12 <- <{ p.addRangeCriteria() }> */
 		nil,
-		/* 63 Action11 <- <{ p.addValueCriteria() }> */
+		/* 66 Action13 <- <{ p.addIndexCriteria() }> */
 		nil,
-		/* 64 Action12 <- <{ p.addRangeCriteria() }> */
+		/* 67 Action14 <- <{ p.push(text) }> */
 		nil,
-		/* 65 Action13 <- <{ p.addIndexCriteria() }> */
+		/* 68 Action15 <- <{ p.push(text) }> */
 		nil,
-		/* 66 Action14 <- <{ p.push(text) }> */
+		/* 69 Action16 <- <{ p.push(text) }> */
 		nil,
-		/* 67 Action15 <- <{ p.push(text) }> */
+		/* 70 Action17 <- <{ p.push(text) }> */
 		nil,
-		/* 68 Action16 <- <{ p.push(text) }> */
+		/* 71 Action18 <- <{ p.push(text) }> */
 		nil,
-		/* 69 Action17 <- <{ p.push(text) }> */
+		/* 72 Action19 <- <{ p.push(text) }> */
 		nil,
-		/* 70 Action18 <- <{ p.push(text) }> */
+		/* 73 Action20 <- <{ p.push(text) }> */
 		nil,
-		/* 71 Action19 <- <{ p.push(text) }> */
+		/* 74 Action21 <- <{ p.push(text) }> */
 		nil,
-		/* 72 Action20 <- <{ p.push(text) }> */
+		/* 75 Action22 <- <{ p.push(text) }> */
 		nil,
-		/* 73 Action21 <- <{ p.push(text) }> */
+		/* 76 Action23 <- <{ p.push(text) }> */
 		nil,
-		/* 74 Action22 <- <{ p.push(text) }> */
+		/* 77 Action24 <- <{ p.push(text) }> */
 		nil,
-		/* 75 Action23 <- <{ p.push(text) }> */
+		/* 78 Action25 <- <{ p.push(text) }> */
 		nil,
-		/* 76 Action24 <- <{ p.push(text) }> */
+		/* 79 Action26 <- <{ p.push(text) }> */
 		nil,
-		/* 77 Action25 <- <{ p.push(text) }> */
+		/* 80 Action27 <- <{ p.push(text) }> */
 		nil,
-		/* 78 Action26 <- <{ p.push(text) }> */
+		/* 81 Action28 <- <{ p.push(text) }> */
 		nil,
-		/* 79 Action27 <- <{ p.setOrder() }> */
+		/* 82 Action29 <- <{ p.setOrder() }> */
 		nil,
-		/* 80 Action28 <- <{ p.addOrderSelector() }> */
+		/* 83 Action30 <- <{ p.addOrderSelector() }> */
 		nil,
-		/* 81 Action29 <- <{ p.setOrderDir() }> */
+		/* 84 Action31 <- <{ p.setOrderDir() }> */
 		nil,
-		/* 82 Action30 <- <{ p.push(text) }> */
+		/* 85 Action32 <- <{ p.push(text) }> */
 		nil,
-		/* 83 Action31 <- <{ p.push(text) }> */
+		/* 86 Action33 <- <{ p.push(text) }> */
 		nil,
-		/* 84 Action32 <- <{ p.setLimit(text) }> */
+		/* 87 Action34 <- <{ p.setLimit(text) }> */
 		nil,
 	}
 	p.rules = _rules
@@ -65,6 +65,7 @@ var simpleq []string = []string{
 	"SELECT * FROM foo.bar WHERE publisher = abc LIMIT 10",
 	"SELECT * FROM foo.bar WHERE wki = mywki:abc",
 	"SELECT * FROM foo.bar WHERE wki = mywki:abc-defg_123-ABC/xyz.XYZ",
+	"SELECT * FROM foo.bar WHERE retracts = QmABC:1474000000:0",
 	"SELECT * FROM foo.bar LIMIT 10",
 	"SELECT * FROM * WHERE id = abc",
 	"SELECT * FROM * ORDER BY id",
@@ -634,6 +635,69 @@ func TestPBWTF(t *testing.T) {
 	}
 }
 
+func TestRetractRefs(t *testing.T) {
+	a := &pb.Statement{
+		Id:        "A:100:0",
+		Publisher: "A",
+		Namespace: "foo.a",
+		Body:      &pb.StatementBody{&pb.StatementBody_Simple{&pb.SimpleStatement{Object: "QmAAA", Refs: []string{"aaa"}}}},
+		Timestamp: 100}
+
+	r := &pb.Statement{
+		Id:        "A:200:1",
+		Publisher: "A",
+		Namespace: "foo.a",
+		Body:      &pb.StatementBody{&pb.StatementBody_Retract{&pb.RetractStatement{Statements: []string{"A:100:0"}}}},
+		Timestamp: 200}
+
+	bytes, err := ggproto.Marshal(r)
+	checkErrorNow(t, "Marshal", err)
+
+	rr := new(pb.Statement)
+	err = ggproto.Unmarshal(bytes, rr)
+	checkErrorNow(t, "Unmarshal", err)
+
+	if !reflect.DeepEqual(r, rr) {
+		t.Logf("Expected: %v; Got: %v", r, rr)
+		t.FailNow()
+	}
+
+	// retractions are found by the statements they retract, not as wkis
+	qs := "SELECT * FROM * WHERE wki = A:100:0"
+	q, err := ParseQuery(qs)
+	checkErrorNow(t, qs, err)
+
+	res, err := EvalQuery(q, []*pb.Statement{a, r})
+	checkErrorNow(t, qs, err)
+	checkResultLen(t, qs, res, 0)
+
+	db, err := makeStmtDb()
+	checkErrorNow(t, "makeStmtDb", err)
+
+	for _, stmt := range []*pb.Statement{a, r} {
+		err = insertStmt(db, stmt)
+		checkErrorNow(t, "insertStmt", err)
+	}
+
+	qs = "SELECT * FROM * WHERE retracts = A:100:0"
+	q, err = ParseQuery(qs)
+	checkErrorNow(t, qs, err)
+
+	res, err = EvalQuery(q, []*pb.Statement{a, r})
+	checkErrorNow(t, qs, err)
+
+	if checkResultLen(t, qs, res, 1) {
+		checkContains(t, qs, res, r)
+	}
+
+	res, err = parseCompileEval(db, qs)
+	checkErrorNow(t, qs, err)
+
+	if checkResultLen(t, qs, res, 1) {
+		checkContains(t, qs, res, r)
+	}
+}
+
 func TestQueryCompileEval(t *testing.T) {
 	a := &pb.Statement{
 		Id:        "a",
@@ -1229,6 +1293,11 @@ func makeStmtDb() (*sql.DB, error) {
 		return nil, err
 	}
 
+	_, err = db.Exec("CREATE TABLE Retracts (id VARCHAR(32), retracts VARCHAR(32))")
+	if err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
@@ -1254,6 +1323,13 @@ func insertStmt(db *sql.DB, stmt *pb.Statement) error {
 		}
 	}
 
+	for _, id := range StatementRetracts(stmt) {
+		_, err = db.Exec("INSERT INTO Retracts VALUES (?, ?)", stmt.Id, id)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1286,3 +1362,158 @@ func parseCompileEval(db *sql.DB, qs string) ([]interface{}, error) {
 
 	return res, nil
 }
+
+func TestQueryPlanEval(t *testing.T) {
+	a := &pb.Statement{
+		Id:        "a",
+		Publisher: "A",
+		Namespace: "foo.a",
+		Body:      &pb.StatementBody{&pb.StatementBody_Simple{&pb.SimpleStatement{Object: "QmAAA", Refs: []string{"aaa"}}}},
+		Timestamp: 100}
+
+	b := &pb.Statement{
+		Id:        "b",
+		Publisher: "B",
+		Namespace: "foo.b",
+		Body:      &pb.StatementBody{&pb.StatementBody_Simple{&pb.SimpleStatement{Object: "QmBBB", Refs: []string{"bbb"}}}},
+		Timestamp: 200}
+
+	c := &pb.Statement{
+		Id:        "c",
+		Publisher: "A",
+		Namespace: "bar.c",
+		Body:      &pb.StatementBody{&pb.StatementBody_Simple{&pb.SimpleStatement{Object: "QmCCC", Refs: []string{"ccc"}}}},
+		Timestamp: 300}
+
+	d := &pb.Statement{
+		Id:        "d",
+		Publisher: "B",
+		Namespace: "foo.a",
+		Body:      &pb.StatementBody{&pb.StatementBody_Simple{&pb.SimpleStatement{Object: "QmDDD", Refs: []string{"aaa", "ddd"}}}},
+		Timestamp: 150}
+
+	stmts := []*pb.Statement{a, b, c, d}
+
+	db, err := makeStmtDb()
+	checkErrorNow(t, "makeStmtDb", err)
+
+	recs := make([]*StatementRecord, len(stmts))
+	for x, stmt := range stmts {
+		err = insertStmt(db, stmt)
+		checkErrorNow(t, "insertStmt", err)
+		recs[x] = &StatementRecord{Counter: int64(x + 1), Stmt: stmt}
+	}
+
+	// the plan must agree with the compiled sql query
+	queries := []string{
+		"SELECT * FROM *",
+		"SELECT * FROM foo.a",
+		"SELECT * FROM foo.*",
+		"SELECT id FROM *",
+		"SELECT body FROM foo.*",
+		"SELECT namespace FROM *",
+		"SELECT publisher FROM *",
+		"SELECT source FROM foo.*",
+		"SELECT timestamp FROM *",
+		"SELECT counter FROM *",
+		"SELECT (namespace) FROM *",
+		"SELECT (id, namespace, counter) FROM *",
+		"SELECT COUNT(*) FROM *",
+		"SELECT COUNT(namespace) FROM *",
+		"SELECT COUNT(publisher) FROM foo.*",
+		"SELECT MIN(timestamp) FROM *",
+		"SELECT MAX(timestamp) FROM foo.*",
+		"SELECT MIN(counter) FROM foo.*",
+		"SELECT MAX(counter) FROM *",
+		"SELECT MAX(counter) FROM nothing",
+		"SELECT * FROM * WHERE id = a",
+		"SELECT * FROM * WHERE publisher = A",
+		"SELECT * FROM * WHERE source != A",
+		"SELECT * FROM * WHERE counter > 2",
+		"SELECT * FROM * WHERE timestamp <= 150",
+		"SELECT * FROM * WHERE wki = aaa",
+		"SELECT * FROM * WHERE wki = aaa AND NOT counter = 1",
+		"SELECT * FROM * WHERE wki = bbb OR timestamp > 200",
+		"SELECT * FROM * WHERE retracts = a",
+		"SELECT * FROM * ORDER BY timestamp",
+		"SELECT * FROM * ORDER BY counter DESC",
+		"SELECT id FROM * ORDER BY namespace, timestamp DESC",
+		"SELECT (id, publisher) FROM * ORDER BY publisher DESC, id",
+		"SELECT * FROM * ORDER BY timestamp DESC LIMIT 2",
+		"SELECT * FROM foo.* WHERE publisher = B ORDER BY counter DESC LIMIT 1",
+		"SELECT namespace FROM * ORDER BY namespace LIMIT 2",
+		"SELECT id FROM * LIMIT 3",
+		"DELETE FROM foo.*",
+		"DELETE FROM * WHERE publisher = A",
+	}
+
+	for _, qs := range queries {
+		xres, err := parseCompileEval(db, qs)
+		checkErrorNow(t, qs, err)
+
+		res, err := parsePlanEval(qs, recs)
+		checkErrorNow(t, qs, err)
+
+		if !checkResultLen(t, qs, res, len(xres)) {
+			continue
+		}
+
+		q, _ := ParseQuery(qs)
+		if q.order != nil {
+			checkBool(t, qs, reflect.DeepEqual(res, xres))
+		} else if q.limit == 0 {
+			for _, val := range xres {
+				checkContains(t, qs, res, val)
+			}
+		}
+	}
+
+	// aggregates have the same type as in sql, including the 0 for empty
+	// result sets
+	for _, qs := range []string{"SELECT MIN(timestamp) FROM *", "SELECT MAX(counter) FROM nothing"} {
+		xres, err := parseCompileEval(db, qs)
+		checkErrorNow(t, qs, err)
+
+		res, err := parsePlanEval(qs, recs)
+		checkErrorNow(t, qs, err)
+
+		checkBool(t, qs, reflect.DeepEqual(res, xres))
+	}
+
+	// evaluation stops as soon as the limit is reached
+	q, err := ParseQuery("SELECT * FROM * LIMIT 1")
+	checkErrorNow(t, "ParseQuery", err)
+
+	plan, err := CompileQueryPlan(q)
+	checkErrorNow(t, "CompileQueryPlan", err)
+
+	ev := plan.Begin(func(interface{}) bool { return true })
+	checkBool(t, "Add", !ev.Add(recs[0]))
+}
+
+func parsePlanEval(qs string, recs []*StatementRecord) ([]interface{}, error) {
+	q, err := ParseQuery(qs)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := CompileQueryPlan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]interface{}, 0)
+	ev := plan.Begin(func(val interface{}) bool {
+		res = append(res, val)
+		return true
+	})
+
+	for _, rec := range recs {
+		if !ev.Add(rec) {
+			break
+		}
+	}
+	ev.End()
+
+	return res, nil
+}
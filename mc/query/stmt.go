@@ -57,6 +57,16 @@ func (refs StatementRefSet) List() []string {
 	return lst
 }
 
+// StatementRetracts returns the ids of the statements retracted by a
+// retraction; other statements retract nothing.
+func StatementRetracts(stmt *pb.Statement) []string {
+	body, ok := stmt.Body.Body.(*pb.StatementBody_Retract)
+	if !ok {
+		return nil
+	}
+	return body.Retract.Statements
+}
+
 func StatementSource(stmt *pb.Statement) string {
 	switch body := stmt.Body.Body.(type) {
 	case *pb.StatementBody_Envelope:
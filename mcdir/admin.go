@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	mux "github.com/gorilla/mux"
+	p2p_net "github.com/libp2p/go-libp2p-net"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	mc "github.com/mediachain/concat/mc"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Directory administration
+// The admin interface is an optional http API bound to localhost, which
+// lists registered peers and manifests, reports request counters, bans and
+// unbans peers, and reloads the manifest store.
+// Banned peers are persisted in the registry; their registrations are
+// dropped and their streams are rejected.
+type RequestCounters struct {
+	mx     sync.Mutex
+	counts map[string]int64
+}
+
+func (rc *RequestCounters) inc(key string) {
+	rc.mx.Lock()
+	if rc.counts == nil {
+		rc.counts = make(map[string]int64)
+	}
+	rc.counts[key] += 1
+	rc.mx.Unlock()
+}
+
+func (rc *RequestCounters) snapshot() map[string]int64 {
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+
+	res := make(map[string]int64, len(rc.counts))
+	for key, count := range rc.counts {
+		res[key] = count
+	}
+	return res
+}
+
+// acceptStream logs an incoming stream and rejects streams from banned
+// peers
+func (dir *Directory) acceptStream(s p2p_net.Stream) (p2p_peer.ID, bool) {
+	pid := mc.LogStreamHandler(s)
+
+	if dir.isBanned(pid) {
+		log.Printf("directory: rejecting stream from banned peer %s", pid.Pretty())
+		dir.counters.inc("banned")
+		return pid, false
+	}
+
+	return pid, true
+}
+
+func (dir *Directory) isBanned(pid p2p_peer.ID) bool {
+	dir.mx.Lock()
+	defer dir.mx.Unlock()
+	return dir.bans[pid]
+}
+
+func (dir *Directory) loadBans() error {
+	pids, err := dir.store.LoadBans()
+	if err != nil {
+		return err
+	}
+
+	dir.mx.Lock()
+	for _, pid := range pids {
+		dir.bans[pid] = true
+	}
+	dir.mx.Unlock()
+
+	return nil
+}
+
+func (dir *Directory) banPeer(pid p2p_peer.ID) error {
+	log.Printf("directory: ban %s", pid.Pretty())
+
+	err := dir.store.Ban(pid, time.Now())
+	if err != nil {
+		return err
+	}
+
+	dir.mx.Lock()
+	dir.bans[pid] = true
+	delete(dir.peers, pid)
+	delete(dir.probes, pid)
+	dir.mfs.Remove(pid)
+	dir.mx.Unlock()
+
+	dir.host.Network().ClosePeer(pid)
+
+	return dir.store.Delete(pid)
+}
+
+func (dir *Directory) unbanPeer(pid p2p_peer.ID) error {
+	log.Printf("directory: unban %s", pid.Pretty())
+
+	err := dir.store.Unban(pid)
+	if err != nil {
+		return err
+	}
+
+	dir.mx.Lock()
+	delete(dir.bans, pid)
+	dir.mx.Unlock()
+
+	return nil
+}
+
+func (dir *Directory) listBans() []string {
+	dir.mx.Lock()
+	lst := make([]string, 0, len(dir.bans))
+	for pid, _ := range dir.bans {
+		lst = append(lst, pid.Pretty())
+	}
+	dir.mx.Unlock()
+
+	sort.Strings(lst)
+	return lst
+}
+
+// reloadManifests drops all manifests and cached keys from the manifest
+// store and verifies the manifests and revocations of the registered peers
+// again; returns the number of peers with manifests or revocations.
+// The directory lock is held throughout, so that registrations and removals
+// are not lost between the reset and the reload.
+func (dir *Directory) reloadManifests() int {
+	log.Printf("directory: reload manifests")
+
+	now := time.Now()
+	dir.mx.Lock()
+	defer dir.mx.Unlock()
+
+	dir.mfs.Reset()
+	count := 0
+	for _, rec := range dir.peers {
+		if rec.live(now) && (len(rec.manifest) > 0 || len(rec.revoke) > 0) {
+			dir.putManifests(rec)
+			count += 1
+		}
+	}
+
+	return count
+}
+
+type AdminPeerInfo struct {
+	ID         string                   `json:"id"`
+	Addrs      []string                 `json:"addrs"`
+	Publisher  string                   `json:"publisher,omitempty"`
+	Namespaces []string                 `json:"namespaces,omitempty"`
+	Info       string                   `json:"info,omitempty"`
+	Manifest   []*pb.Manifest           `json:"manifest,omitempty"`
+	Revocation []*pb.ManifestRevocation `json:"revocation,omitempty"`
+	Origin     string                   `json:"origin,omitempty"` // if synced from another directory
+	LastSeen   int64                    `json:"lastSeen,omitempty"`
+	Expires    int64                    `json:"expires"`
+	Probe      *AdminProbe              `json:"probe,omitempty"`
+}
+
+type AdminProbe struct {
+	Reachable bool     `json:"reachable"`
+	Addrs     []string `json:"addrs,omitempty"` // addresses that answered
+	Latency   int64    `json:"latency"`         // ms
+	Checked   int64    `json:"checked"`
+}
+
+type AdminStats struct {
+	Peers     int              `json:"peers"`
+	Manifests int              `json:"manifests"`
+	Banned    int              `json:"banned"`
+	Requests  map[string]int64 `json:"requests"`
+}
+
+func (dir *Directory) serveAdmin(port int) {
+	router := mux.NewRouter()
+	router.HandleFunc("/id", dir.httpId)
+	router.HandleFunc("/peers", dir.httpPeers)
+	router.HandleFunc("/manifests", dir.httpManifests)
+	router.HandleFunc("/manifests/reload", dir.httpManifestsReload)
+	router.HandleFunc("/stats", dir.httpStats)
+	router.HandleFunc("/ban", dir.httpBans)
+	router.HandleFunc("/ban/{peerId}", dir.httpBan)
+	router.HandleFunc("/unban/{peerId}", dir.httpUnban)
+
+	haddr := fmt.Sprintf("127.0.0.1:%d", port)
+	log.Printf("Serving admin interface at %s", haddr)
+	err := http.ListenAndServe(haddr, router)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func apiError(w http.ResponseWriter, status int, err error) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "Error: %s\n", err.Error())
+}
+
+// GET /id
+// Returns the directory peer id
+func (dir *Directory) httpId(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, dir.ID.Pretty())
+}
+
+// GET /peers
+// Lists the registered peers as json objects, ordered by peer id
+func (dir *Directory) httpPeers(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	dir.mx.Lock()
+	recs := make([]PeerRecord, 0, len(dir.peers))
+	probes := make(map[p2p_peer.ID]ProbeResult)
+	for pid, rec := range dir.peers {
+		if rec.live(now) {
+			recs = append(recs, rec)
+			probe, ok := dir.probes[pid]
+			if ok {
+				probes[pid] = probe
+			}
+		}
+	}
+	dir.mx.Unlock()
+
+	sort.Sort(peerRecords(recs))
+
+	enc := json.NewEncoder(w)
+	for _, rec := range recs {
+		info := AdminPeerInfo{
+			ID:         rec.peer.ID.Pretty(),
+			Addrs:      make([]string, len(rec.peer.Addrs)),
+			Info:       rec.info,
+			Manifest:   dir.mfs.LookupSource(rec.peer.ID),
+			Revocation: rec.revoke,
+			Expires:    rec.expires.Unix(),
+		}
+
+		for x, addr := range rec.peer.Addrs {
+			info.Addrs[x] = addr.String()
+		}
+
+		if rec.publisher != nil {
+			info.Publisher = rec.publisher.Id
+			info.Namespaces = rec.publisher.Namespaces
+		}
+
+		if rec.origin != "" {
+			info.Origin = rec.origin.Pretty()
+		}
+
+		if !rec.seen.IsZero() {
+			info.LastSeen = rec.seen.Unix()
+		}
+
+		probe, ok := probes[rec.peer.ID]
+		if ok {
+			info.Probe = &AdminProbe{
+				Reachable: probe.reachable,
+				Addrs:     make([]string, len(probe.addrs)),
+				Latency:   int64(probe.latency / time.Millisecond),
+				Checked:   probe.checked.Unix(),
+			}
+			for x, addr := range probe.addrs {
+				info.Probe.Addrs[x] = addr.String()
+			}
+		}
+
+		err := enc.Encode(info)
+		if err != nil {
+			log.Printf("Error encoding peer: %s", err.Error())
+			return
+		}
+	}
+}
+
+// GET /manifests
+// Lists the verified manifests in the manifest store
+func (dir *Directory) httpManifests(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	for _, mf := range dir.mfs.Lookup("*") {
+		err := enc.Encode(mf)
+		if err != nil {
+			log.Printf("Error encoding manifest: %s", err.Error())
+			return
+		}
+	}
+}
+
+// POST /manifests/reload
+// Drops all manifests and cached entity keys from the manifest store and
+// verifies the manifests of registered peers again in the background.
+// Returns the number of peers with manifests.
+func (dir *Directory) httpManifestsReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, http.StatusBadRequest, BadMethod)
+		return
+	}
+
+	count := dir.reloadManifests()
+	fmt.Fprintln(w, count)
+}
+
+// GET /stats
+// Returns the number of registered peers, manifests and banned peers, and
+// the request counters by protocol
+func (dir *Directory) httpStats(w http.ResponseWriter, r *http.Request) {
+	var stats AdminStats
+
+	now := time.Now()
+	dir.mx.Lock()
+	for _, rec := range dir.peers {
+		if rec.live(now) {
+			stats.Peers += 1
+		}
+	}
+	stats.Banned = len(dir.bans)
+	dir.mx.Unlock()
+
+	stats.Manifests = len(dir.mfs.Lookup("*"))
+	stats.Requests = dir.counters.snapshot()
+
+	err := json.NewEncoder(w).Encode(stats)
+	if err != nil {
+		log.Printf("Error encoding stats: %s", err.Error())
+	}
+}
+
+// GET /ban
+// Lists the banned peers
+func (dir *Directory) httpBans(w http.ResponseWriter, r *http.Request) {
+	for _, pid := range dir.listBans() {
+		fmt.Fprintln(w, pid)
+	}
+}
+
+// POST /ban/{peerId}
+// Bans a peer; the peer's registration is dropped and its streams are
+// rejected
+func (dir *Directory) httpBan(w http.ResponseWriter, r *http.Request) {
+	dir.httpBanImpl(w, r, dir.banPeer)
+}
+
+// POST /unban/{peerId}
+// Unbans a peer
+func (dir *Directory) httpUnban(w http.ResponseWriter, r *http.Request) {
+	dir.httpBanImpl(w, r, dir.unbanPeer)
+}
+
+func (dir *Directory) httpBanImpl(w http.ResponseWriter, r *http.Request, proc func(p2p_peer.ID) error) {
+	if r.Method != http.MethodPost {
+		apiError(w, http.StatusBadRequest, BadMethod)
+		return
+	}
+
+	vars := mux.Vars(r)
+	pid, err := p2p_peer.IDB58Decode(vars["peerId"])
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = proc(pid)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
@@ -1,71 +1,330 @@
 package main
 
 import (
+	"errors"
 	p2p_host "github.com/libp2p/go-libp2p-host"
 	p2p_peer "github.com/libp2p/go-libp2p-peer"
 	p2p_pstore "github.com/libp2p/go-libp2p-peerstore"
 	mc "github.com/mediachain/concat/mc"
 	pb "github.com/mediachain/concat/proto"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
+var (
+	BadRegistration   = errors.New("Bad registration record")
+	BadMethod         = errors.New("Unsupported method")
+	StaleRegistration = errors.New("Registration older than the current record")
+)
+
+// Registrations expire ttl after the last registration message, so that
+// peers survive brief disconnects; peers re-register every 5 minutes.
+// Registrations are also synced with the configured sync directories, and
+// optionally probed for reachability by the prober.
 type Directory struct {
 	mc.PeerIdentity
-	host  p2p_host.Host
-	peers map[p2p_peer.ID]PeerRecord
-	mx    sync.Mutex
-	mfs   ManifestStore
+	host      p2p_host.Host
+	peers     map[p2p_peer.ID]PeerRecord
+	probes    map[p2p_peer.ID]ProbeResult
+	bans      map[p2p_peer.ID]bool
+	deferred  map[p2p_peer.ID]PeerRecord
+	mx        sync.Mutex
+	counters  RequestCounters
+	policy    DirectoryPolicy
+	mfs       ManifestStore
+	store     *RegistryStore
+	ttl       time.Duration
+	syncPeers []p2p_pstore.PeerInfo
+	prober    p2p_host.Host
 }
 
 type PeerRecord struct {
 	peer      p2p_pstore.PeerInfo
 	publisher *pb.PublisherInfo
+	manifest  []*pb.Manifest
+	revoke    []*pb.ManifestRevocation
+	info      string
+	seen      time.Time // last registration; zero if unknown
+	expires   time.Time
+	origin    p2p_peer.ID // directory the peer registered with; empty if local
+}
+
+func (rec PeerRecord) live(now time.Time) bool {
+	return now.Before(rec.expires)
 }
 
 type ManifestStore interface {
 	Put(src p2p_peer.ID, lst []*pb.Manifest)
+	Revoke(src p2p_peer.ID, lst []*pb.ManifestRevocation)
 	Remove(src p2p_peer.ID)
 	Lookup(entity string) []*pb.Manifest
+	LookupSource(src p2p_peer.ID) []*pb.Manifest
+	Verify(mf *pb.Manifest) ManifestStatus
+	Reset()
 }
 
-func (dir *Directory) registerPeer(rec PeerRecord) {
+type ManifestStatus int
+
+const (
+	ManifestInvalid ManifestStatus = iota
+	ManifestValid
+	ManifestPending // the entity key is being resolved
+)
+
+// registerPeer checks a registration against the policy and registers the
+// peer and its manifests. Registrations whose node manifest is pending
+// verification are deferred and NodeManifestPending is returned.
+func (dir *Directory) registerPeer(rec PeerRecord) error {
 	log.Printf("directory: register %s", rec.peer.ID.Pretty())
+	rec.seen = time.Now()
+	rec.expires = rec.seen.Add(dir.ttl)
+
+	err := dir.checkRegistration(rec.peer.ID, rec.publisher, rec.manifest)
+	switch {
+	case err == NodeManifestPending:
+		dir.deferPeer(rec)
+		return err
+
+	case err != nil:
+		return err
+	}
+
+	return dir.admitPeer(rec)
+}
+
+// admitPeer sets the record of a peer and adds its manifests. Returns
+// NotAuthorized if the peer is banned and StaleRegistration if the peer
+// already has a registration that expires later, or at the same time for
+// synced records; a local registration with the same expiration has its
+// ttl refreshed.
+func (dir *Directory) admitPeer(rec PeerRecord) error {
 	dir.mx.Lock()
-	dir.peers[rec.peer.ID] = rec
+	if dir.bans[rec.peer.ID] {
+		dir.mx.Unlock()
+		return NotAuthorized
+	}
+
+	xrec, ok := dir.peers[rec.peer.ID]
+	switch {
+	case !ok || rec.expires.After(xrec.expires):
+
+	case rec.expires.Equal(xrec.expires) && rec.origin == "":
+		rec.seen = time.Now()
+		rec.expires = rec.seen.Add(dir.ttl)
+
+	default:
+		dir.mx.Unlock()
+		return StaleRegistration
+	}
+
+	dir.updatePeer(rec)
+	dir.putManifests(rec)
 	dir.mx.Unlock()
+
+	err := dir.storePeer(rec)
+	if err != nil {
+		log.Printf("directory: error storing registration for %s: %s", rec.peer.ID.Pretty(), err.Error())
+	}
+
+	return nil
+}
+
+// putManifests adds the manifests and revocations of a registration to the
+// manifest store; must be called with the directory lock held, so that they
+// are removed together with the registration.
+func (dir *Directory) putManifests(rec PeerRecord) {
+	if len(rec.revoke) > 0 {
+		dir.mfs.Revoke(rec.peer.ID, rec.revoke)
+	}
+
+	if len(rec.manifest) > 0 {
+		dir.mfs.Put(rec.peer.ID, rec.manifest)
+	}
 }
 
-func (dir *Directory) unregisterPeer(pid p2p_peer.ID) {
-	log.Printf("directory: unregister %s", pid.Pretty())
+// storePeer persists a registration; if the peer has been banned in the
+// meantime, the registration is deleted again, as the ban may have deleted
+// it before it was stored.
+func (dir *Directory) storePeer(rec PeerRecord) error {
+	err := dir.store.Put(rec)
+	if err != nil {
+		return err
+	}
+
+	if dir.isBanned(rec.peer.ID) {
+		return dir.store.Delete(rec.peer.ID)
+	}
+
+	return nil
+}
+
+// loadPeers loads the stored registrations that have not expired and are
+// still admitted by the policy
+func (dir *Directory) loadPeers() error {
+	recs, err := dir.store.Load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	stale := make([]p2p_peer.ID, 0)
+	count := 0
+	for _, rec := range recs {
+		if !rec.live(now) {
+			stale = append(stale, rec.peer.ID)
+			continue
+		}
+
+		// the ban list and policy may have changed since the registration
+		if dir.isBanned(rec.peer.ID) {
+			err = dir.store.Delete(rec.peer.ID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = dir.checkRegistration(rec.peer.ID, rec.publisher, rec.manifest)
+		switch {
+		case err == NodeManifestPending:
+			dir.deferPeer(rec)
+			continue
+
+		case err != nil:
+			err = dir.store.Delete(rec.peer.ID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		dir.mx.Lock()
+		dir.peers[rec.peer.ID] = rec
+		dir.putManifests(rec)
+		dir.mx.Unlock()
+
+		count += 1
+	}
+
+	log.Printf("directory: loaded %d registrations", count)
+
+	return dir.store.DeleteExpired(stale, now)
+}
+
+func (dir *Directory) expirePeersLoop() {
+	for {
+		time.Sleep(time.Minute)
+		dir.expirePeers()
+		if dir.policy.rate != nil {
+			dir.policy.rate.prune(time.Now())
+		}
+	}
+}
+
+// expirePeers removes registrations whose ttl has run out, both in memory
+// and in the store. The manifests are removed with the directory lock held,
+// so that a peer re-registering concurrently keeps its new manifests; the
+// store only deletes registrations that are still expired.
+func (dir *Directory) expirePeers() {
+	now := time.Now()
+	expired := make([]p2p_peer.ID, 0)
+
 	dir.mx.Lock()
-	delete(dir.peers, pid)
+	for pid, rec := range dir.peers {
+		if !rec.live(now) {
+			log.Printf("directory: expire %s", pid.Pretty())
+			delete(dir.peers, pid)
+			delete(dir.probes, pid)
+			dir.mfs.Remove(pid)
+			expired = append(expired, pid)
+		}
+	}
 	dir.mx.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+
+	err := dir.store.DeleteExpired(expired, now)
+	if err != nil {
+		log.Printf("directory: error deleting registrations: %s", err.Error())
+	}
 }
 
 func (dir *Directory) lookupPeer(pid p2p_peer.ID) (p2p_pstore.PeerInfo, bool) {
 	log.Printf("directory: lookup %s", pid.Pretty())
 	dir.mx.Lock()
 	rec, ok := dir.peers[pid]
+	reachable := dir.isReachable(pid)
+	pinfo := dir.probedPeer(rec)
 	dir.mx.Unlock()
-	return rec.peer, ok
+
+	if ok && !(rec.live(time.Now()) && reachable) {
+		return p2p_pstore.PeerInfo{}, false
+	}
+
+	return pinfo, ok
 }
 
 func (dir *Directory) listPeers(ns string) []string {
 	log.Printf("directory: list %s", ns)
 
+	recs := dir.selectPeers(namespaceFilter(ns))
+	lst := make([]string, len(recs))
+	for x, rec := range recs {
+		lst[x] = rec.peer.ID.Pretty()
+	}
+	return lst
+}
+
+// listPeersVerbose returns a page of the peers in a namespace, ordered by
+// peer id, together with the total number of peers in the namespace.
+func (dir *Directory) listPeersVerbose(ns string, offset, limit int) ([]PeerRecord, int) {
+	log.Printf("directory: listpeers %s %d %d", ns, offset, limit)
+
+	recs := dir.selectPeers(namespaceFilter(ns))
+	sort.Sort(peerRecords(recs))
+
+	total := len(recs)
+	switch {
+	case offset >= total:
+		return nil, total
+	case offset+limit < total:
+		return recs[offset : offset+limit], total
+	default:
+		return recs[offset:], total
+	}
+}
+
+type peerRecords []PeerRecord
+
+func (recs peerRecords) Len() int {
+	return len(recs)
+}
+
+func (recs peerRecords) Less(i, j int) bool {
+	return recs[i].peer.ID < recs[j].peer.ID
+}
+
+func (recs peerRecords) Swap(i, j int) {
+	recs[i], recs[j] = recs[j], recs[i]
+}
+
+func namespaceFilter(ns string) func(PeerRecord) bool {
 	switch {
 	case ns == "":
 		fallthrough
 	case ns == "*":
-		return dir.listPeersFilter(func(PeerRecord) bool {
+		return func(PeerRecord) bool {
 			return true
-		})
+		}
 
 	case strings.HasSuffix(ns, ".*"):
 		pre := ns[:len(ns)-2]
-		return dir.listPeersFilter(func(rec PeerRecord) bool {
+		return func(rec PeerRecord) bool {
 			if rec.publisher == nil {
 				return false
 			}
@@ -77,10 +336,10 @@ func (dir *Directory) listPeers(ns string) []string {
 			}
 
 			return false
-		})
+		}
 
 	default:
-		return dir.listPeersFilter(func(rec PeerRecord) bool {
+		return func(rec PeerRecord) bool {
 			if rec.publisher == nil {
 				return false
 			}
@@ -92,17 +351,21 @@ func (dir *Directory) listPeers(ns string) []string {
 			}
 
 			return false
-		})
+		}
 
 	}
 }
 
-func (dir *Directory) listPeersFilter(filter func(PeerRecord) bool) []string {
+// selectPeers returns the live and reachable peers matching the filter, with
+// the addresses that answered the last probe.
+func (dir *Directory) selectPeers(filter func(PeerRecord) bool) []PeerRecord {
+	now := time.Now()
 	dir.mx.Lock()
-	lst := make([]string, 0, len(dir.peers))
+	lst := make([]PeerRecord, 0, len(dir.peers))
 	for pid, rec := range dir.peers {
-		if filter(rec) {
-			lst = append(lst, pid.Pretty())
+		if rec.live(now) && dir.isReachable(pid) && filter(rec) {
+			rec.peer = dir.probedPeer(rec)
+			lst = append(lst, rec)
 		}
 	}
 	dir.mx.Unlock()
@@ -114,9 +377,10 @@ func (dir *Directory) listNamespaces() []string {
 
 	nsset := make(map[string]bool)
 
+	now := time.Now()
 	dir.mx.Lock()
 	for _, rec := range dir.peers {
-		if rec.publisher != nil {
+		if rec.publisher != nil && rec.live(now) {
 			for _, ns := range rec.publisher.Namespaces {
 				nsset[ns] = true
 			}
@@ -131,3 +395,66 @@ func (dir *Directory) listNamespaces() []string {
 
 	return nslst
 }
+
+// listNamespaceStats summarizes the namespace stats advertised by reachable
+// peers; the peers holding each namespace are ranked by completeness, which
+// is the statement count and then the last update.
+func (dir *Directory) listNamespaceStats() []*pb.NamespaceListing {
+	log.Printf("directory: listns stats")
+
+	nsmap := make(map[string]*pb.NamespaceListing)
+
+	now := time.Now()
+	dir.mx.Lock()
+	for pid, rec := range dir.peers {
+		if rec.publisher == nil || !rec.live(now) || !dir.isReachable(pid) {
+			continue
+		}
+
+		for _, ns := range rec.publisher.Namespaces {
+			_, ok := nsmap[ns]
+			if !ok {
+				nsmap[ns] = &pb.NamespaceListing{Namespace: ns}
+			}
+		}
+
+		for _, stats := range rec.publisher.Stats {
+			lst, ok := nsmap[stats.Namespace]
+			if !ok {
+				continue
+			}
+
+			lst.Peers = append(lst.Peers, &pb.NamespacePeer{pid.Pretty(), stats.Statements, stats.LastUpdate})
+			if stats.Statements > lst.Statements {
+				lst.Statements = stats.Statements
+			}
+			if stats.LastUpdate > lst.LastUpdate {
+				lst.LastUpdate = stats.LastUpdate
+			}
+		}
+	}
+	dir.mx.Unlock()
+
+	nslst := make([]*pb.NamespaceListing, 0, len(nsmap))
+	for _, lst := range nsmap {
+		mc.SortNamespacePeers(lst.Peers)
+		nslst = append(nslst, lst)
+	}
+	sort.Sort(namespaceListings(nslst))
+
+	return nslst
+}
+
+type namespaceListings []*pb.NamespaceListing
+
+func (lst namespaceListings) Len() int {
+	return len(lst)
+}
+
+func (lst namespaceListings) Less(i, j int) bool {
+	return lst[i].Namespace < lst[j].Namespace
+}
+
+func (lst namespaceListings) Swap(i, j int) {
+	lst[i], lst[j] = lst[j], lst[i]
+}
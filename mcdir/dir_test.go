@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRegisterBanned(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	a := makeTestPeerRecord(t, "a", time.Time{})
+	b := makeTestPeerRecord(t, "b", time.Time{})
+	checkError(t, "store.Ban", dir.store.Ban(b.peer.ID, time.Now()))
+	checkError(t, "loadBans", dir.loadBans())
+
+	checkError(t, "register a", dir.registerPeer(a))
+	checkBool(t, "register b", dir.registerPeer(b) == NotAuthorized)
+
+	_, ok := dir.peers[a.peer.ID]
+	checkBool(t, "registered a", ok)
+	_, ok = dir.peers[b.peer.ID]
+	checkBool(t, "registered b", !ok)
+
+	recs := loadTestRegistrations(t, dir.store)
+	_, ok = recs[a.peer.ID]
+	checkBool(t, "stored a", ok)
+	_, ok = recs[b.peer.ID]
+	checkBool(t, "stored b", !ok)
+
+	// a registration stored after the ban deleted the peer is deleted again
+	a.expires = time.Now().Add(time.Hour)
+	dir.mx.Lock()
+	dir.bans[a.peer.ID] = true
+	delete(dir.peers, a.peer.ID)
+	dir.mx.Unlock()
+	checkError(t, "store.Delete", dir.store.Delete(a.peer.ID))
+
+	checkError(t, "storePeer", dir.storePeer(a))
+	recs = loadTestRegistrations(t, dir.store)
+	_, ok = recs[a.peer.ID]
+	checkBool(t, "stored a after ban", !ok)
+
+	// synced registrations of banned peers are rejected too
+	b.origin = makeTestPeerID(t, "dir")
+	b.expires = time.Now().Add(time.Hour)
+	checkBool(t, "merge b", !dir.mergePeer(b))
+	recs = loadTestRegistrations(t, dir.store)
+	checkBool(t, "stored after merge", len(recs) == 0)
+}
+
+func TestAdmitPeer(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	now := time.Now()
+	a := makeTestPeerRecord(t, "a", now.Add(time.Minute))
+	checkError(t, "admit a", dir.admitPeer(a))
+
+	// older records are refused without rejecting the peer
+	old := a
+	old.expires = now
+	checkBool(t, "admit older", dir.admitPeer(old) == StaleRegistration)
+	checkBool(t, "kept a", dir.peers[a.peer.ID].expires.Equal(a.expires))
+
+	// repeated local registrations refresh the ttl
+	checkError(t, "admit equal", dir.admitPeer(a))
+	checkBool(t, "refreshed a", dir.peers[a.peer.ID].expires.After(a.expires))
+
+	// synced records don't
+	b := makeTestPeerRecord(t, "b", now.Add(time.Minute))
+	b.origin = makeTestPeerID(t, "dir")
+	checkError(t, "admit b", dir.admitPeer(b))
+	checkBool(t, "admit equal synced", dir.admitPeer(b) == StaleRegistration)
+	checkBool(t, "kept b", dir.peers[b.peer.ID].expires.Equal(b.expires))
+}
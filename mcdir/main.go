@@ -9,6 +9,8 @@ import (
 	homedir "github.com/mitchellh/go-homedir"
 	"log"
 	"os"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -16,6 +18,16 @@ func main() {
 
 	port := flag.Int("l", 9000, "Listen port")
 	hdir := flag.String("d", "~/.mediachain/mcdir", "Directory home")
+	ttl := flag.Duration("ttl", 15*time.Minute, "Registration TTL; peers re-register every 5 minutes")
+	aport := flag.Int("admin", 0, "Admin interface port [http, localhost]; 0 disables the admin interface")
+	probe := flag.Duration("probe", 0, "Reachability probe interval for registered peers; 0 disables probing")
+	rate := flag.Float64("rate", 0, "Per-peer request rate limit [requests/s]; 0 disables rate limiting")
+	burst := flag.Int("burst", 20, "Per-peer request burst for rate limiting")
+	maxns := flag.Int("max-ns", 1000, "Maximum number of namespaces per registration; 0 for no limit")
+	allow := flag.String("allow", "", "File with the peer ids allowed to register, one per line")
+	deny := flag.String("deny", "", "File with the peer ids denied registration, one per line")
+	entities := flag.String("require-manifest", "", "Comma separated list of entities; peers must present a node manifest signed by one of them to register")
+	syncdirs := flag.String("sync", "", "Comma separated list of directory handles to sync registrations with")
 	ver := flag.Bool("version", false, "print version and exit")
 	flag.Parse()
 
@@ -55,12 +67,103 @@ func main() {
 		log.Fatal(err)
 	}
 
-	dir := &Directory{PeerIdentity: id, host: host, peers: make(map[p2p_peer.ID]PeerRecord), mfs: NewManifestStore()}
+	store, err := OpenRegistryStore(home)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir := &Directory{PeerIdentity: id, host: host, peers: make(map[p2p_peer.ID]PeerRecord), probes: make(map[p2p_peer.ID]ProbeResult), bans: make(map[p2p_peer.ID]bool), deferred: make(map[p2p_peer.ID]PeerRecord), store: store, ttl: *ttl}
+
+	dir.mfs, err = NewManifestStore(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir.policy.maxNS = *maxns
+
+	if *rate > 0 {
+		dir.policy.rate = NewRateLimiter(*rate, *burst)
+	}
+
+	if *allow != "" {
+		dir.policy.allow, err = loadPeerList(*allow)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *deny != "" {
+		dir.policy.deny, err = loadPeerList(*deny)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *entities != "" {
+		dir.policy.entities = make(map[string]bool)
+		for _, entity := range strings.Split(*entities, ",") {
+			err = mc.CheckEntityId(entity)
+			if err != nil {
+				log.Fatalf("Bad entity %s: %s", entity, err.Error())
+			}
+			dir.policy.entities[entity] = true
+		}
+	}
+
+	if *syncdirs != "" {
+		for _, handle := range strings.Split(*syncdirs, ",") {
+			pinfo, err := mc.ParseHandle(handle)
+			if err != nil {
+				log.Fatalf("Bad sync directory %s: %s", handle, err.Error())
+			}
+			dir.syncPeers = append(dir.syncPeers, pinfo)
+		}
+	}
+
+	err = dir.loadBans()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = dir.loadPeers()
+	if err != nil {
+		log.Fatal(err)
+	}
+	go dir.expirePeersLoop()
+
+	if dir.policy.entities != nil {
+		go dir.retryDeferredLoop()
+	}
+
 	host.SetStreamHandler("/mediachain/dir/register", dir.registerHandler)
 	host.SetStreamHandler("/mediachain/dir/lookup", dir.lookupHandler)
 	host.SetStreamHandler("/mediachain/dir/list", dir.listHandler)
 	host.SetStreamHandler("/mediachain/dir/listns", dir.listnsHandler)
 	host.SetStreamHandler("/mediachain/dir/listmf", dir.listmfHandler)
+	host.SetStreamHandler("/mediachain/dir/listpeers", dir.listpeersHandler)
+	host.SetStreamHandler("/mediachain/dir/sync", dir.syncHandler)
+
+	if len(dir.syncPeers) > 0 {
+		go dir.syncPeersLoop()
+	}
+
+	if *probe > 0 {
+		pid, err := mc.NewPeerIdentity()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		dir.prober, err = mc.NewHost(context.Background(), pid, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go dir.probePeersLoop(*probe)
+	}
+
+	if *aport > 0 {
+		go dir.serveAdmin(*aport)
+	}
 
 	for _, addr := range host.Addrs() {
 		if !mc.IsLinkLocalAddr(addr) {
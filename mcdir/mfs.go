@@ -1,7 +1,6 @@
 package main
 
 import (
-	ggproto "github.com/gogo/protobuf/proto"
 	p2p_crypto "github.com/libp2p/go-libp2p-crypto"
 	p2p_peer "github.com/libp2p/go-libp2p-peer"
 	mc "github.com/mediachain/concat/mc"
@@ -10,12 +9,39 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"time"
 )
 
+// Manifests are verified against the key of the signing entity, which is
+// resolved in the background by a bounded pool of workers, so that slow
+// identity providers don't stall registrations. Manifests wait in the
+// pending set until their key is resolved; failed lookups are retried with
+// exponential back-off for as long as there are manifests waiting.
+// Resolved keys are cached for keyTTL.
+// Registration checks verify manifests through the same resolver: manifests
+// whose key is not cached are reported pending while the key is resolved,
+// and the check is repeated by the caller.
+// Revocations are verified the same way; once verified, the revoked
+// manifest is dropped and rejected from then on. Verified revocations are
+// persisted in the revocation store, so that they outlive the registration
+// that carried them and directory restarts.
+// Expired manifests are rejected and dropped from lookups.
 type ManifestStoreImpl struct {
-	mx   sync.Mutex
-	mf   map[string]ManifestRecord
-	keys map[string]p2p_crypto.PubKey
+	mx          sync.Mutex
+	mf          map[string]ManifestRecord
+	revoked     map[string]bool // entity/manifest hash
+	keys        map[string]EntityKey
+	pending     map[string]map[string]ManifestRecord   // key id -> manifest hash -> manifest
+	pendingRevs map[string]map[string]RevocationRecord // key id -> manifest hash -> revocation
+	resolving   map[string]int                         // key id -> failed lookups
+	verifying   map[string]bool                        // key ids requested by Verify
+	jobs        chan string
+	store       RevocationStore // nil if revocations are not persisted
+}
+
+type RevocationStore interface {
+	PutRevocation(key string, rev *pb.ManifestRevocation) error
+	LoadRevocations() (map[string]*pb.ManifestRevocation, error)
 }
 
 type ManifestRecord struct {
@@ -23,56 +49,117 @@ type ManifestRecord struct {
 	src p2p_peer.ID
 }
 
-func NewManifestStore() ManifestStore {
-	return &ManifestStoreImpl{
-		mf:   make(map[string]ManifestRecord),
-		keys: make(map[string]p2p_crypto.PubKey),
+type RevocationRecord struct {
+	rev *pb.ManifestRevocation
+	src p2p_peer.ID
+}
+
+type EntityKey struct {
+	pubk    p2p_crypto.PubKey
+	expires time.Time
+}
+
+const (
+	keyWorkers  = 4
+	keyTTL      = time.Hour
+	keyRetryMin = 10 * time.Second
+	keyRetryMax = 30 * time.Minute
+)
+
+// NewManifestStore creates a manifest store with the revocations persisted
+// in store; store may be nil.
+func NewManifestStore(store RevocationStore) (ManifestStore, error) {
+	mfs := &ManifestStoreImpl{
+		mf:          make(map[string]ManifestRecord),
+		revoked:     make(map[string]bool),
+		keys:        make(map[string]EntityKey),
+		pending:     make(map[string]map[string]ManifestRecord),
+		pendingRevs: make(map[string]map[string]RevocationRecord),
+		resolving:   make(map[string]int),
+		verifying:   make(map[string]bool),
+		jobs:        make(chan string),
+		store:       store,
+	}
+
+	if store != nil {
+		revs, err := store.LoadRevocations()
+		if err != nil {
+			return nil, err
+		}
+
+		for key, _ := range revs {
+			mfs.revoked[key] = true
+		}
+	}
+
+	for x := 0; x < keyWorkers; x++ {
+		go mfs.keyWorker()
 	}
+
+	return mfs, nil
 }
 
 func (mfs *ManifestStoreImpl) Put(src p2p_peer.ID, lst []*pb.Manifest) {
 	mfs.mx.Lock()
-	defer mfs.mx.Unlock()
-
+	resolve := make([]string, 0)
 	for _, mf := range lst {
-		mfs.putManifest(src, mf)
+		kid, ok := mfs.putManifest(src, mf)
+		if ok {
+			resolve = append(resolve, kid)
+		}
+	}
+	mfs.mx.Unlock()
+
+	for _, kid := range resolve {
+		go mfs.queueKey(kid)
 	}
 }
 
-func (mfs *ManifestStoreImpl) putManifest(src p2p_peer.ID, mf *pb.Manifest) {
-	mfx, err := hashManifest(mf)
+// putManifest verifies a manifest if the entity key is known, or adds it to
+// the pending set; returns the key id and true if the key must be resolved.
+func (mfs *ManifestStoreImpl) putManifest(src p2p_peer.ID, mf *pb.Manifest) (string, bool) {
+	mfx, err := mc.HashManifest(mf)
 	if err != nil {
 		log.Printf("Error hashing manifest; wtf: %s", err.Error())
-		return
+		return "", false
 	}
 
 	mfh := mfx.B58String()
 
+	if mfs.revoked[revocationKey(mf.Entity, mfh)] {
+		log.Printf("Ignoring revoked manifest %s", mfh)
+		return "", false
+	}
+
+	if mc.IsManifestExpired(mf, time.Now()) {
+		log.Printf("Ignoring expired manifest %s", mfh)
+		return "", false
+	}
+
 	_, ok := mfs.mf[mfh]
 	if ok {
-		return
+		return "", false
 	}
 
 	kid := mf.Entity + ":" + mf.KeyId
-	pubk, ok := mfs.keys[kid]
+	key, ok := mfs.keys[kid]
+	if ok && time.Now().Before(key.expires) {
+		mfs.checkManifest(mfh, ManifestRecord{mf, src}, key.pubk)
+		return "", false
+	}
 
+	pending, ok := mfs.pending[kid]
 	if !ok {
-		// XXX This can take arbitrary long and might need to be throttled
-		// XXX and in the meantime, it holds the manifest store lock...
-		// XXX The solution to this problem is to fetch keys asynchronously
-		// XXX with back-off/retry logic; impl is complicated though, so for now
-		// XXX damn the torpedoes (but at least release the lock)
-		mfs.mx.Unlock()
-		pubk, err = mc.LookupEntityKey(mf.Entity, mf.KeyId)
-		mfs.mx.Lock()
-		if err != nil {
-			log.Printf("Error looking up entity key %s: %s", kid, err.Error())
-			return
-		}
-		mfs.keys[kid] = pubk
+		pending = make(map[string]ManifestRecord)
+		mfs.pending[kid] = pending
 	}
+	pending[mfh] = ManifestRecord{mf, src}
 
-	ok, err = verifyManifest(mf, pubk)
+	return kid, mfs.scheduleKey(kid)
+}
+
+func (mfs *ManifestStoreImpl) checkManifest(mfh string, mfr ManifestRecord, pubk p2p_crypto.PubKey) {
+	ok, err := verifyManifest(mfr.mf, pubk)
 	switch {
 	case err != nil:
 		log.Printf("Error verifying manifest %s: %s", mfh, err.Error())
@@ -82,8 +169,204 @@ func (mfs *ManifestStoreImpl) putManifest(src p2p_peer.ID, mf *pb.Manifest) {
 
 	default:
 		// yay! a valid manifest.
-		mfs.mf[mfh] = ManifestRecord{mf, src}
+		mfs.mf[mfh] = mfr
+	}
+}
+
+func (mfs *ManifestStoreImpl) Revoke(src p2p_peer.ID, lst []*pb.ManifestRevocation) {
+	mfs.mx.Lock()
+	resolve := make([]string, 0)
+	verified := make(map[string]*pb.ManifestRevocation)
+	for _, rev := range lst {
+		kid, ok := mfs.putRevocation(src, rev, verified)
+		if ok {
+			resolve = append(resolve, kid)
+		}
+	}
+	mfs.mx.Unlock()
+
+	mfs.storeRevocations(verified)
+
+	for _, kid := range resolve {
+		go mfs.queueKey(kid)
+	}
+}
+
+// putRevocation verifies a revocation if the entity key is known, or adds it
+// to the pending set; returns the key id and true if the key must be
+// resolved. Verified revocations are added to verified for storing.
+func (mfs *ManifestStoreImpl) putRevocation(src p2p_peer.ID, rev *pb.ManifestRevocation, verified map[string]*pb.ManifestRevocation) (string, bool) {
+	if rev.Entity == "" || len(rev.Manifest) == 0 {
+		log.Printf("Ignoring bad revocation from %s", src.Pretty())
+		return "", false
+	}
+
+	mfh := multihash.Multihash(rev.Manifest).B58String()
+	if mfs.revoked[revocationKey(rev.Entity, mfh)] {
+		return "", false
+	}
+
+	kid := rev.Entity + ":" + rev.KeyId
+	key, ok := mfs.keys[kid]
+	if ok && time.Now().Before(key.expires) {
+		mfs.checkRevocation(mfh, RevocationRecord{rev, src}, key.pubk, verified)
+		return "", false
+	}
+
+	pending, ok := mfs.pendingRevs[kid]
+	if !ok {
+		pending = make(map[string]RevocationRecord)
+		mfs.pendingRevs[kid] = pending
+	}
+	pending[mfh] = RevocationRecord{rev, src}
+
+	return kid, mfs.scheduleKey(kid)
+}
+
+// checkRevocation verifies a revocation and applies it; verified revocations
+// are added to verified, to be stored once the lock is released.
+func (mfs *ManifestStoreImpl) checkRevocation(mfh string, rr RevocationRecord, pubk p2p_crypto.PubKey, verified map[string]*pb.ManifestRevocation) {
+	ok, err := mc.VerifyManifestRevocation(rr.rev, pubk)
+	switch {
+	case err != nil:
+		log.Printf("Error verifying revocation for %s: %s", mfh, err.Error())
+		return
+
+	case !ok:
+		log.Printf("Error verifying revocation for %s: signature verification failed", mfh)
+		return
+	}
+
+	log.Printf("Revoking manifest %s", mfh)
+	entity := rr.rev.Entity
+	key := revocationKey(entity, mfh)
+	mfs.revoked[key] = true
+	verified[key] = rr.rev
+
+	mfr, ok := mfs.mf[mfh]
+	if ok && mfr.mf.Entity == entity {
+		delete(mfs.mf, mfh)
 	}
+
+	for kid, pending := range mfs.pending {
+		mfr, ok := pending[mfh]
+		if ok && mfr.mf.Entity == entity {
+			delete(pending, mfh)
+		}
+
+		if len(pending) == 0 {
+			delete(mfs.pending, kid)
+		}
+	}
+}
+
+// storeRevocations persists verified revocations; it must be called without
+// the lock held, as the registry store syncs to disk.
+func (mfs *ManifestStoreImpl) storeRevocations(revs map[string]*pb.ManifestRevocation) {
+	if mfs.store == nil {
+		return
+	}
+
+	for key, rev := range revs {
+		err := mfs.store.PutRevocation(key, rev)
+		if err != nil {
+			log.Printf("Error storing revocation %s: %s", key, err.Error())
+		}
+	}
+}
+
+// revocations only apply to manifests signed by the same entity
+func revocationKey(entity, mfh string) string {
+	return entity + "/" + mfh
+}
+
+// scheduleKey marks a key for resolution; returns false if the key is
+// already being resolved.
+func (mfs *ManifestStoreImpl) scheduleKey(kid string) bool {
+	_, ok := mfs.resolving[kid]
+	if ok {
+		return false
+	}
+
+	mfs.resolving[kid] = 0
+	return true
+}
+
+func (mfs *ManifestStoreImpl) queueKey(kid string) {
+	mfs.jobs <- kid
+}
+
+func (mfs *ManifestStoreImpl) keyWorker() {
+	for kid := range mfs.jobs {
+		mfs.resolveKey(kid)
+	}
+}
+
+// resolveKey looks up an entity key and verifies the manifests and
+// revocations waiting for it; failed lookups are retried with back-off.
+func (mfs *ManifestStoreImpl) resolveKey(kid string) {
+	mfs.mx.Lock()
+	if len(mfs.pending[kid]) == 0 && len(mfs.pendingRevs[kid]) == 0 && !mfs.verifying[kid] {
+		// the manifests waiting for the key are gone
+		delete(mfs.resolving, kid)
+		mfs.mx.Unlock()
+		return
+	}
+	// verification requests are renewed by the caller while the key is
+	// being resolved
+	delete(mfs.verifying, kid)
+	mfs.mx.Unlock()
+
+	// key ids are base58 multihashes, entities may contain colons
+	ix := strings.LastIndex(kid, ":")
+	pubk, err := mc.LookupEntityKey(kid[:ix], kid[ix+1:])
+
+	mfs.mx.Lock()
+	if err != nil {
+		fails := mfs.resolving[kid] + 1
+		mfs.resolving[kid] = fails
+		mfs.mx.Unlock()
+
+		backoff := keyRetryBackoff(fails)
+		log.Printf("Error looking up entity key %s: %s; retrying in %s", kid, err.Error(), backoff)
+		time.AfterFunc(backoff, func() {
+			mfs.queueKey(kid)
+		})
+		return
+	}
+
+	delete(mfs.resolving, kid)
+	mfs.keys[kid] = EntityKey{pubk, time.Now().Add(keyTTL)}
+
+	// revocations first, so that revoked manifests are never admitted
+	revs := mfs.pendingRevs[kid]
+	delete(mfs.pendingRevs, kid)
+	verified := make(map[string]*pb.ManifestRevocation)
+	for mfh, rr := range revs {
+		mfs.checkRevocation(mfh, rr, pubk, verified)
+	}
+
+	pending := mfs.pending[kid]
+	delete(mfs.pending, kid)
+	for mfh, mfr := range pending {
+		mfs.checkManifest(mfh, mfr, pubk)
+	}
+	mfs.mx.Unlock()
+
+	mfs.storeRevocations(verified)
+}
+
+func keyRetryBackoff(fails int) time.Duration {
+	backoff := keyRetryMin
+	for x := 1; x < fails && backoff < keyRetryMax; x++ {
+		backoff *= 2
+	}
+
+	if backoff > keyRetryMax {
+		backoff = keyRetryMax
+	}
+
+	return backoff
 }
 
 func (mfs *ManifestStoreImpl) Remove(src p2p_peer.ID) {
@@ -95,6 +378,30 @@ func (mfs *ManifestStoreImpl) Remove(src p2p_peer.ID) {
 			delete(mfs.mf, mfh)
 		}
 	}
+
+	for kid, pending := range mfs.pending {
+		for mfh, mfr := range pending {
+			if mfr.src == src {
+				delete(pending, mfh)
+			}
+		}
+
+		if len(pending) == 0 {
+			delete(mfs.pending, kid)
+		}
+	}
+
+	for kid, pending := range mfs.pendingRevs {
+		for mfh, rr := range pending {
+			if rr.src == src {
+				delete(pending, mfh)
+			}
+		}
+
+		if len(pending) == 0 {
+			delete(mfs.pendingRevs, kid)
+		}
+	}
 }
 
 func (mfs *ManifestStoreImpl) Lookup(entity string) []*pb.Manifest {
@@ -105,51 +412,121 @@ func (mfs *ManifestStoreImpl) Lookup(entity string) []*pb.Manifest {
 	case entity == "":
 		fallthrough
 	case entity == "*":
-		return mfs.lookupManifest(func(*pb.Manifest) bool {
+		return mfs.lookupManifest(func(ManifestRecord) bool {
 			return true
 		})
 
 	case strings.HasSuffix(entity, "*"):
 		pre := entity[:len(entity)-1]
-		return mfs.lookupManifest(func(mf *pb.Manifest) bool {
-			return strings.HasPrefix(mf.Entity, pre)
+		return mfs.lookupManifest(func(mfr ManifestRecord) bool {
+			return strings.HasPrefix(mfr.mf.Entity, pre)
 		})
 
 	default:
-		return mfs.lookupManifest(func(mf *pb.Manifest) bool {
-			return mf.Entity == entity
+		return mfs.lookupManifest(func(mfr ManifestRecord) bool {
+			return mfr.mf.Entity == entity
 		})
 	}
 }
 
-func (mfs *ManifestStoreImpl) lookupManifest(filter func(*pb.Manifest) bool) []*pb.Manifest {
-	res := make([]*pb.Manifest, 0)
-	for _, mfr := range mfs.mf {
-		if filter(mfr.mf) {
-			res = append(res, mfr.mf)
+// Verify checks the signature, expiration and revocation of a manifest with
+// the cached entity key; if the key is not cached, it is queued for
+// resolution and the manifest is reported pending.
+func (mfs *ManifestStoreImpl) Verify(mf *pb.Manifest) ManifestStatus {
+	mfx, err := mc.HashManifest(mf)
+	if err != nil {
+		log.Printf("Error hashing manifest; wtf: %s", err.Error())
+		return ManifestInvalid
+	}
+
+	if mc.IsManifestExpired(mf, time.Now()) {
+		return ManifestInvalid
+	}
+
+	kid := mf.Entity + ":" + mf.KeyId
+
+	mfs.mx.Lock()
+	if mfs.revoked[revocationKey(mf.Entity, mfx.B58String())] {
+		mfs.mx.Unlock()
+		return ManifestInvalid
+	}
+
+	key, ok := mfs.keys[kid]
+	if !ok || !time.Now().Before(key.expires) {
+		mfs.verifying[kid] = true
+		resolve := mfs.scheduleKey(kid)
+		mfs.mx.Unlock()
+
+		if resolve {
+			go mfs.queueKey(kid)
 		}
+		return ManifestPending
 	}
-	return res
-}
+	mfs.mx.Unlock()
 
-func hashManifest(mf *pb.Manifest) (multihash.Multihash, error) {
-	bytes, err := ggproto.Marshal(mf)
-	if err != nil {
-		return nil, err
+	ok, err = verifyManifest(mf, key.pubk)
+	switch {
+	case err != nil:
+		log.Printf("Error verifying manifest: %s", err.Error())
+		return ManifestInvalid
+
+	case !ok:
+		return ManifestInvalid
+
+	default:
+		return ManifestValid
 	}
+}
 
-	return mc.Hash(bytes), nil
+// Reset drops all manifests and revocations pending verification, verified
+// manifests, cached entity keys and key resolution state; verified
+// revocations stay in effect.
+func (mfs *ManifestStoreImpl) Reset() {
+	mfs.mx.Lock()
+	defer mfs.mx.Unlock()
+
+	mfs.mf = make(map[string]ManifestRecord)
+	mfs.keys = make(map[string]EntityKey)
+	mfs.pending = make(map[string]map[string]ManifestRecord)
+	mfs.pendingRevs = make(map[string]map[string]RevocationRecord)
+	mfs.resolving = make(map[string]int)
+	mfs.verifying = make(map[string]bool)
 }
 
-func verifyManifest(mf *pb.Manifest, pubk p2p_crypto.PubKey) (bool, error) {
-	sig := mf.Signature
-	mf.Signature = nil
-	bytes, err := ggproto.Marshal(mf)
-	mf.Signature = sig
+// LookupSource returns the verified manifests of a peer
+func (mfs *ManifestStoreImpl) LookupSource(src p2p_peer.ID) []*pb.Manifest {
+	mfs.mx.Lock()
+	defer mfs.mx.Unlock()
 
-	if err != nil {
-		return false, err
+	return mfs.lookupManifest(func(mfr ManifestRecord) bool {
+		return mfr.src == src
+	})
+}
+
+// lookupManifest returns the manifests matching the filter; expired
+// manifests are dropped.
+func (mfs *ManifestStoreImpl) lookupManifest(filter func(ManifestRecord) bool) []*pb.Manifest {
+	now := time.Now()
+	res := make([]*pb.Manifest, 0)
+	for mfh, mfr := range mfs.mf {
+		if mc.IsManifestExpired(mfr.mf, now) {
+			delete(mfs.mf, mfh)
+			continue
+		}
+
+		if filter(mfr) {
+			res = append(res, mfr.mf)
+		}
+	}
+	return res
+}
+
+// verifyManifest checks the signature of a manifest; expired manifests fail
+// verification.
+func verifyManifest(mf *pb.Manifest, pubk p2p_crypto.PubKey) (bool, error) {
+	if mc.IsManifestExpired(mf, time.Now()) {
+		return false, mc.ManifestExpired
 	}
 
-	return pubk.Verify(bytes, sig)
+	return mc.VerifyManifest(mf, pubk)
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	ggproto "github.com/gogo/protobuf/proto"
+	p2p_crypto "github.com/libp2p/go-libp2p-crypto"
+	mc "github.com/mediachain/concat/mc"
+	pb "github.com/mediachain/concat/proto"
+	"os"
+	"testing"
+	"time"
+)
+
+// the test entity has an unknown id provider, so that key lookups fail
+// without calling out to an identity provider; keys are added to the
+// cache by the tests.
+const testEntity = "test:mediachain"
+
+func makeTestKey(t *testing.T) (p2p_crypto.PrivKey, p2p_crypto.PubKey) {
+	privk, pubk, err := mc.GenerateECCKeyPair()
+	checkError(t, "GenerateECCKeyPair", err)
+	return privk, pubk
+}
+
+func makeTestManifest(t *testing.T, privk p2p_crypto.PrivKey, peer string, expires int64) *pb.Manifest {
+	mf := &pb.Manifest{
+		Entity:    testEntity,
+		KeyId:     "key",
+		Body:      &pb.ManifestBody{&pb.ManifestBody_Node{&pb.NodeManifest{Peer: peer}}},
+		Timestamp: time.Now().Unix(),
+		Expires:   expires,
+	}
+
+	data, err := ggproto.Marshal(mf)
+	checkError(t, "ggproto.Marshal", err)
+
+	mf.Signature, err = privk.Sign(data)
+	checkError(t, "privk.Sign", err)
+
+	return mf
+}
+
+func cacheTestKey(mfs *ManifestStoreImpl, pubk p2p_crypto.PubKey) {
+	mfs.mx.Lock()
+	mfs.keys[testEntity+":key"] = EntityKey{pubk, time.Now().Add(keyTTL)}
+	mfs.mx.Unlock()
+}
+
+func TestManifestVerify(t *testing.T) {
+	xmfs, err := NewManifestStore(nil)
+	checkError(t, "NewManifestStore", err)
+	mfs := xmfs.(*ManifestStoreImpl)
+	privk, pubk := makeTestKey(t)
+	_, xpubk := makeTestKey(t)
+
+	kid := testEntity + ":key"
+	mf := makeTestManifest(t, privk, "a", 0)
+
+	// uncached keys are resolved in the background
+	checkBool(t, "verify uncached", mfs.Verify(mf) == ManifestPending)
+
+	// the lookup fails and is retried with back-off
+	fails := 0
+	for x := 0; x < 100 && fails == 0; x++ {
+		time.Sleep(10 * time.Millisecond)
+		mfs.mx.Lock()
+		fails = mfs.resolving[kid]
+		mfs.mx.Unlock()
+	}
+	checkBool(t, "failed lookup", fails == 1)
+
+	cacheTestKey(mfs, pubk)
+	checkBool(t, "verify cached", mfs.Verify(mf) == ManifestValid)
+
+	expired := makeTestManifest(t, privk, "a", time.Now().Add(-time.Minute).Unix())
+	checkBool(t, "verify expired", mfs.Verify(expired) == ManifestInvalid)
+
+	forged := makeTestManifest(t, privk, "a", 0)
+	forged.Body.GetNode().Peer = "b"
+	checkBool(t, "verify forged", mfs.Verify(forged) == ManifestInvalid)
+
+	mfx, err := mc.HashManifest(mf)
+	checkError(t, "HashManifest", err)
+	mfs.mx.Lock()
+	mfs.revoked[revocationKey(mf.Entity, mfx.B58String())] = true
+	mfs.mx.Unlock()
+	checkBool(t, "verify revoked", mfs.Verify(mf) == ManifestInvalid)
+
+	// reset drops the cached keys and the resolution state
+	mfs.Reset()
+	mfs.mx.Lock()
+	nresolving := len(mfs.resolving)
+	nkeys := len(mfs.keys)
+	mfs.mx.Unlock()
+	checkBool(t, "reset resolving", nresolving == 0)
+	checkBool(t, "reset keys", nkeys == 0)
+
+	other := makeTestManifest(t, privk, "b", 0)
+	checkBool(t, "verify after reset", mfs.Verify(other) == ManifestPending)
+
+	cacheTestKey(mfs, xpubk)
+	checkBool(t, "verify wrong key", mfs.Verify(other) == ManifestInvalid)
+}
+
+func makeTestRevocation(t *testing.T, privk p2p_crypto.PrivKey, mf *pb.Manifest) *pb.ManifestRevocation {
+	mfx, err := mc.HashManifest(mf)
+	checkError(t, "HashManifest", err)
+
+	rev := &pb.ManifestRevocation{
+		Entity:    mf.Entity,
+		KeyId:     mf.KeyId,
+		Manifest:  []byte(mfx),
+		Timestamp: time.Now().Unix(),
+	}
+
+	data, err := ggproto.Marshal(rev)
+	checkError(t, "ggproto.Marshal", err)
+
+	rev.Signature, err = privk.Sign(data)
+	checkError(t, "privk.Sign", err)
+
+	return rev
+}
+
+func TestManifestRevocationStore(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	mfs := dir.mfs.(*ManifestStoreImpl)
+	privk, pubk := makeTestKey(t)
+	cacheTestKey(mfs, pubk)
+
+	a := makeTestPeerID(t, "a")
+	b := makeTestPeerID(t, "b")
+	mf := makeTestManifest(t, privk, a.Pretty(), 0)
+	xmf := makeTestManifest(t, privk, b.Pretty(), 0)
+	mfs.Put(a, []*pb.Manifest{mf})
+	mfs.Put(b, []*pb.Manifest{xmf})
+	checkBool(t, "put", len(mfs.Lookup(testEntity)) == 2)
+
+	// the revocation is carried by another peer
+	mfs.Revoke(b, []*pb.ManifestRevocation{makeTestRevocation(t, privk, mf)})
+	checkBool(t, "revoke", len(mfs.LookupSource(a)) == 0)
+	checkBool(t, "revoke other", len(mfs.LookupSource(b)) == 1)
+
+	revs, err := dir.store.LoadRevocations()
+	checkError(t, "LoadRevocations", err)
+	checkBool(t, "stored revocations", len(revs) == 1)
+
+	// the revocation outlives the registration that carried it and
+	// applies after a restart
+	mfs.Remove(b)
+	xmfs, err := NewManifestStore(dir.store)
+	checkError(t, "NewManifestStore", err)
+	mfs = xmfs.(*ManifestStoreImpl)
+	cacheTestKey(mfs, pubk)
+
+	checkBool(t, "verify revoked", mfs.Verify(mf) == ManifestInvalid)
+	checkBool(t, "verify other", mfs.Verify(xmf) == ManifestValid)
+	mfs.Put(a, []*pb.Manifest{mf})
+	checkBool(t, "put revoked", len(mfs.LookupSource(a)) == 0)
+}
+
+func TestReloadManifests(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	mfs := dir.mfs.(*ManifestStoreImpl)
+	privk, pubk := makeTestKey(t)
+	cacheTestKey(mfs, pubk)
+
+	now := time.Now()
+	a := makeTestPeerRecord(t, "a", now.Add(time.Hour))
+	a.manifest = []*pb.Manifest{makeTestManifest(t, privk, a.peer.ID.Pretty(), 0)}
+	b := makeTestPeerRecord(t, "b", now.Add(-time.Hour))
+	b.manifest = []*pb.Manifest{makeTestManifest(t, privk, b.peer.ID.Pretty(), 0)}
+	for _, rec := range []PeerRecord{a, b} {
+		dir.peers[rec.peer.ID] = rec
+		dir.putManifests(rec)
+	}
+	checkBool(t, "put", len(mfs.Lookup(testEntity)) == 2)
+
+	// the cached keys are dropped, so the manifests of live peers wait for
+	// the key again
+	checkBool(t, "reload", dir.reloadManifests() == 1)
+	checkBool(t, "reload verified", len(mfs.Lookup(testEntity)) == 0)
+
+	mfs.mx.Lock()
+	pending := mfs.pending[testEntity+":key"]
+	npending := len(pending)
+	for _, mfr := range pending {
+		checkBool(t, "reload source", mfr.src == a.peer.ID)
+	}
+	mfs.mx.Unlock()
+	checkBool(t, "reload pending", npending == 1)
+}
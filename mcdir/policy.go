@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	p2p_net "github.com/libp2p/go-libp2p-net"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	NotAuthorized       = errors.New("Registration not authorized")
+	TooManyNamespaces   = errors.New("Too many namespaces")
+	NoNodeManifest      = errors.New("No valid node manifest from an allowed entity")
+	NodeManifestPending = errors.New("Node manifest pending verification")
+)
+
+// Access control
+// Requests are rate limited per peer with a token bucket; peers exceeding
+// the rate have their streams closed. Registrations are checked against
+// the allow and deny lists and the namespace cap, and when a set of
+// entities is configured, peers must present a valid node manifest for
+// themselves, signed by one of the entities. The manifests are verified
+// without blocking the registration; while the entity key is being
+// resolved, the registration is deferred and checked again every
+// deferRetry, until it is admitted, rejected or expires.
+// Synced registrations are subject to the same checks.
+type DirectoryPolicy struct {
+	rate     *RateLimiter         // nil if unlimited
+	maxNS    int                  // 0 if unlimited
+	allow    map[p2p_peer.ID]bool // nil if all peers are allowed
+	deny     map[p2p_peer.ID]bool
+	entities map[string]bool // nil if manifests are not required
+}
+
+type RateLimiter struct {
+	mx      sync.Mutex
+	rate    float64 // tokens/s
+	burst   float64
+	buckets map[p2p_peer.ID]*rateBucket
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[p2p_peer.ID]*rateBucket),
+	}
+}
+
+func (rl *RateLimiter) allow(pid p2p_peer.ID, now time.Time) bool {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	b, ok := rl.buckets[pid]
+	if !ok {
+		b = &rateBucket{tokens: rl.burst, last: now}
+		rl.buckets[pid] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rl.rate
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens -= 1
+	return true
+}
+
+// prune drops the buckets that have refilled
+func (rl *RateLimiter) prune(now time.Time) {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	for pid, b := range rl.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.buckets, pid)
+		}
+	}
+}
+
+// admitRequest counts a request in a protocol stream, subject to the rate
+// limit
+func (dir *Directory) admitRequest(s p2p_net.Stream, pid p2p_peer.ID) bool {
+	if dir.policy.rate != nil && !dir.policy.rate.allow(pid, time.Now()) {
+		log.Printf("directory: rate limiting %s", pid.Pretty())
+		dir.counters.inc("ratelimited")
+		return false
+	}
+
+	dir.counters.inc(string(s.Protocol()))
+	return true
+}
+
+// checkRegistration checks a registration against the policy
+func (dir *Directory) checkRegistration(pid p2p_peer.ID, pub *pb.PublisherInfo, mfs []*pb.Manifest) error {
+	policy := &dir.policy
+
+	if policy.deny[pid] || (policy.allow != nil && !policy.allow[pid]) {
+		return NotAuthorized
+	}
+
+	if pub != nil && policy.maxNS > 0 &&
+		(len(pub.Namespaces) > policy.maxNS || len(pub.Stats) > policy.maxNS) {
+		return TooManyNamespaces
+	}
+
+	if policy.entities != nil {
+		switch dir.checkNodeManifest(pid, mfs) {
+		case ManifestPending:
+			return NodeManifestPending
+
+		case ManifestInvalid:
+			return NoNodeManifest
+		}
+	}
+
+	return nil
+}
+
+// checkNodeManifest looks for a valid node manifest for the peer; the
+// result is pending if no manifest is valid yet, but some are waiting for
+// their entity key.
+func (dir *Directory) checkNodeManifest(pid p2p_peer.ID, mfs []*pb.Manifest) ManifestStatus {
+	res := ManifestInvalid
+	for _, mf := range mfs {
+		if !dir.policy.entities[mf.Entity] || mf.Body == nil {
+			continue
+		}
+
+		nmf := mf.Body.GetNode()
+		if nmf == nil || nmf.Peer != pid.Pretty() {
+			continue
+		}
+
+		switch dir.mfs.Verify(mf) {
+		case ManifestValid:
+			return ManifestValid
+
+		case ManifestPending:
+			res = ManifestPending
+		}
+	}
+
+	return res
+}
+
+const deferRetry = 10 * time.Second
+
+// deferPeer holds a registration until its node manifest is verified;
+// a later registration from the same peer replaces it.
+func (dir *Directory) deferPeer(rec PeerRecord) {
+	dir.mx.Lock()
+	dir.deferred[rec.peer.ID] = rec
+	dir.mx.Unlock()
+}
+
+func (dir *Directory) retryDeferredLoop() {
+	for {
+		time.Sleep(deferRetry)
+		dir.retryDeferred()
+	}
+}
+
+// retryDeferred checks the deferred registrations again, admitting those
+// with a verified node manifest; rejected registrations are also deleted
+// from the store, unless the peer has registered again since.
+func (dir *Directory) retryDeferred() {
+	now := time.Now()
+	dir.mx.Lock()
+	recs := make([]PeerRecord, 0, len(dir.deferred))
+	for pid, rec := range dir.deferred {
+		delete(dir.deferred, pid)
+		if rec.live(now) {
+			recs = append(recs, rec)
+		}
+	}
+	dir.mx.Unlock()
+
+	for _, rec := range recs {
+		pid := rec.peer.ID
+		err := dir.checkRegistration(pid, rec.publisher, rec.manifest)
+		switch {
+		case err == NodeManifestPending:
+			dir.mx.Lock()
+			_, ok := dir.deferred[pid]
+			if !ok {
+				dir.deferred[pid] = rec
+			}
+			dir.mx.Unlock()
+
+		case err != nil:
+			log.Printf("directory: rejecting deferred registration from %s: %s", pid.Pretty(), err.Error())
+			err = dir.store.DeleteExpired([]p2p_peer.ID{pid}, rec.expires)
+			if err != nil {
+				log.Printf("directory: error deleting registration for %s: %s", pid.Pretty(), err.Error())
+			}
+
+		default:
+			if dir.admitPeer(rec) == nil {
+				log.Printf("directory: admitted deferred registration from %s", pid.Pretty())
+			}
+		}
+	}
+}
+
+// loadPeerList loads a list of peer ids from a file, one per line;
+// empty lines and lines starting with # are ignored.
+func loadPeerList(fpath string) (map[p2p_peer.ID]bool, error) {
+	fd, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	pids := make(map[p2p_peer.ID]bool)
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pid, err := p2p_peer.IDB58Decode(line)
+		if err != nil {
+			return nil, err
+		}
+
+		pids[pid] = true
+	}
+
+	return pids, scanner.Err()
+}
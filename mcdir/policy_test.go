@@ -0,0 +1,122 @@
+package main
+
+import (
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	pb "github.com/mediachain/concat/proto"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(1, 2)
+	a := makeTestPeerID(t, "a")
+	b := makeTestPeerID(t, "b")
+	now := time.Now()
+
+	checkBool(t, "burst 1", rl.allow(a, now))
+	checkBool(t, "burst 2", rl.allow(a, now))
+	checkBool(t, "over burst", !rl.allow(a, now))
+	checkBool(t, "other peer", rl.allow(b, now))
+
+	now = now.Add(time.Second)
+	checkBool(t, "refill", rl.allow(a, now))
+	checkBool(t, "over rate", !rl.allow(a, now))
+
+	// the bucket of a has not refilled yet
+	rl.prune(now.Add(time.Second))
+	_, ok := rl.buckets[a]
+	checkBool(t, "prune keeps a", ok)
+	_, ok = rl.buckets[b]
+	checkBool(t, "prune drops b", !ok)
+
+	rl.prune(now.Add(2 * time.Second))
+	checkBool(t, "prune drops a", len(rl.buckets) == 0)
+}
+
+func TestRegistrationPolicy(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	a := makeTestPeerID(t, "a")
+	b := makeTestPeerID(t, "b")
+	c := makeTestPeerID(t, "c")
+	pub := &pb.PublisherInfo{Id: "pub", Namespaces: []string{"foo.a", "foo.b"}}
+
+	checkError(t, "open policy", dir.checkRegistration(a, pub, nil))
+
+	dir.policy.deny = map[p2p_peer.ID]bool{b: true}
+	checkError(t, "deny a", dir.checkRegistration(a, pub, nil))
+	checkBool(t, "deny b", dir.checkRegistration(b, pub, nil) == NotAuthorized)
+
+	dir.policy.allow = map[p2p_peer.ID]bool{a: true, b: true}
+	checkError(t, "allow a", dir.checkRegistration(a, pub, nil))
+	checkBool(t, "allow b", dir.checkRegistration(b, pub, nil) == NotAuthorized)
+	checkBool(t, "allow c", dir.checkRegistration(c, pub, nil) == NotAuthorized)
+
+	dir.policy.maxNS = 2
+	checkError(t, "namespace cap", dir.checkRegistration(a, pub, nil))
+	checkError(t, "namespace cap without publisher", dir.checkRegistration(a, nil, nil))
+
+	pub.Namespaces = append(pub.Namespaces, "foo.c")
+	checkBool(t, "too many namespaces", dir.checkRegistration(a, pub, nil) == TooManyNamespaces)
+
+	pub.Namespaces = pub.Namespaces[:1]
+	pub.Stats = []*pb.NamespaceStats{{Namespace: "foo.a"}, {Namespace: "foo.b"}, {Namespace: "foo.c"}}
+	checkBool(t, "too many stats", dir.checkRegistration(a, pub, nil) == TooManyNamespaces)
+
+	// registrations rejected by the policy are not stored
+	rec := makeTestPeerRecord(t, "a", time.Time{})
+	rec.publisher = pub
+	checkBool(t, "register rejected", dir.registerPeer(rec) == TooManyNamespaces)
+	_, ok := dir.peers[a]
+	checkBool(t, "rejected peer", !ok)
+	checkBool(t, "rejected registration", len(loadTestRegistrations(t, dir.store)) == 0)
+}
+
+func TestRegistrationManifest(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	dir.policy.entities = map[string]bool{testEntity: true}
+	mfs := dir.mfs.(*ManifestStoreImpl)
+	privk, pubk := makeTestKey(t)
+	xprivk, _ := makeTestKey(t)
+
+	a := makeTestPeerRecord(t, "a", time.Time{})
+	a.manifest = []*pb.Manifest{makeTestManifest(t, privk, a.peer.ID.Pretty(), 0)}
+	b := makeTestPeerRecord(t, "b", time.Time{})
+	b.manifest = []*pb.Manifest{makeTestManifest(t, privk, a.peer.ID.Pretty(), 0)}
+	c := makeTestPeerRecord(t, "c", time.Time{})
+	c.manifest = []*pb.Manifest{makeTestManifest(t, xprivk, c.peer.ID.Pretty(), 0)}
+
+	checkBool(t, "register without manifest", dir.registerPeer(makeTestPeerRecord(t, "x", time.Time{})) == NoNodeManifest)
+	checkBool(t, "register foreign manifest", dir.registerPeer(b) == NoNodeManifest)
+
+	// registrations are deferred until the entity key is resolved
+	checkBool(t, "register a", dir.registerPeer(a) == NodeManifestPending)
+	checkBool(t, "register c", dir.registerPeer(c) == NodeManifestPending)
+	checkBool(t, "deferred", len(dir.deferred) == 2 && len(dir.peers) == 0)
+
+	dir.retryDeferred()
+	checkBool(t, "still deferred", len(dir.deferred) == 2 && len(dir.peers) == 0)
+
+	cacheTestKey(mfs, pubk)
+	dir.retryDeferred()
+	checkBool(t, "retried", len(dir.deferred) == 0)
+
+	_, ok := dir.peers[a.peer.ID]
+	checkBool(t, "admitted a", ok)
+	_, ok = dir.peers[c.peer.ID]
+	checkBool(t, "rejected c", !ok)
+	checkBool(t, "manifests a", len(dir.mfs.LookupSource(a.peer.ID)) == 1)
+
+	recs := loadTestRegistrations(t, dir.store)
+	_, ok = recs[a.peer.ID]
+	checkBool(t, "stored a", ok && len(recs) == 1)
+
+	// with the key cached, registrations are admitted right away
+	checkError(t, "register a again", dir.registerPeer(a))
+}
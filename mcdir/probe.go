@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	ggio "github.com/gogo/protobuf/io"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	p2p_pstore "github.com/libp2p/go-libp2p-peerstore"
+	p2p_swarm "github.com/libp2p/go-libp2p-swarm"
+	mc "github.com/mediachain/concat/mc"
+	pb "github.com/mediachain/concat/proto"
+	multiaddr "github.com/multiformats/go-multiaddr"
+	"log"
+	"sync"
+	"time"
+)
+
+// Liveness probing
+// Peers register whatever addresses they believe to be public, which is not
+// necessarily true for nodes behind broken NATs. When probing is enabled,
+// the directory periodically dials registered peers at each of their
+// registered addresses and pings them with /mediachain/node/ping. Lookup and
+// list responses only include the addresses that answered the last probe,
+// and peers that fail at every address are omitted until they pass a probe
+// or re-register with different addresses; peers that have not been probed
+// yet are considered reachable at all their addresses.
+// Probes are made from a separate host with an ephemeral identity, as the
+// directory host is usually already connected to the peer through its
+// registration stream.
+type ProbeResult struct {
+	reachable bool
+	addrs     []multiaddr.Multiaddr // addresses that answered
+	latency   time.Duration         // best round trip time
+	checked   time.Time
+}
+
+const (
+	probeTimeout = 30 * time.Second
+	probeWorkers = 16
+)
+
+func (dir *Directory) probePeersLoop(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		dir.probePeers()
+	}
+}
+
+func (dir *Directory) probePeers() {
+	now := time.Now()
+	dir.mx.Lock()
+	pinfos := make([]p2p_pstore.PeerInfo, 0, len(dir.peers))
+	for _, rec := range dir.peers {
+		if rec.live(now) {
+			pinfos = append(pinfos, rec.peer)
+		}
+	}
+	dir.mx.Unlock()
+
+	ch := make(chan p2p_pstore.PeerInfo)
+	var wg sync.WaitGroup
+	for x := 0; x < probeWorkers; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pinfo := range ch {
+				dir.probePeer(pinfo)
+			}
+		}()
+	}
+
+	for _, pinfo := range pinfos {
+		ch <- pinfo
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// probePeer pings the peer at each of its addresses separately, so that
+// dead addresses are detected even if the peer answers at another one.
+func (dir *Directory) probePeer(pinfo p2p_pstore.PeerInfo) {
+	res := ProbeResult{addrs: make([]multiaddr.Multiaddr, 0, len(pinfo.Addrs))}
+	for _, addr := range pinfo.Addrs {
+		latency, err := dir.pingPeer(p2p_pstore.PeerInfo{ID: pinfo.ID, Addrs: []multiaddr.Multiaddr{addr}})
+		if err != nil {
+			log.Printf("directory/probe: %s is unreachable at %s: %s", pinfo.ID.Pretty(), addr.String(), err.Error())
+			continue
+		}
+
+		if len(res.addrs) == 0 || latency < res.latency {
+			res.latency = latency
+		}
+		res.addrs = append(res.addrs, addr)
+	}
+	res.reachable = len(res.addrs) > 0
+	res.checked = time.Now()
+
+	if !res.reachable {
+		log.Printf("directory/probe: %s is unreachable", pinfo.ID.Pretty())
+	}
+
+	dir.mx.Lock()
+	rec, ok := dir.peers[pinfo.ID]
+	// discard the result if the peer has expired or changed addresses
+	// while being probed
+	if ok && sameAddrs(rec.peer, pinfo) {
+		dir.probes[pinfo.ID] = res
+	}
+	dir.mx.Unlock()
+}
+
+// pingPeer dials the peer at the given addresses and pings it; returns the
+// round trip time of the ping.
+func (dir *Directory) pingPeer(pinfo p2p_pstore.PeerInfo) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	defer dir.resetProbe(pinfo.ID)
+
+	err := dir.prober.Connect(ctx, pinfo)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := dir.prober.NewStream(ctx, pinfo.ID, "/mediachain/node/ping")
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	var ping pb.Ping
+	var pong pb.Pong
+
+	w := ggio.NewDelimitedWriter(s)
+	r := ggio.NewDelimitedReader(s, mc.MaxMessageSize)
+
+	start := time.Now()
+	err = w.WriteMsg(&ping)
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.ReadMsg(&pong)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
+
+// resetProbe closes the prober connection to a peer and forgets its
+// addresses and dial backoff, so that the next probe dials again and only
+// at the addresses being probed.
+func (dir *Directory) resetProbe(pid p2p_peer.ID) {
+	dir.prober.Network().ClosePeer(pid)
+	dir.prober.Peerstore().ClearAddrs(pid)
+
+	swarm, ok := dir.prober.Network().(*p2p_swarm.Network)
+	if ok {
+		swarm.Swarm().Backoff().Clear(pid)
+	}
+}
+
+// isReachable checks the last probe result of a peer; must be called with
+// the directory lock held.
+func (dir *Directory) isReachable(pid p2p_peer.ID) bool {
+	res, ok := dir.probes[pid]
+	return !ok || res.reachable
+}
+
+// probedPeer returns the peer info of a record with the addresses that
+// answered the last probe; must be called with the directory lock held.
+func (dir *Directory) probedPeer(rec PeerRecord) p2p_pstore.PeerInfo {
+	res, ok := dir.probes[rec.peer.ID]
+	if !ok || !res.reachable {
+		return rec.peer
+	}
+
+	return p2p_pstore.PeerInfo{ID: rec.peer.ID, Addrs: res.addrs}
+}
+
+// probeLatency returns the best round trip time of the last probe of a peer,
+// if it was reachable.
+func (dir *Directory) probeLatency(pid p2p_peer.ID) (time.Duration, bool) {
+	dir.mx.Lock()
+	res, ok := dir.probes[pid]
+	dir.mx.Unlock()
+
+	return res.latency, ok && res.reachable
+}
+
+// updatePeer sets the record of a peer, discarding the last probe result
+// if the addresses have changed; must be called with the directory lock
+// held.
+func (dir *Directory) updatePeer(rec PeerRecord) {
+	xrec, ok := dir.peers[rec.peer.ID]
+	if ok && !sameAddrs(xrec.peer, rec.peer) {
+		delete(dir.probes, rec.peer.ID)
+	}
+	dir.peers[rec.peer.ID] = rec
+}
+
+func sameAddrs(a, b p2p_pstore.PeerInfo) bool {
+	if len(a.Addrs) != len(b.Addrs) {
+		return false
+	}
+
+	for x, addr := range a.Addrs {
+		if !addr.Equal(b.Addrs[x]) {
+			return false
+		}
+	}
+
+	return true
+}
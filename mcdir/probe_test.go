@@ -0,0 +1,69 @@
+package main
+
+import (
+	multiaddr "github.com/multiformats/go-multiaddr"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProbeFilter(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	alt, err := multiaddr.NewMultiaddr("/ip4/10.0.0.1/tcp/9001")
+	checkError(t, "NewMultiaddr", err)
+
+	expires := time.Now().Add(time.Hour)
+	a := makeTestPeerRecord(t, "a", expires)
+	a.peer.Addrs = append(a.peer.Addrs, alt)
+	b := makeTestPeerRecord(t, "b", expires)
+	c := makeTestPeerRecord(t, "c", expires)
+	for _, rec := range []PeerRecord{a, b, c} {
+		dir.peers[rec.peer.ID] = rec
+	}
+
+	// a answers at its second address only, b at none; c is not probed
+	dir.probes[a.peer.ID] = ProbeResult{reachable: true, addrs: a.peer.Addrs[1:], latency: 42 * time.Millisecond, checked: time.Now()}
+	dir.probes[b.peer.ID] = ProbeResult{reachable: false, checked: time.Now()}
+
+	pinfo, ok := dir.lookupPeer(a.peer.ID)
+	checkBool(t, "lookup a", ok)
+	checkBool(t, "lookup a addrs", len(pinfo.Addrs) == 1 && pinfo.Addrs[0].Equal(alt))
+
+	_, ok = dir.lookupPeer(b.peer.ID)
+	checkBool(t, "lookup b", !ok)
+
+	pinfo, ok = dir.lookupPeer(c.peer.ID)
+	checkBool(t, "lookup c", ok && sameAddrs(pinfo, c.peer))
+
+	lst := dir.listPeers("")
+	checkBool(t, "list", len(lst) == 2)
+
+	recs, total := dir.listPeersVerbose("", 0, 10)
+	checkBool(t, "listpeers total", total == 2 && len(recs) == 2)
+	for _, rec := range recs {
+		listing := dir.peerListing(rec)
+		switch rec.peer.ID {
+		case a.peer.ID:
+			checkBool(t, "listpeers a addrs", len(listing.Info.Addr) == 1)
+			checkBool(t, "listpeers a latency", listing.Latency == 42)
+
+		case c.peer.ID:
+			checkBool(t, "listpeers c addrs", len(listing.Info.Addr) == 1)
+			checkBool(t, "listpeers c latency", listing.Latency == 0)
+
+		default:
+			t.Errorf("listpeers: unexpected peer %s", rec.peer.ID.Pretty())
+		}
+	}
+
+	// new addresses discard the last probe
+	a.peer.Addrs = a.peer.Addrs[:1]
+	dir.updatePeer(a)
+	pinfo, ok = dir.lookupPeer(a.peer.ID)
+	checkBool(t, "lookup a after update", ok && sameAddrs(pinfo, a.peer))
+	_, ok = dir.probeLatency(a.peer.ID)
+	checkBool(t, "latency a after update", !ok)
+}
@@ -7,12 +7,16 @@ import (
 	mc "github.com/mediachain/concat/mc"
 	pb "github.com/mediachain/concat/proto"
 	"log"
+	"time"
 )
 
 func (dir *Directory) registerHandler(s p2p_net.Stream) {
 	defer s.Close()
 
-	pid := mc.LogStreamHandler(s)
+	pid, ok := dir.acceptStream(s)
+	if !ok {
+		return
+	}
 
 	var req pb.RegisterPeer
 	r := ggio.NewDelimitedReader(s, mc.MaxMessageSize)
@@ -23,6 +27,10 @@ func (dir *Directory) registerHandler(s p2p_net.Stream) {
 			break
 		}
 
+		if !dir.admitRequest(s, pid) {
+			break
+		}
+
 		if req.Info == nil {
 			log.Printf("directory/register: empty peer info from %s", pid.Pretty())
 			break
@@ -39,23 +47,40 @@ func (dir *Directory) registerHandler(s p2p_net.Stream) {
 			break
 		}
 
-		dir.registerPeer(PeerRecord{pinfo, req.Publisher})
+		rec := PeerRecord{peer: pinfo, publisher: req.Publisher, manifest: req.Manifest, revoke: req.Revocation, info: req.NodeInfo}
+		err = dir.registerPeer(rec)
+		switch err {
+		case NodeManifestPending:
+			// the registration is admitted once the entity key is resolved
+			log.Printf("directory/register: deferring registration from %s: %s", pid.Pretty(), err.Error())
+			req.Reset()
+			continue
+
+		case StaleRegistration:
+			// a newer record has been admitted in the meantime
+			req.Reset()
+			continue
+		}
 
-		if len(req.Manifest) > 0 {
-			dir.mfs.Put(pid, req.Manifest)
+		if err != nil {
+			log.Printf("directory/register: rejecting registration from %s: %s", pid.Pretty(), err.Error())
+			break
 		}
 
 		req.Reset()
 	}
 
-	dir.unregisterPeer(pid)
-	dir.mfs.Remove(pid)
+	// the registration stays until it expires, so that brief disconnects
+	// don't drop the peer
 }
 
 func (dir *Directory) lookupHandler(s p2p_net.Stream) {
 	defer s.Close()
 
-	pid := mc.LogStreamHandler(s)
+	pid, ok := dir.acceptStream(s)
+	if !ok {
+		return
+	}
 
 	var req pb.LookupPeerRequest
 	var res pb.LookupPeerResponse
@@ -69,6 +94,10 @@ func (dir *Directory) lookupHandler(s p2p_net.Stream) {
 			break
 		}
 
+		if !dir.admitRequest(s, pid) {
+			break
+		}
+
 		xid, err := p2p_peer.IDB58Decode(req.Id)
 		if err != nil {
 			log.Printf("directory/lookup: bad request from %s", pid.Pretty())
@@ -95,7 +124,10 @@ func (dir *Directory) lookupHandler(s p2p_net.Stream) {
 func (dir *Directory) listHandler(s p2p_net.Stream) {
 	defer s.Close()
 
-	mc.LogStreamHandler(s)
+	pid, ok := dir.acceptStream(s)
+	if !ok {
+		return
+	}
 
 	var req pb.ListPeersRequest
 	var res pb.ListPeersResponse
@@ -109,6 +141,10 @@ func (dir *Directory) listHandler(s p2p_net.Stream) {
 			break
 		}
 
+		if !dir.admitRequest(s, pid) {
+			break
+		}
+
 		res.Peers = dir.listPeers(req.Namespace)
 
 		err = w.WriteMsg(&res)
@@ -123,7 +159,10 @@ func (dir *Directory) listHandler(s p2p_net.Stream) {
 func (dir *Directory) listnsHandler(s p2p_net.Stream) {
 	defer s.Close()
 
-	mc.LogStreamHandler(s)
+	pid, ok := dir.acceptStream(s)
+	if !ok {
+		return
+	}
 
 	var req pb.ListNamespacesRequest
 	var res pb.ListNamespacesResponse
@@ -137,7 +176,14 @@ func (dir *Directory) listnsHandler(s p2p_net.Stream) {
 			break
 		}
 
+		if !dir.admitRequest(s, pid) {
+			break
+		}
+
 		res.Namespaces = dir.listNamespaces()
+		if req.Stats {
+			res.Stats = dir.listNamespaceStats()
+		}
 
 		err = w.WriteMsg(&res)
 		if err != nil {
@@ -151,7 +197,10 @@ func (dir *Directory) listnsHandler(s p2p_net.Stream) {
 func (dir *Directory) listmfHandler(s p2p_net.Stream) {
 	defer s.Close()
 
-	mc.LogStreamHandler(s)
+	pid, ok := dir.acceptStream(s)
+	if !ok {
+		return
+	}
 
 	var req pb.ListManifestRequest
 	var res pb.ListManifestResponse
@@ -165,6 +214,10 @@ func (dir *Directory) listmfHandler(s p2p_net.Stream) {
 			break
 		}
 
+		if !dir.admitRequest(s, pid) {
+			break
+		}
+
 		res.Manifest = dir.mfs.Lookup(req.Entity)
 
 		err = w.WriteMsg(&res)
@@ -175,3 +228,86 @@ func (dir *Directory) listmfHandler(s p2p_net.Stream) {
 		res.Reset()
 	}
 }
+
+const (
+	listPeersDefaultLimit = 100
+	listPeersMaxLimit     = 1000
+)
+
+func (dir *Directory) listpeersHandler(s p2p_net.Stream) {
+	defer s.Close()
+
+	pid, ok := dir.acceptStream(s)
+	if !ok {
+		return
+	}
+
+	var req pb.ListPeersVerboseRequest
+	var res pb.ListPeersVerboseResponse
+
+	r := ggio.NewDelimitedReader(s, mc.MaxMessageSize)
+	w := ggio.NewDelimitedWriter(s)
+
+	for {
+		err := r.ReadMsg(&req)
+		if err != nil {
+			break
+		}
+
+		if !dir.admitRequest(s, pid) {
+			break
+		}
+
+		if req.Offset < 0 || req.Limit < 0 {
+			log.Printf("directory/listpeers: bad request from %s", pid.Pretty())
+			break
+		}
+
+		limit := int(req.Limit)
+		switch {
+		case limit == 0:
+			limit = listPeersDefaultLimit
+		case limit > listPeersMaxLimit:
+			limit = listPeersMaxLimit
+		}
+
+		recs, total := dir.listPeersVerbose(req.Namespace, int(req.Offset), limit)
+
+		res.Peers = make([]*pb.PeerListing, len(recs))
+		for x, rec := range recs {
+			res.Peers[x] = dir.peerListing(rec)
+		}
+		res.Total = int64(total)
+
+		err = w.WriteMsg(&res)
+		if err != nil {
+			break
+		}
+
+		req.Reset()
+		res.Reset()
+	}
+}
+
+func (dir *Directory) peerListing(rec PeerRecord) *pb.PeerListing {
+	var pbpi pb.PeerInfo
+	mc.PBFromPeerInfo(&pbpi, rec.peer)
+
+	lst := &pb.PeerListing{
+		Info:      &pbpi,
+		Publisher: rec.publisher,
+		NodeInfo:  rec.info,
+		Manifest:  dir.mfs.LookupSource(rec.peer.ID),
+	}
+
+	if !rec.seen.IsZero() {
+		lst.LastSeen = rec.seen.Unix()
+	}
+
+	latency, ok := dir.probeLatency(rec.peer.ID)
+	if ok {
+		lst.Latency = int64(latency / time.Millisecond)
+	}
+
+	return lst
+}
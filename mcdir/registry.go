@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/binary"
+	bolt "github.com/boltdb/bolt"
+	ggproto "github.com/gogo/protobuf/proto"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	mc "github.com/mediachain/concat/mc"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// Persistent registrations
+// Registrations are stored in a bolt db in the directory home, so that the
+// directory survives restarts without waiting for every peer to reconnect.
+// The Peer bucket maps peer ids to the registration expiration time, as a
+// big endian unix timestamp, followed by the registration message.
+// The Origin bucket maps peer ids of registrations synced from other
+// directories to the id of the origin directory, and the Seen bucket maps
+// peer ids to the time of the last registration, as a big endian unix
+// timestamp. The Ban bucket holds the banned peer ids, with the time of the
+// ban. The Revocation bucket holds the verified manifest revocations, keyed
+// by entity and manifest hash, independent of the registrations that
+// carried them.
+type RegistryStore struct {
+	db *bolt.DB
+}
+
+var (
+	registryPeerBucket   = []byte("Peer")
+	registryOriginBucket = []byte("Origin")
+	registrySeenBucket   = []byte("Seen")
+	registryBanBucket    = []byte("Ban")
+	registryRevBucket    = []byte("Revocation")
+)
+
+func OpenRegistryStore(home string) (*RegistryStore, error) {
+	dbdir := path.Join(home, "registry")
+	err := os.MkdirAll(dbdir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path.Join(dbdir, "registry.bolt"), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(registryPeerBucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucketIfNotExists(registryOriginBucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucketIfNotExists(registrySeenBucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucketIfNotExists(registryBanBucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucketIfNotExists(registryRevBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &RegistryStore{db: db}, nil
+}
+
+func (rs *RegistryStore) Put(rec PeerRecord) error {
+	var pbpi pb.PeerInfo
+	mc.PBFromPeerInfo(&pbpi, rec.peer)
+	msg := pb.RegisterPeer{&pbpi, rec.publisher, rec.manifest, rec.info, rec.revoke}
+
+	data, err := ggproto.Marshal(&msg)
+	if err != nil {
+		return err
+	}
+
+	val := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(val, uint64(rec.expires.Unix()))
+	copy(val[8:], data)
+
+	return rs.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(rec.peer.ID)
+		err := tx.Bucket(registryPeerBucket).Put(key, val)
+		if err != nil {
+			return err
+		}
+
+		if rec.seen.IsZero() {
+			err = tx.Bucket(registrySeenBucket).Delete(key)
+		} else {
+			seen := make([]byte, 8)
+			binary.BigEndian.PutUint64(seen, uint64(rec.seen.Unix()))
+			err = tx.Bucket(registrySeenBucket).Put(key, seen)
+		}
+		if err != nil {
+			return err
+		}
+
+		if rec.origin == "" {
+			return tx.Bucket(registryOriginBucket).Delete(key)
+		}
+
+		return tx.Bucket(registryOriginBucket).Put(key, []byte(rec.origin))
+	})
+}
+
+// DeleteExpired deletes the registrations of peers that have expired by
+// now; peers that have re-registered in the meantime are kept.
+func (rs *RegistryStore) DeleteExpired(pids []p2p_peer.ID, now time.Time) error {
+	return rs.db.Update(func(tx *bolt.Tx) error {
+		peers := tx.Bucket(registryPeerBucket)
+		origins := tx.Bucket(registryOriginBucket)
+		seen := tx.Bucket(registrySeenBucket)
+		for _, pid := range pids {
+			val := peers.Get([]byte(pid))
+			if len(val) >= 8 && int64(binary.BigEndian.Uint64(val[:8])) > now.Unix() {
+				continue
+			}
+
+			err := peers.Delete([]byte(pid))
+			if err != nil {
+				return err
+			}
+
+			err = origins.Delete([]byte(pid))
+			if err != nil {
+				return err
+			}
+
+			err = seen.Delete([]byte(pid))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete deletes the registration of a peer
+func (rs *RegistryStore) Delete(pid p2p_peer.ID) error {
+	return rs.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{registryPeerBucket, registryOriginBucket, registrySeenBucket} {
+			err := tx.Bucket(bucket).Delete([]byte(pid))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (rs *RegistryStore) Ban(pid p2p_peer.ID, now time.Time) error {
+	val := make([]byte, 8)
+	binary.BigEndian.PutUint64(val, uint64(now.Unix()))
+	return rs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(registryBanBucket).Put([]byte(pid), val)
+	})
+}
+
+func (rs *RegistryStore) Unban(pid p2p_peer.ID) error {
+	return rs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(registryBanBucket).Delete([]byte(pid))
+	})
+}
+
+func (rs *RegistryStore) LoadBans() ([]p2p_peer.ID, error) {
+	pids := make([]p2p_peer.ID, 0)
+	err := rs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(registryBanBucket).ForEach(func(key, val []byte) error {
+			pids = append(pids, p2p_peer.ID(key))
+			return nil
+		})
+	})
+
+	return pids, err
+}
+
+// PutRevocation stores a verified revocation
+func (rs *RegistryStore) PutRevocation(key string, rev *pb.ManifestRevocation) error {
+	data, err := ggproto.Marshal(rev)
+	if err != nil {
+		return err
+	}
+
+	return rs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(registryRevBucket).Put([]byte(key), data)
+	})
+}
+
+// LoadRevocations returns the stored revocations by key; undecodable
+// revocations are skipped.
+func (rs *RegistryStore) LoadRevocations() (map[string]*pb.ManifestRevocation, error) {
+	revs := make(map[string]*pb.ManifestRevocation)
+	err := rs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(registryRevBucket).ForEach(func(key, val []byte) error {
+			rev := new(pb.ManifestRevocation)
+			err := ggproto.Unmarshal(val, rev)
+			if err != nil {
+				log.Printf("registry: bad revocation %s: %s", string(key), err.Error())
+				return nil
+			}
+
+			revs[string(key)] = rev
+			return nil
+		})
+	})
+
+	return revs, err
+}
+
+// Load returns the stored registrations; undecodable registrations are
+// skipped.
+func (rs *RegistryStore) Load() ([]PeerRecord, error) {
+	recs := make([]PeerRecord, 0)
+	err := rs.db.View(func(tx *bolt.Tx) error {
+		origins := tx.Bucket(registryOriginBucket)
+		seen := tx.Bucket(registrySeenBucket)
+		return tx.Bucket(registryPeerBucket).ForEach(func(key, val []byte) error {
+			rec, err := registryDecodeRecord(val)
+			if err != nil {
+				log.Printf("registry: bad registration for %s: %s", p2p_peer.ID(key).Pretty(), err.Error())
+				return nil
+			}
+
+			origin := origins.Get(key)
+			if origin != nil {
+				rec.origin = p2p_peer.ID(origin)
+			}
+
+			ts := seen.Get(key)
+			if len(ts) == 8 {
+				rec.seen = time.Unix(int64(binary.BigEndian.Uint64(ts)), 0)
+			}
+
+			recs = append(recs, rec)
+			return nil
+		})
+	})
+
+	return recs, err
+}
+
+func registryDecodeRecord(val []byte) (rec PeerRecord, err error) {
+	if len(val) < 8 {
+		return rec, BadRegistration
+	}
+
+	var msg pb.RegisterPeer
+	err = ggproto.Unmarshal(val[8:], &msg)
+	if err != nil {
+		return
+	}
+
+	if msg.Info == nil {
+		return rec, BadRegistration
+	}
+
+	rec.peer, err = mc.PBToPeerInfo(msg.Info)
+	if err != nil {
+		return
+	}
+
+	rec.publisher = msg.Publisher
+	rec.manifest = msg.Manifest
+	rec.revoke = msg.Revocation
+	rec.info = msg.NodeInfo
+	rec.expires = time.Unix(int64(binary.BigEndian.Uint64(val[:8])), 0)
+	return
+}
+
+func (rs *RegistryStore) Close() error {
+	return rs.db.Close()
+}
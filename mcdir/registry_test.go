@@ -0,0 +1,202 @@
+package main
+
+import (
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	p2p_pstore "github.com/libp2p/go-libp2p-peerstore"
+	pb "github.com/mediachain/concat/proto"
+	multiaddr "github.com/multiformats/go-multiaddr"
+	multihash "github.com/multiformats/go-multihash"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func checkError(t *testing.T, where string, err error) {
+	if err != nil {
+		t.Fatalf("%s: %s", where, err.Error())
+	}
+}
+
+func checkBool(t *testing.T, where string, e bool) {
+	if !e {
+		t.Errorf("%s: check failed", where)
+	}
+}
+
+func makeTestPeerID(t *testing.T, name string) p2p_peer.ID {
+	mh, err := multihash.Sum([]byte(name), multihash.SHA2_256, -1)
+	checkError(t, "multihash.Sum", err)
+	return p2p_peer.ID(mh)
+}
+
+func makeTestPeerRecord(t *testing.T, name string, expires time.Time) PeerRecord {
+	addr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/9001")
+	checkError(t, "NewMultiaddr", err)
+
+	pinfo := p2p_pstore.PeerInfo{ID: makeTestPeerID(t, name), Addrs: []multiaddr.Multiaddr{addr}}
+	publisher := &pb.PublisherInfo{Id: name, Namespaces: []string{"foo.a"}}
+	return PeerRecord{peer: pinfo, publisher: publisher, info: name, expires: expires}
+}
+
+func openTestRegistryStore(t *testing.T) (*RegistryStore, string) {
+	home, err := ioutil.TempDir("", "mcdir-test")
+	checkError(t, "TempDir", err)
+
+	store, err := OpenRegistryStore(home)
+	if err != nil {
+		os.RemoveAll(home)
+		t.Fatalf("OpenRegistryStore: %s", err.Error())
+	}
+
+	return store, home
+}
+
+func loadTestRegistrations(t *testing.T, store *RegistryStore) map[p2p_peer.ID]PeerRecord {
+	recs, err := store.Load()
+	checkError(t, "store.Load", err)
+
+	res := make(map[p2p_peer.ID]PeerRecord)
+	for _, rec := range recs {
+		res[rec.peer.ID] = rec
+	}
+	return res
+}
+
+func TestRegistryStore(t *testing.T) {
+	store, home := openTestRegistryStore(t)
+	defer os.RemoveAll(home)
+	defer store.Close()
+
+	// registrations are stored with second granularity
+	now := time.Unix(time.Now().Unix(), 0)
+	a := makeTestPeerRecord(t, "a", now.Add(time.Hour))
+	a.seen = now
+	b := makeTestPeerRecord(t, "b", now.Add(-time.Hour))
+	b.origin = makeTestPeerID(t, "dir")
+
+	checkError(t, "store.Put", store.Put(a))
+	checkError(t, "store.Put", store.Put(b))
+
+	recs := loadTestRegistrations(t, store)
+	checkBool(t, "load count", len(recs) == 2)
+
+	xa, ok := recs[a.peer.ID]
+	checkBool(t, "load a", ok)
+	checkBool(t, "a expires", xa.expires.Equal(a.expires))
+	checkBool(t, "a seen", xa.seen.Equal(now))
+	checkBool(t, "a origin", xa.origin == "")
+	checkBool(t, "a addrs", sameAddrs(xa.peer, a.peer))
+	checkBool(t, "a publisher", xa.publisher != nil && xa.publisher.Id == "a")
+	checkBool(t, "a info", xa.info == "a")
+
+	xb, ok := recs[b.peer.ID]
+	checkBool(t, "load b", ok)
+	checkBool(t, "b expires", xb.expires.Equal(b.expires))
+	checkBool(t, "b seen", xb.seen.IsZero())
+	checkBool(t, "b origin", xb.origin == b.origin)
+
+	// expiry only deletes registrations that are expired
+	checkError(t, "store.DeleteExpired", store.DeleteExpired([]p2p_peer.ID{a.peer.ID, b.peer.ID}, now))
+	recs = loadTestRegistrations(t, store)
+	_, ok = recs[a.peer.ID]
+	checkBool(t, "expire keeps a", ok)
+	_, ok = recs[b.peer.ID]
+	checkBool(t, "expire deletes b", !ok)
+
+	// a re-registration after the expiry scan is kept
+	c := makeTestPeerRecord(t, "c", now.Add(-time.Minute))
+	checkError(t, "store.Put", store.Put(c))
+	c.expires = now.Add(time.Hour)
+	checkError(t, "store.Put", store.Put(c))
+	checkError(t, "store.DeleteExpired", store.DeleteExpired([]p2p_peer.ID{c.peer.ID}, now))
+	recs = loadTestRegistrations(t, store)
+	xc, ok := recs[c.peer.ID]
+	checkBool(t, "re-registration kept", ok && xc.expires.Equal(c.expires))
+
+	checkError(t, "store.Delete", store.Delete(a.peer.ID))
+	recs = loadTestRegistrations(t, store)
+	_, ok = recs[a.peer.ID]
+	checkBool(t, "delete a", !ok)
+	checkBool(t, "delete count", len(recs) == 1)
+}
+
+func TestRegistryStoreReopen(t *testing.T) {
+	store, home := openTestRegistryStore(t)
+	defer os.RemoveAll(home)
+
+	now := time.Unix(time.Now().Unix(), 0)
+	a := makeTestPeerRecord(t, "a", now.Add(time.Hour))
+	checkError(t, "store.Put", store.Put(a))
+	checkError(t, "store.Ban", store.Ban(makeTestPeerID(t, "x"), now))
+	checkError(t, "store.Close", store.Close())
+
+	store, err := OpenRegistryStore(home)
+	checkError(t, "OpenRegistryStore", err)
+	defer store.Close()
+
+	recs := loadTestRegistrations(t, store)
+	_, ok := recs[a.peer.ID]
+	checkBool(t, "reopen load", ok && len(recs) == 1)
+
+	bans, err := store.LoadBans()
+	checkError(t, "store.LoadBans", err)
+	checkBool(t, "reopen bans", len(bans) == 1 && bans[0] == makeTestPeerID(t, "x"))
+}
+
+func makeTestDirectory(t *testing.T) (*Directory, string) {
+	store, home := openTestRegistryStore(t)
+	mfs, err := NewManifestStore(store)
+	if err != nil {
+		store.Close()
+		os.RemoveAll(home)
+		t.Fatalf("NewManifestStore: %s", err.Error())
+	}
+
+	dir := &Directory{
+		peers:    make(map[p2p_peer.ID]PeerRecord),
+		probes:   make(map[p2p_peer.ID]ProbeResult),
+		bans:     make(map[p2p_peer.ID]bool),
+		deferred: make(map[p2p_peer.ID]PeerRecord),
+		mfs:      mfs,
+		store:    store,
+		ttl:      time.Hour,
+	}
+	return dir, home
+}
+
+func TestExpirePeers(t *testing.T) {
+	dir, home := makeTestDirectory(t)
+	defer os.RemoveAll(home)
+	defer dir.store.Close()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	a := makeTestPeerRecord(t, "a", now.Add(time.Hour))
+	b := makeTestPeerRecord(t, "b", now.Add(-time.Hour))
+	for _, rec := range []PeerRecord{a, b} {
+		dir.peers[rec.peer.ID] = rec
+		dir.probes[rec.peer.ID] = ProbeResult{reachable: true}
+		checkError(t, "store.Put", dir.store.Put(rec))
+	}
+
+	dir.expirePeers()
+
+	_, ok := dir.peers[a.peer.ID]
+	checkBool(t, "live peer kept", ok)
+	_, ok = dir.peers[b.peer.ID]
+	checkBool(t, "expired peer removed", !ok)
+	_, ok = dir.probes[b.peer.ID]
+	checkBool(t, "expired probe removed", !ok)
+
+	recs := loadTestRegistrations(t, dir.store)
+	_, ok = recs[a.peer.ID]
+	checkBool(t, "live registration kept", ok)
+	_, ok = recs[b.peer.ID]
+	checkBool(t, "expired registration deleted", !ok)
+
+	// the stored registrations are loaded back on restart
+	dir.peers = make(map[p2p_peer.ID]PeerRecord)
+	checkError(t, "loadPeers", dir.loadPeers())
+	_, ok = dir.peers[a.peer.ID]
+	checkBool(t, "load live peer", ok && len(dir.peers) == 1)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	ggio "github.com/gogo/protobuf/io"
+	p2p_net "github.com/libp2p/go-libp2p-net"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	p2p_pstore "github.com/libp2p/go-libp2p-peerstore"
+	mc "github.com/mediachain/concat/mc"
+	pb "github.com/mediachain/concat/proto"
+	"io"
+	"log"
+	"time"
+)
+
+// Directory federation
+// Directories configured with sync peers periodically pull the live
+// registrations of their peers, together with the verified manifests, so
+// that a node registered with one directory can be found through any of
+// them. Synced records carry the directory of origin and their expiration;
+// a synced record only replaces an existing registration if it expires
+// later, and it never outlives the local ttl.
+func (dir *Directory) syncHandler(s p2p_net.Stream) {
+	defer s.Close()
+
+	pid, ok := dir.acceptStream(s)
+	if !ok {
+		return
+	}
+
+	if !dir.isSyncPeer(pid) {
+		log.Printf("directory/sync: rejecting sync request from %s", pid.Pretty())
+		return
+	}
+
+	var req pb.SyncRequest
+	r := ggio.NewDelimitedReader(s, mc.MaxMessageSize)
+	err := r.ReadMsg(&req)
+	if err != nil {
+		return
+	}
+
+	if !dir.admitRequest(s, pid) {
+		return
+	}
+
+	now := time.Now()
+	dir.mx.Lock()
+	recs := make([]PeerRecord, 0, len(dir.peers))
+	for _, rec := range dir.peers {
+		if rec.live(now) {
+			recs = append(recs, rec)
+		}
+	}
+	dir.mx.Unlock()
+
+	w := ggio.NewDelimitedWriter(s)
+	for _, rec := range recs {
+		var pbpi pb.PeerInfo
+		mc.PBFromPeerInfo(&pbpi, rec.peer)
+
+		origin := rec.origin
+		if origin == "" {
+			origin = dir.ID
+		}
+
+		msg := pb.SyncRecord{
+			Registration: &pb.RegisterPeer{&pbpi, rec.publisher, dir.mfs.LookupSource(rec.peer.ID), rec.info, rec.revoke},
+			Origin:       origin.Pretty(),
+			Expires:      rec.expires.Unix(),
+		}
+
+		if !rec.seen.IsZero() {
+			msg.LastSeen = rec.seen.Unix()
+		}
+
+		err = w.WriteMsg(&msg)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (dir *Directory) isSyncPeer(pid p2p_peer.ID) bool {
+	for _, pinfo := range dir.syncPeers {
+		if pinfo.ID == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func (dir *Directory) syncPeersLoop() {
+	for {
+		for _, pinfo := range dir.syncPeers {
+			count, err := dir.syncPeer(pinfo)
+			if err != nil {
+				log.Printf("directory/sync: error syncing with %s: %s", pinfo.ID.Pretty(), err.Error())
+			}
+			if count > 0 {
+				log.Printf("directory/sync: merged %d registrations from %s", count, pinfo.ID.Pretty())
+			}
+		}
+
+		time.Sleep(5 * time.Minute)
+	}
+}
+
+// syncPeer pulls the registrations of a sync peer and merges them; returns
+// the number of merged registrations.
+func (dir *Directory) syncPeer(pinfo p2p_pstore.PeerInfo) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	err := dir.host.Connect(ctx, pinfo)
+	if err != nil {
+		return 0, err
+	}
+
+	pid := pinfo.ID
+	s, err := dir.host.NewStream(ctx, pid, "/mediachain/dir/sync")
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	w := ggio.NewDelimitedWriter(s)
+	err = w.WriteMsg(&pb.SyncRequest{})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	r := ggio.NewDelimitedReader(s, mc.MaxMessageSize)
+	for {
+		var msg pb.SyncRecord
+		err = r.ReadMsg(&msg)
+		switch {
+		case err == io.EOF:
+			return count, nil
+		case err != nil:
+			return count, err
+		}
+
+		rec, err := syncDecodeRecord(&msg)
+		if err != nil {
+			log.Printf("directory/sync: bad record from %s: %s", pid.Pretty(), err.Error())
+			continue
+		}
+
+		if dir.mergePeer(rec) {
+			count += 1
+		}
+	}
+}
+
+func syncDecodeRecord(msg *pb.SyncRecord) (rec PeerRecord, err error) {
+	if msg.Registration == nil || msg.Registration.Info == nil {
+		return rec, BadRegistration
+	}
+
+	rec.peer, err = mc.PBToPeerInfo(msg.Registration.Info)
+	if err != nil {
+		return
+	}
+
+	rec.origin, err = p2p_peer.IDB58Decode(msg.Origin)
+	if err != nil {
+		return
+	}
+
+	rec.publisher = msg.Registration.Publisher
+	rec.manifest = msg.Registration.Manifest
+	rec.revoke = msg.Registration.Revocation
+	rec.info = msg.Registration.NodeInfo
+	rec.expires = time.Unix(msg.Expires, 0)
+	if msg.LastSeen > 0 {
+		rec.seen = time.Unix(msg.LastSeen, 0)
+	}
+	return
+}
+
+// mergePeer merges a synced registration; returns true if the record was
+// merged.
+func (dir *Directory) mergePeer(rec PeerRecord) bool {
+	if rec.origin == dir.ID || rec.peer.ID == dir.ID {
+		return false
+	}
+
+	// registrations whose node manifest is pending verification are
+	// rejected for now and merged with the next sync
+	err := dir.checkRegistration(rec.peer.ID, rec.publisher, rec.manifest)
+	if err != nil {
+		log.Printf("directory/sync: rejecting registration for %s: %s", rec.peer.ID.Pretty(), err.Error())
+		return false
+	}
+
+	now := time.Now()
+	maxexp := now.Add(dir.ttl)
+	if rec.expires.After(maxexp) {
+		rec.expires = maxexp
+	}
+
+	if !rec.live(now) {
+		return false
+	}
+
+	return dir.admitPeer(rec) == nil
+}
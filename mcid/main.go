@@ -33,11 +33,16 @@ func main() {
 		_ = kp.Command("id", "show your public identity; generates a new key pair if it doesn't already exist.") // idCmd declared but not used
 
 		signCmd      = kp.Command("sign", "sign a manifest")
+		signExpires  = signCmd.Flag("expires", "manifest validity period; the manifest doesn't expire if unspecified").Short('e').Duration()
 		signEntity   = signCmd.Arg("entity", "entity id").Required().String()
 		signManifest = signCmd.Arg("manifest", "manifest json file").Required().File()
 
-		verifyCmd      = kp.Command("verify", "verify a manifest")
-		verifyManifest = verifyCmd.Arg("manifest", "manifest json file").Required().File()
+		verifyCmd        = kp.Command("verify", "verify a manifest")
+		verifyRevocation = verifyCmd.Flag("revocation", "revocation json file; the manifest fails verification if revoked").Short('r').ExistingFiles()
+		verifyManifest   = verifyCmd.Arg("manifest", "manifest json file").Required().File()
+
+		revokeCmd      = kp.Command("revoke", "revoke a signed manifest")
+		revokeManifest = revokeCmd.Arg("manifest", "signed manifest json file").Required().File()
 	)
 
 	switch kp.Parse() {
@@ -45,10 +50,13 @@ func main() {
 		doId(*home)
 
 	case "sign":
-		doSign(*home, *signEntity, *signManifest)
+		doSign(*home, *signEntity, *signManifest, *signExpires)
 
 	case "verify":
-		doVerify(*home, *verifyManifest)
+		doVerify(*home, *verifyManifest, *verifyRevocation)
+
+	case "revoke":
+		doRevoke(*home, *revokeManifest)
 	}
 }
 
@@ -90,7 +98,7 @@ func doId(home string) {
 	json.NewEncoder(os.Stdout).Encode(id.Public)
 }
 
-func doSign(home string, entity string, mf *os.File) {
+func doSign(home string, entity string, mf *os.File, expires time.Duration) {
 	var manifest pb.Manifest
 	var manifestBody pb.ManifestBody
 
@@ -118,6 +126,9 @@ func doSign(home string, entity string, mf *os.File) {
 	manifest.KeyId = id.Public.KeyId
 	manifest.Body = &manifestBody
 	manifest.Timestamp = time.Now().Unix()
+	if expires > 0 {
+		manifest.Expires = manifest.Timestamp + int64(expires/time.Second)
+	}
 
 	bytes, err := ggproto.Marshal(&manifest)
 	if err != nil {
@@ -139,7 +150,7 @@ func doSign(home string, entity string, mf *os.File) {
 	fmt.Println()
 }
 
-func doVerify(home string, mf *os.File) {
+func doVerify(home string, mf *os.File, revs []string) {
 	var manifest pb.Manifest
 
 	err := jsonpb.Unmarshal(mf, &manifest)
@@ -147,28 +158,129 @@ func doVerify(home string, mf *os.File) {
 		log.Fatalf("Error decoding manifest: %s", err.Error())
 	}
 
+	if mc.IsManifestExpired(&manifest, time.Now()) {
+		log.Fatalf("Manifest expired at %s", time.Unix(manifest.Expires, 0))
+	}
+
 	pubk, err := mc.LookupEntityKey(manifest.Entity, manifest.KeyId)
 	if err != nil {
 		log.Fatalf("Error looking up entity key: %s", err.Error())
 	}
 
-	sig := manifest.Signature
-	manifest.Signature = nil
+	ok, err := mc.VerifyManifest(&manifest, pubk)
+	switch {
+	case err != nil:
+		log.Fatalf("Error verifying manifest: %s", err.Error())
+	case !ok:
+		log.Fatalf("Manifest verification failed")
+	}
+
+	for _, rpath := range revs {
+		rev, err := loadRevocation(rpath)
+		if err != nil {
+			log.Fatalf("Error loading revocation: %s", err.Error())
+		}
 
-	bytes, err := ggproto.Marshal(&manifest)
+		if !mc.RevokesManifest(rev, &manifest) {
+			continue
+		}
+
+		pubk, err := mc.LookupEntityKey(rev.Entity, rev.KeyId)
+		if err != nil {
+			log.Fatalf("Error looking up entity key: %s", err.Error())
+		}
+
+		ok, err := mc.VerifyManifestRevocation(rev, pubk)
+		switch {
+		case err != nil:
+			log.Fatalf("Error verifying revocation: %s", err.Error())
+		case !ok:
+			log.Printf("Ignoring revocation %s: signature verification failed", rpath)
+		default:
+			log.Fatalf("Manifest revoked by %s", rpath)
+		}
+	}
+
+	fmt.Println("OK")
+}
+
+func doRevoke(home string, mf *os.File) {
+	var manifest pb.Manifest
+
+	err := jsonpb.Unmarshal(mf, &manifest)
 	if err != nil {
-		log.Fatalf("Error marshalling manifest: %s", err.Error())
+		log.Fatalf("Error decoding manifest: %s", err.Error())
+	}
+
+	id, err := getIdentity(home, false) // error if id doesn't exist
+	if err != nil {
+		log.Fatalf("Error retrieving identity: %s", err.Error())
 	}
 
-	ok, err := pubk.Verify(bytes, sig)
+	// only the key that signed the manifest can revoke it for the entity
+	if manifest.KeyId != id.Public.KeyId {
+		log.Fatalf("Manifest key %s does not match your key %s", manifest.KeyId, id.Public.KeyId)
+	}
+
+	pubk, err := p2p_crypto.UnmarshalPublicKey(id.Public.Key)
+	if err != nil {
+		log.Fatalf("Error unmarshalling public key: %s", err.Error())
+	}
+
+	ok, err := mc.VerifyManifest(&manifest, pubk)
 	switch {
 	case err != nil:
 		log.Fatalf("Error verifying manifest: %s", err.Error())
 	case !ok:
-		log.Fatalf("Manifest verification failed")
-	default:
-		fmt.Println("OK")
+		log.Fatalf("Manifest for %s not signed by your key", manifest.Entity)
+	}
+
+	privk, err := getPrivateKey(id.Private)
+	if err != nil {
+		log.Fatalf("Error decrypting private key: %s", err.Error())
 	}
+
+	mfh, err := mc.HashManifest(&manifest)
+	if err != nil {
+		log.Fatalf("Error hashing manifest: %s", err.Error())
+	}
+
+	var rev pb.ManifestRevocation
+	rev.Entity = manifest.Entity
+	rev.KeyId = id.Public.KeyId
+	rev.Manifest = mfh
+	rev.Timestamp = time.Now().Unix()
+
+	bytes, err := ggproto.Marshal(&rev)
+	if err != nil {
+		log.Fatalf("Error marshalling revocation: %s", err.Error())
+	}
+
+	sig, err := privk.Sign(bytes)
+	if err != nil {
+		log.Fatalf("Error signing revocation: %s", err.Error())
+	}
+
+	rev.Signature = sig
+
+	marshaler := jsonpb.Marshaler{}
+	err = marshaler.Marshal(os.Stdout, &rev)
+	if err != nil {
+		log.Fatalf("Error encoding revocation: %s", err.Error())
+	}
+	fmt.Println()
+}
+
+func loadRevocation(rpath string) (*pb.ManifestRevocation, error) {
+	fd, err := os.Open(rpath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	rev := new(pb.ManifestRevocation)
+	err = jsonpb.Unmarshal(fd, rev)
+	return rev, err
 }
 
 // identity
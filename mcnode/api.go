@@ -228,6 +228,9 @@ func (node *Node) httpPing(w http.ResponseWriter, r *http.Request) {
 // GET /dir/list
 // GET /dir/list/{namespace}
 // List peers known to the directory, with a namespace filter if provided
+// With ?verbose=true, lists the peers as json objects with their addresses,
+// publisher, namespaces, node info, manifests and last registration time,
+// paginated with the offset and limit query parameters.
 func (node *Node) httpDirList(w http.ResponseWriter, r *http.Request) {
 	node.httpDirListImpl(w, r, false)
 }
@@ -245,6 +248,11 @@ func (node *Node) httpDirListImpl(w http.ResponseWriter, r *http.Request, inclSe
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
+	if r.FormValue("verbose") == "true" {
+		node.httpDirListVerbose(ctx, w, r, ns, inclSelf)
+		return
+	}
+
 	peers, err := node.doDirList(ctx, ns)
 	if err != nil {
 		apiError(w, http.StatusInternalServerError, err)
@@ -260,12 +268,62 @@ func (node *Node) httpDirListImpl(w http.ResponseWriter, r *http.Request, inclSe
 	}
 }
 
+func (node *Node) httpDirListVerbose(ctx context.Context, w http.ResponseWriter, r *http.Request, ns string, inclSelf bool) {
+	var offset, limit int
+	var err error
+
+	if str := r.FormValue("offset"); str != "" {
+		offset, err = strconv.Atoi(str)
+		if err != nil || offset < 0 {
+			apiError(w, http.StatusBadRequest, BadPagination)
+			return
+		}
+	}
+
+	if str := r.FormValue("limit"); str != "" {
+		limit, err = strconv.Atoi(str)
+		if err != nil || limit < 0 {
+			apiError(w, http.StatusBadRequest, BadPagination)
+			return
+		}
+	}
+
+	var peers []DirectoryPeer
+	if inclSelf {
+		peers, err = node.doDirListVerbose(ctx, ns, offset, limit)
+	} else {
+		peers, err = node.doDirListVerboseExcludeSelf(ctx, ns, offset, limit)
+	}
+
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	for _, peer := range peers {
+		err = enc.Encode(peer)
+		if err != nil {
+			log.Printf("Error encoding directory peer: %s", err.Error())
+			return
+		}
+	}
+}
+
 // GET /dir/listns
 // List namespaces known to the directory
+// With ?stats=true, lists the namespaces as json objects with the statement
+// counts and last updates advertised by peers, and the peers ranked by the
+// completeness of their copy.
 func (node *Node) httpDirListNS(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
+	if r.FormValue("stats") == "true" {
+		node.httpDirListNSStats(ctx, w)
+		return
+	}
+
 	nss, err := node.doDirListNS(ctx)
 	if err != nil {
 		apiError(w, http.StatusInternalServerError, err)
@@ -277,6 +335,23 @@ func (node *Node) httpDirListNS(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (node *Node) httpDirListNSStats(ctx context.Context, w http.ResponseWriter) {
+	nss, err := node.doDirListNSStats(ctx)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	for _, ns := range nss {
+		err = enc.Encode(ns)
+		if err != nil {
+			log.Printf("Error encoding namespace stats: %s", err.Error())
+			return
+		}
+	}
+}
+
 // GET /dir/listmf/{entity}
 // List manifests from some entity
 func (node *Node) httpDirListMF(w http.ResponseWriter, r *http.Request) {
@@ -478,6 +553,75 @@ import_error:
 	}
 }
 
+// POST /import/archive
+// DATA: A node archive, as produced by /export
+// Imports the statements and objects in an archive, verifying statement
+// signatures and object hashes; the archive is validated in full before
+// anything is merged.
+// Returns the number of statements and objects merged
+func (node *Node) httpImportArchive(w http.ResponseWriter, r *http.Request) {
+	count, ocount, err := node.doImportArchive(r.Context(), r.Body)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		if count > 0 {
+			fmt.Fprintf(w, "Partial import: %d statements merged\n", count)
+		}
+		if ocount > 0 {
+			fmt.Fprintf(w, "Partial import: %d objects merged\n", ocount)
+		}
+		return
+	}
+
+	fmt.Fprintln(w, count)
+	fmt.Fprintln(w, ocount)
+}
+
+// POST /export
+// DATA: MCQL SELECT * query
+// Exports the statements matching the query and the objects they reference
+// as a single archive (tar file)
+// Errors after the archive has started streaming truncate the archive,
+// which lacks an index and is rejected on import
+func (node *Node) httpExport(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("http/export: Error reading request body: %s", err.Error())
+		return
+	}
+
+	qs := string(body)
+	q, err := mcq.ParseQuery(qs)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !q.IsSimpleSelect("*") {
+		apiError(w, http.StatusBadRequest, BadQuery)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	ch, err := node.db.QueryStream(ctx, q)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	index, err := node.doExport(ctx, qs, ch, w)
+	if err != nil {
+		log.Printf("http/export: Error exporting archive: %s", err.Error())
+		return
+	}
+
+	if len(index.Missing) > 0 {
+		log.Printf("http/export: Archive is missing %d objects", len(index.Missing))
+	}
+}
+
 // GET /stmt/{statementId}
 // Retrieves a statement by id
 func (node *Node) httpStatement(w http.ResponseWriter, r *http.Request) {
@@ -543,6 +687,51 @@ func (node *Node) httpQuery(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// POST /latest
+// DATA: json-encoded VersionQuery
+// Returns the latest version of a wki for each publisher, or for the
+// specified publisher, in ndjson
+func (node *Node) httpLatest(w http.ResponseWriter, r *http.Request) {
+	node.httpVersionQuery(w, r, node.doLatest)
+}
+
+// POST /history
+// DATA: json-encoded VersionQuery
+// Returns all versions of a wki in version order, optionally restricted to
+// a publisher, in ndjson
+func (node *Node) httpHistory(w http.ResponseWriter, r *http.Request) {
+	node.httpVersionQuery(w, r, node.doHistory)
+}
+
+func (node *Node) httpVersionQuery(w http.ResponseWriter, r *http.Request, qf func(VersionQuery) ([]*pb.Statement, error)) {
+	var q VersionQuery
+	err := json.NewDecoder(r.Body).Decode(&q)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	stmts, err := qf(q)
+	switch {
+	case err == BadWKI:
+		apiError(w, http.StatusBadRequest, err)
+		return
+
+	case err != nil:
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	for _, stmt := range stmts {
+		err = enc.Encode(stmt)
+		if err != nil {
+			log.Printf("Error encoding query result: %s", err.Error())
+			return
+		}
+	}
+}
+
 // POST /query/{peerId}
 // DATA: MCQL SELECT query
 // Queries a remote peer and returns the result set in ndjson
@@ -727,6 +916,7 @@ func (node *Node) httpDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	count, err := node.db.Delete(q)
+	node.nsscache.touchAll()
 	if err != nil {
 		apiError(w, http.StatusInternalServerError, err)
 		if count > 0 {
@@ -738,6 +928,55 @@ func (node *Node) httpDelete(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, count)
 }
 
+// POST /retract/{namespace}
+// DATA: newline delimited list of statement ids
+// Publishes a retraction for statements published by the node in error in
+// the namespace.
+// The retracted statements are deleted, and subsequently skipped in merges
+// and imports.
+// Returns the retraction statement id.
+func (node *Node) httpRetract(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ns := vars["namespace"]
+
+	if !nsrx.Match([]byte(ns)) {
+		apiError(w, http.StatusBadRequest, BadNamespace)
+		return
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	sid, err := node.doRetract(ns, ids)
+	switch {
+	case err == BadRetraction || err == BadRetractionNamespace:
+		apiError(w, http.StatusBadRequest, err)
+		return
+
+	case err != nil:
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, sid)
+}
+
 // POST /vacuum/incremental
 // Perform an incremental vacuum in the statement db
 func (node *Node) httpVacuumIncremental(w http.ResponseWriter, r *http.Request) {
@@ -762,6 +1001,47 @@ func (node *Node) httpVacuumFull(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "OK")
 }
 
+// GET /db/check
+// GET /db/check/verify
+// Checks the integrity of the statement db, verifying that the indexes
+// agree with the statement data; with /verify, statement signatures are
+// verified as well.
+// Returns a json report of the statements with problems.
+func (node *Node) httpCheckDB(w http.ResponseWriter, r *http.Request) {
+	var verify StatementVerifier
+	if strings.HasSuffix(r.URL.Path, "/verify") {
+		pkcache := make(map[string]p2p_crypto.PubKey)
+		verify = func(stmt *pb.Statement) (bool, error) {
+			return node.verifyStatementCacheKeys(stmt, pkcache)
+		}
+	}
+
+	report, err := node.db.Check(verify)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Printf("Error writing response body: %s", err.Error())
+	}
+}
+
+// POST /db/reindex
+// Rebuilds the statement db indexes from the statement data
+// Returns the number of statements reindexed
+func (node *Node) httpReindexDB(w http.ResponseWriter, r *http.Request) {
+	count, err := node.db.Rebuild()
+	node.nsscache.touchAll()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, count)
+}
+
 // datastore interface
 type DataObject struct {
 	Data []byte `json:"data"`
@@ -769,6 +1049,7 @@ type DataObject struct {
 
 // GET /data/get/{objectId}
 // Retrieves a data object from the datastore
+// In lazy mode, missing objects are fetched from the network on first access
 func (node *Node) httpGetData(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	key58 := vars["objectId"]
@@ -784,6 +1065,17 @@ func (node *Node) httpGetData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if data == nil && node.lazy {
+		ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+		defer cancel()
+
+		data, err = node.doFetchData(ctx, key58, Key(key))
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
 	if data == nil {
 		apiError(w, http.StatusNotFound, UnknownObject)
 		return
@@ -857,8 +1149,11 @@ loop:
 	// a single error result or a stream of hashes.
 	// Writes are idempotent, so there is no deleterious effect from partial writes
 	// (other than a subset of the objects written in the datastore)
+	defer node.gcs.beginWrite().Done()
+
 	keys := make([]Key, len(batch))
 	for x, data := range batch {
+		node.gcs.touchData(data)
 		key, err := node.ds.Put(data)
 		if err != nil {
 			apiError(w, http.StatusInternalServerError, err)
@@ -909,6 +1204,7 @@ func (node *Node) httpMergeData(w http.ResponseWriter, r *http.Request) {
 
 // POST /data/gc
 // garbage collect orphan data objects that are not referenced by any statement
+// the gc runs online, concurrently with merges and publishing
 func (node *Node) httpGCData(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
@@ -925,6 +1221,97 @@ func (node *Node) httpGCData(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, count)
 }
 
+// GET /data/gc/dryrun
+// lists the objects that would be collected by a gc, without deleting them
+func (node *Node) httpGCDryRun(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	keys, err := node.doGCDryRun(ctx)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for _, key58 := range keys {
+		fmt.Fprintln(w, key58)
+	}
+}
+
+// GET  /data/pin
+// POST /data/pin
+// DATA: A newline-delimited list of object ids
+// Lists pinned objects, or pins objects so that they are not garbage
+// collected. Objects can be pinned before they are added to the datastore.
+// POST returns the number of newly pinned objects.
+func (node *Node) httpPinData(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodHead:
+		return
+
+	case http.MethodGet:
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		ch, err := node.pindb.List(ctx)
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		for val := range ch {
+			switch val := val.(type) {
+			case string:
+				fmt.Fprintln(w, val)
+
+			case StreamError:
+				fmt.Fprintf(w, "Error: %s\n", val.Error())
+				return
+			}
+		}
+
+	case http.MethodPost:
+		node.httpPinDataMethod(w, r, node.doPin)
+
+	default:
+		apiError(w, http.StatusBadRequest, BadMethod)
+	}
+}
+
+// POST /data/unpin
+// DATA: A newline-delimited list of object ids
+// Unpins objects; returns the number of pins removed.
+func (node *Node) httpUnpinData(w http.ResponseWriter, r *http.Request) {
+	node.httpPinDataMethod(w, r, node.doUnpin)
+}
+
+func (node *Node) httpPinDataMethod(w http.ResponseWriter, r *http.Request, pinf func(map[string]Key) (int, error)) {
+	keys := make(map[string]Key)
+
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		err := node.mergeObjectKey(scanner.Text(), keys)
+		if err != nil {
+			apiError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	count, err := pinf(keys)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, count)
+}
+
 // POST /data/compact
 // compact the datastore
 func (node *Node) httpCompactData(w http.ResponseWriter, r *http.Request) {
@@ -949,8 +1336,51 @@ func (node *Node) httpSyncData(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "OK")
 }
 
+// GET  /data/scrub
+// POST /data/scrub/quarantine
+// POST /data/scrub/refetch
+// Verifies the hash of every object in the datastore and returns a json
+// report of corrupt objects. With quarantine, corrupt objects are moved to
+// the quarantine directory; with refetch, they are also fetched again from
+// peers, which requires the node to be online.
+func (node *Node) httpScrubData(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	action, ok := vars["action"]
+
+	var quarantine, refetch bool
+	switch {
+	case !ok:
+	case action == "quarantine":
+		quarantine = true
+	case action == "refetch":
+		refetch = true
+	default:
+		apiError(w, http.StatusNotFound, fmt.Errorf("Unknown scrub action: %s", action))
+		return
+	}
+
+	if ok && r.Method != http.MethodPost {
+		apiError(w, http.StatusBadRequest, BadMethod)
+		return
+	}
+
+	report, err := node.doScrub(r.Context(), quarantine, refetch)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		if report != nil {
+			fmt.Fprintf(w, "Partial scrub: %d objects quarantined\n", report.Quarantined)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Printf("Error writing response body: %s", err.Error())
+	}
+}
+
 func (node *Node) httpDataKeys(w http.ResponseWriter, r *http.Request) {
-	keys, err := node.ds.IterKeys(r.Context())
+	keys, errc, err := node.ds.IterKeys(r.Context())
 	if err != nil {
 		apiError(w, http.StatusInternalServerError, err)
 		return
@@ -959,6 +1389,40 @@ func (node *Node) httpDataKeys(w http.ResponseWriter, r *http.Request) {
 	for key := range keys {
 		fmt.Fprintln(w, multihash.Multihash(key).B58String())
 	}
+
+	err = <-errc
+	if err != nil {
+		fmt.Fprintf(w, "Error: %s\n", err.Error())
+	}
+}
+
+// POST /backup
+// DATA: json-encoded BackupOptions
+// Backs up the node home to a directory or tarball, while the node is running
+func (node *Node) httpBackup(w http.ResponseWriter, r *http.Request) {
+	var opts BackupOptions
+	err := json.NewDecoder(r.Body).Decode(&opts)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if opts.Path == "" {
+		apiError(w, http.StatusBadRequest, BadBackup)
+		return
+	}
+
+	err = node.doBackup(opts)
+	switch err {
+	case nil:
+		fmt.Fprintln(w, "OK")
+
+	case BackupExists, BackupUnsupported:
+		apiError(w, http.StatusBadRequest, err)
+
+	default:
+		apiError(w, http.StatusInternalServerError, err)
+	}
 }
 
 // GET /status
@@ -1114,6 +1578,168 @@ func (node *Node) httpConfigInfoSet(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "OK")
 }
 
+// GET  /config/provide
+// POST /config/provide
+// retrieve/set the object provider configuration in json
+// the configuration is an object of the form {"namespaces": [...], "sample": N}
+// where namespaces are MCQL namespace selectors for objects announced in the
+// DHT, and sample is the optional sampling ratio (1 in N objects)
+func (node *Node) httpConfigProvide(w http.ResponseWriter, r *http.Request) {
+	apiConfigMethod(w, r, node.httpConfigProvideGet, node.httpConfigProvideSet)
+}
+
+func (node *Node) httpConfigProvideGet(w http.ResponseWriter, r *http.Request) {
+	cfg := node.provideConfig()
+	if cfg == nil {
+		cfg = &ProvideConfig{}
+	}
+
+	err := json.NewEncoder(w).Encode(cfg)
+	if err != nil {
+		log.Printf("Error writing response body: %s", err.Error())
+	}
+}
+
+func (node *Node) httpConfigProvideSet(w http.ResponseWriter, r *http.Request) {
+	cfg := new(ProvideConfig)
+	err := json.NewDecoder(r.Body).Decode(cfg)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, ns := range cfg.Namespaces {
+		_, err = mcq.ParseQuery(fmt.Sprintf("SELECT * FROM %s", ns))
+		if err != nil {
+			apiError(w, http.StatusBadRequest, BadNamespace)
+			return
+		}
+	}
+
+	if cfg.Sample < 0 {
+		apiError(w, http.StatusBadRequest, BadProvideConfig)
+		return
+	}
+
+	node.setProvideConfig(cfg)
+
+	err = node.saveConfig()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+// GET  /config/lazy
+// POST /config/lazy
+// retrieve/set lazy object retrieval mode (true/false)
+func (node *Node) httpConfigLazy(w http.ResponseWriter, r *http.Request) {
+	apiConfigMethod(w, r, node.httpConfigLazyGet, node.httpConfigLazySet)
+}
+
+func (node *Node) httpConfigLazyGet(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, node.lazy)
+}
+
+func (node *Node) httpConfigLazySet(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("http/config/lazy: Error reading request body: %s", err.Error())
+		return
+	}
+
+	lazy, err := strconv.ParseBool(strings.TrimSpace(string(body)))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	node.lazy = lazy
+
+	err = node.saveConfig()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+// GET  /config/compress
+// POST /config/compress
+// retrieve/set compressed data transfer mode (true/false)
+// when set, the node asks peers for compressed objects in data merges
+func (node *Node) httpConfigCompress(w http.ResponseWriter, r *http.Request) {
+	apiConfigMethod(w, r, node.httpConfigCompressGet, node.httpConfigCompressSet)
+}
+
+func (node *Node) httpConfigCompressGet(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, node.compress)
+}
+
+func (node *Node) httpConfigCompressSet(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("http/config/compress: Error reading request body: %s", err.Error())
+		return
+	}
+
+	compress, err := strconv.ParseBool(strings.TrimSpace(string(body)))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	node.compress = compress
+
+	err = node.saveConfig()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+// GET  /config/nsstats
+// POST /config/nsstats
+// retrieve/set namespace stats advertisement (true/false)
+// when set, the node advertises the statement count and last update of its
+// namespaces when registering with directories
+func (node *Node) httpConfigNSStats(w http.ResponseWriter, r *http.Request) {
+	apiConfigMethod(w, r, node.httpConfigNSStatsGet, node.httpConfigNSStatsSet)
+}
+
+func (node *Node) httpConfigNSStatsGet(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, node.nsstats)
+}
+
+func (node *Node) httpConfigNSStatsSet(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("http/config/nsstats: Error reading request body: %s", err.Error())
+		return
+	}
+
+	nsstats, err := strconv.ParseBool(strings.TrimSpace(string(body)))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	node.nsstats = nsstats
+
+	err = node.saveConfig()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
 // GET /auth
 // retrieves all peer authorization rules in json
 func (node *Node) httpAuth(w http.ResponseWriter, r *http.Request) {
@@ -1184,14 +1810,15 @@ func (node *Node) httpAuthPeerSet(w http.ResponseWriter, r *http.Request) {
 
 // GET  /manifest
 // POST /manifest
-// Gets or sets the node's manifests
+// Gets or sets the node's manifests; expired and revoked manifests are
+// omitted, and rejected when set.
 func (node *Node) httpManifest(w http.ResponseWriter, r *http.Request) {
 	apiConfigMethod(w, r, node.httpManifestGet, node.httpManifestSet)
 }
 
 func (node *Node) httpManifestGet(w http.ResponseWriter, r *http.Request) {
 	enc := json.NewEncoder(w)
-	for _, mf := range node.mfs {
+	for _, mf := range node.validManifests() {
 		err := enc.Encode(mf)
 		if err != nil {
 			log.Printf("Error writing response body: %s", err.Error())
@@ -1203,6 +1830,7 @@ func (node *Node) httpManifestGet(w http.ResponseWriter, r *http.Request) {
 func (node *Node) httpManifestSet(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	mfs := make([]*pb.Manifest, 0)
+	now := time.Now()
 
 loop:
 	for {
@@ -1214,6 +1842,9 @@ loop:
 		case err != nil:
 			apiError(w, http.StatusBadRequest, err)
 			return
+		case !checkManifest(mf, node.mfrevs, now):
+			apiError(w, http.StatusBadRequest, BadManifest)
+			return
 		default:
 			mfs = append(mfs, mf)
 		}
@@ -1241,8 +1872,115 @@ func (node *Node) httpManifestSelf(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GET  /manifest/revoke
+// POST /manifest/revoke
+// Gets or sets the node's manifest revocations, which are presented to
+// directories with the node's registration.
+func (node *Node) httpManifestRevoke(w http.ResponseWriter, r *http.Request) {
+	apiConfigMethod(w, r, node.httpManifestRevokeGet, node.httpManifestRevokeSet)
+}
+
+func (node *Node) httpManifestRevokeGet(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	for _, rev := range node.mfrevs {
+		err := enc.Encode(rev)
+		if err != nil {
+			log.Printf("Error writing response body: %s", err.Error())
+			return
+		}
+	}
+}
+
+func (node *Node) httpManifestRevokeSet(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	revs := make([]*pb.ManifestRevocation, 0)
+
+loop:
+	for {
+		rev := new(pb.ManifestRevocation)
+		err := dec.Decode(rev)
+		switch {
+		case err == io.EOF:
+			break loop
+		case err != nil:
+			apiError(w, http.StatusBadRequest, err)
+			return
+		default:
+			revs = append(revs, rev)
+		}
+	}
+
+	node.mfrevs = revs
+	node.updateNamespacePolicy()
+
+	err := node.saveConfig()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
+// GET  /manifest/ns
+// POST /manifest/ns
+// Gets or sets the namespace manifests enforced by the node; manifests are
+// verified when set. Statements in claimed namespaces by publishers not
+// listed in the manifest are rejected in merges, pushes and imports.
+func (node *Node) httpManifestNS(w http.ResponseWriter, r *http.Request) {
+	apiConfigMethod(w, r, node.httpManifestNSGet, node.httpManifestNSSet)
+}
+
+func (node *Node) httpManifestNSGet(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	for _, mf := range node.nsmfs {
+		err := enc.Encode(mf)
+		if err != nil {
+			log.Printf("Error writing response body: %s", err.Error())
+			return
+		}
+	}
+}
+
+func (node *Node) httpManifestNSSet(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	mfs := make([]*pb.Manifest, 0)
+
+loop:
+	for {
+		mf := new(pb.Manifest)
+		err := dec.Decode(mf)
+		switch {
+		case err == io.EOF:
+			break loop
+		case err != nil:
+			apiError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		err = node.verifyNamespaceManifest(mf)
+		if err != nil {
+			apiError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		mfs = append(mfs, mf)
+	}
+
+	node.nsmfs = mfs
+	node.updateNamespacePolicy()
+
+	err := node.saveConfig()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fmt.Fprintln(w, "OK")
+}
+
 // GET /manifest/{peerId}
-// Requests manifest from remote peer peerId
+// Requests manifest from remote peer peerId; expired manifests are omitted
 func (node *Node) httpManifestPeer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	peerId := vars["peerId"]
@@ -1263,7 +2001,7 @@ func (node *Node) httpManifestPeer(w http.ResponseWriter, r *http.Request) {
 
 	enc := json.NewEncoder(w)
 
-	for _, mf := range mfs {
+	for _, mf := range filterManifests(mfs, nil, time.Now()) {
 		err := enc.Encode(mf)
 		if err != nil {
 			log.Printf("Error writing response body: %s", err.Error())
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	ggio "github.com/gogo/protobuf/io"
+	p2p_crypto "github.com/libp2p/go-libp2p-crypto"
+	mc "github.com/mediachain/concat/mc"
+	pb "github.com/mediachain/concat/proto"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	BadArchive        = errors.New("Bad archive")
+	IncompleteArchive = errors.New("Incomplete archive; missing index")
+)
+
+// Node archives
+// An archive is a tar file with the statements matching an export query and
+// the objects they reference: batches of length-delimited pb.Statements in
+// stmt/<n> entries, data objects in data/<key58> entries, and the archive
+// index in index.json.
+// The objects referenced by a statement batch follow the batch, so that
+// objects can be checked against the statements that reference them in a
+// single pass. The index is written last and
+// marks the archive as complete; an export that fails midway produces an
+// archive without an index.
+const archiveVersion = 1
+
+type ArchiveIndex struct {
+	Version    int      `json:"version"`
+	Query      string   `json:"query"`
+	Timestamp  int64    `json:"timestamp"`
+	Statements int      `json:"statements"`
+	Objects    []string `json:"objects"`
+	Missing    []string `json:"missing,omitempty"` // objects not in the datastore
+}
+
+// doExport writes an archive with the statement stream of a SELECT * query;
+// objects missing from the datastore are fetched from the network in lazy
+// mode, and are otherwise listed as missing in the index.
+func (node *Node) doExport(ctx context.Context, qs string, ch <-chan interface{}, w io.Writer) (*ArchiveIndex, error) {
+	var err error
+	index := &ArchiveIndex{
+		Version:   archiveVersion,
+		Query:     qs,
+		Timestamp: time.Now().Unix(),
+		Objects:   []string{},
+	}
+
+	tw := tar.NewWriter(w)
+
+	const batch = 1024
+	var buf bytes.Buffer
+	sw := ggio.NewDelimitedWriter(&buf)
+	scount := 0
+	nbatch := 0
+	seen := make(map[string]bool)
+	keys := make(map[string]Key)
+
+	writeBatch := func() error {
+		err := archiveWriteEntry(tw, fmt.Sprintf("stmt/%06d", nbatch), buf.Bytes(), index.Timestamp)
+		if err != nil {
+			return err
+		}
+		buf.Reset()
+		scount = 0
+		nbatch += 1
+
+		err = node.exportObjects(ctx, tw, keys, index)
+		if err != nil {
+			return err
+		}
+		keys = make(map[string]Key)
+		return nil
+	}
+
+	for val := range ch {
+		switch val := val.(type) {
+		case *pb.Statement:
+			err = sw.WriteMsg(val)
+			if err != nil {
+				return nil, err
+			}
+
+			xkeys := make(map[string]Key)
+			err = node.mergeStatementKeys(val, xkeys)
+			if err != nil {
+				return nil, err
+			}
+
+			for key58, key := range xkeys {
+				if !seen[key58] {
+					seen[key58] = true
+					keys[key58] = key
+				}
+			}
+
+			index.Statements += 1
+			scount += 1
+			if scount >= batch {
+				err = writeBatch()
+				if err != nil {
+					return nil, err
+				}
+			}
+
+		case StreamError:
+			return nil, val
+
+		default:
+			return nil, BadResult
+		}
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if scount > 0 {
+		err = writeBatch()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bytes, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+
+	err = archiveWriteEntry(tw, "index.json", bytes, index.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func (node *Node) exportObjects(ctx context.Context, tw *tar.Writer, keys map[string]Key, index *ArchiveIndex) error {
+	klist := make([]string, 0, len(keys))
+	for key58, _ := range keys {
+		klist = append(klist, key58)
+	}
+	sort.Strings(klist)
+
+	for _, key58 := range klist {
+		key := keys[key58]
+		data, err := node.ds.Get(key)
+		if err != nil {
+			return err
+		}
+
+		if data == nil && node.lazy {
+			fctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+			data, err = node.doFetchData(fctx, key58, key)
+			cancel()
+			if err != nil {
+				log.Printf("export: Error fetching %s: %s", key58, err.Error())
+			}
+		}
+
+		if data == nil {
+			index.Missing = append(index.Missing, key58)
+			continue
+		}
+
+		err = archiveWriteEntry(tw, "data/"+key58, data, index.Timestamp)
+		if err != nil {
+			return err
+		}
+		index.Objects = append(index.Objects, key58)
+	}
+
+	return nil
+}
+
+func archiveWriteEntry(tw *tar.Writer, name string, data []byte, ts int64) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Unix(ts, 0),
+	}
+
+	err := tw.WriteHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
+
+// doImportArchive imports an archive, verifying statement signatures and
+// object hashes; objects must be referenced by a statement preceding them in
+// the archive. It returns the number of statements and objects merged.
+// The archive is staged in a temporary file and validated in full before
+// anything is merged, so that truncated or corrupt archives are rejected
+// without a partial import.
+func (node *Node) doImportArchive(ctx context.Context, r io.Reader) (count int, ocount int, err error) {
+	tmp, err := ioutil.TempFile("", "mcnode-archive")
+	if err != nil {
+		return
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return
+	}
+
+	pkcache := make(map[string]p2p_crypto.PubKey)
+	_, _, err = node.readArchive(ctx, tmp, pkcache, false)
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return
+	}
+
+	return node.readArchive(ctx, tmp, pkcache, true)
+}
+
+// readArchive reads an archive, either validating or merging its contents;
+// validation checks the statements and objects without writing anything.
+func (node *Node) readArchive(ctx context.Context, r io.Reader, pkcache map[string]p2p_crypto.PubKey, merge bool) (count int, ocount int, err error) {
+	if merge {
+		defer node.gcs.beginWrite().Done()
+	}
+
+	// objects are only imported if referenced by a merged statement
+	tr := tar.NewReader(r)
+	keys := make(map[string]Key)
+	imported := make(map[string]Key)
+	scount := 0
+	dcount := 0
+	var index *ArchiveIndex
+
+loop:
+	for {
+		err = ctx.Err()
+		if err != nil {
+			return
+		}
+
+		var hdr *tar.Header
+		hdr, err = tr.Next()
+		switch {
+		case err == io.EOF:
+			err = nil
+			break loop
+		case err != nil:
+			return
+		}
+
+		if index != nil {
+			// the index is the last entry
+			err = BadArchive
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(hdr.Name, "stmt/"):
+			var stmts []*pb.Statement
+			stmts, err = archiveReadStatements(tr)
+			if err != nil {
+				return
+			}
+
+			for _, stmt := range stmts {
+				err = node.mergeStatementKeys(stmt, keys)
+				if err != nil {
+					return
+				}
+			}
+
+			scount += len(stmts)
+			if !merge {
+				err = node.verifyArchiveStatements(stmts, pkcache)
+				if err != nil {
+					return
+				}
+				continue
+			}
+
+			stmts, err = node.checkImport(stmts, pkcache)
+			if err != nil {
+				return
+			}
+
+			for _, stmt := range stmts {
+				err = node.mergeStatementKeys(stmt, imported)
+				if err != nil {
+					return
+				}
+			}
+
+			var xcount int
+			xcount, err = node.mergeImport(stmts)
+			count += xcount
+			if err != nil {
+				return
+			}
+
+		case strings.HasPrefix(hdr.Name, "data/"):
+			key58 := strings.TrimPrefix(hdr.Name, "data/")
+			key, ok := keys[key58]
+			if !ok {
+				err = UnexpectedData
+				return
+			}
+
+			var data []byte
+			data, err = ioutil.ReadAll(io.LimitReader(tr, mc.MaxMessageSize+1))
+			if err != nil {
+				return
+			}
+
+			if len(data) > mc.MaxMessageSize || !bytes.Equal([]byte(key), []byte(mc.Hash(data))) {
+				err = BadData
+				return
+			}
+
+			dcount += 1
+			if !merge {
+				ocount += 1
+				continue
+			}
+
+			_, ok = imported[key58]
+			if !ok {
+				continue
+			}
+
+			ocount += 1
+			node.gcs.touchData(data)
+			_, err = node.ds.Put(data)
+			if err != nil {
+				return
+			}
+
+		case hdr.Name == "index.json":
+			index = new(ArchiveIndex)
+			err = json.NewDecoder(tr).Decode(index)
+			if err != nil {
+				return
+			}
+
+		default:
+			err = BadArchive
+			return
+		}
+	}
+
+	switch {
+	case index == nil:
+		err = IncompleteArchive
+
+	case index.Version != archiveVersion:
+		err = BadArchive
+
+	case index.Statements != scount || len(index.Objects) != dcount:
+		err = BadArchive
+	}
+
+	return
+}
+
+func (node *Node) verifyArchiveStatements(stmts []*pb.Statement, pkcache map[string]p2p_crypto.PubKey) error {
+	for _, stmt := range stmts {
+		if !node.checkStatement(stmt) {
+			return BadStatement
+		}
+
+		verify, err := node.verifyStatementCacheKeys(stmt, pkcache)
+		if err != nil {
+			return err
+		}
+
+		if !verify {
+			return BadStatement
+		}
+	}
+
+	return nil
+}
+
+func archiveReadStatements(r io.Reader) ([]*pb.Statement, error) {
+	rd := ggio.NewDelimitedReader(r, mc.MaxMessageSize)
+	stmts := make([]*pb.Statement, 0, 1024)
+	for {
+		stmt := new(pb.Statement)
+		err := rd.ReadMsg(stmt)
+		switch {
+		case err == io.EOF:
+			return stmts, nil
+		case err != nil:
+			return nil, err
+		}
+
+		stmts = append(stmts, stmt)
+	}
+}
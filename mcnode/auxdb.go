@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	bolt "github.com/boltdb/bolt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Auxiliary dbs
+// Node state kept outside the statement db, like lazy object sources, pins
+// and retraction tombstones, is stored in small key-value dbs so that it is
+// available with every statement db backend. On disk, each aux db is a bolt
+// db with a single bucket in home/name/name.db; memory nodes keep them in
+// memory.
+type AuxDB interface {
+	// Get returns nil if the key is not present
+	Get(key string) ([]byte, error)
+	// GetBatch looks up a batch of keys, returning the entries present
+	GetBatch(keys []string) (map[string][]byte, error)
+	Has(key string) (bool, error)
+	// Put writes a batch of entries, returning the number of new keys;
+	// existing keys are only overwritten with replace.
+	Put(kvs []AuxEntry, replace bool) (int, error)
+	// Delete removes a batch of keys, returning the number of keys removed
+	Delete(keys []string) (int, error)
+	// Scan returns up to limit entries with keys after start, in key order
+	Scan(start string, limit int) ([]AuxEntry, error)
+	Backup(dir string) error
+	Close() error
+}
+
+type AuxEntry struct {
+	Key string
+	Val []byte
+}
+
+func openAuxDB(home string, name string) (AuxDB, error) {
+	if home == ":memory:" { // allow testing
+		return &MemAuxDB{data: make(map[string][]byte)}, nil
+	}
+
+	adb := &BoltAuxDB{name: name}
+	err := adb.Open(path.Join(home, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return adb, nil
+}
+
+type BoltAuxDB struct {
+	db   *bolt.DB
+	name string
+}
+
+var boltAuxBucket = []byte("Aux")
+
+func (adb *BoltAuxDB) Open(dbdir string) error {
+	err := os.MkdirAll(dbdir, 0755)
+	if err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(path.Join(dbdir, adb.name+".db"), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltAuxBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	adb.db = db
+	return nil
+}
+
+func (adb *BoltAuxDB) Get(key string) (val []byte, err error) {
+	err = adb.db.View(func(tx *bolt.Tx) error {
+		xval := tx.Bucket(boltAuxBucket).Get([]byte(key))
+		if xval != nil {
+			val = make([]byte, len(xval))
+			copy(val, xval)
+		}
+		return nil
+	})
+	return
+}
+
+func (adb *BoltAuxDB) GetBatch(keys []string) (res map[string][]byte, err error) {
+	err = adb.db.View(func(tx *bolt.Tx) error {
+		res = make(map[string][]byte)
+		bucket := tx.Bucket(boltAuxBucket)
+		for _, key := range keys {
+			xval := bucket.Get([]byte(key))
+			if xval != nil {
+				val := make([]byte, len(xval))
+				copy(val, xval)
+				res[key] = val
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (adb *BoltAuxDB) Has(key string) (have bool, err error) {
+	err = adb.db.View(func(tx *bolt.Tx) error {
+		have = tx.Bucket(boltAuxBucket).Get([]byte(key)) != nil
+		return nil
+	})
+	return
+}
+
+func (adb *BoltAuxDB) Put(kvs []AuxEntry, replace bool) (count int, err error) {
+	err = adb.db.Update(func(tx *bolt.Tx) error {
+		count = 0
+		bucket := tx.Bucket(boltAuxBucket)
+		for _, kv := range kvs {
+			key := []byte(kv.Key)
+			if bucket.Get(key) != nil {
+				if !replace {
+					continue
+				}
+			} else {
+				count += 1
+			}
+
+			err := bucket.Put(key, kv.Val)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (adb *BoltAuxDB) Delete(keys []string) (count int, err error) {
+	err = adb.db.Update(func(tx *bolt.Tx) error {
+		count = 0
+		bucket := tx.Bucket(boltAuxBucket)
+		for _, key := range keys {
+			xkey := []byte(key)
+			if bucket.Get(xkey) == nil {
+				continue
+			}
+
+			err := bucket.Delete(xkey)
+			if err != nil {
+				return err
+			}
+			count += 1
+		}
+		return nil
+	})
+	return
+}
+
+func (adb *BoltAuxDB) Scan(start string, limit int) (res []AuxEntry, err error) {
+	err = adb.db.View(func(tx *bolt.Tx) error {
+		res = nil
+		xstart := []byte(start)
+		cursor := tx.Bucket(boltAuxBucket).Cursor()
+		k, v := cursor.Seek(xstart)
+		if k != nil && bytes.Equal(k, xstart) {
+			k, v = cursor.Next()
+		}
+
+		for ; k != nil && len(res) < limit; k, v = cursor.Next() {
+			val := make([]byte, len(v))
+			copy(val, v)
+			res = append(res, AuxEntry{string(k), val})
+		}
+		return nil
+	})
+	return
+}
+
+// Backup copies the db to dir/name/name.db in a read transaction
+func (adb *BoltAuxDB) Backup(dir string) error {
+	return boltBackup(adb.db, path.Join(dir, adb.name, adb.name+".db"))
+}
+
+func (adb *BoltAuxDB) Close() error {
+	return adb.db.Close()
+}
+
+type MemAuxDB struct {
+	mx   sync.RWMutex
+	data map[string][]byte
+}
+
+func (adb *MemAuxDB) Get(key string) ([]byte, error) {
+	adb.mx.RLock()
+	val, ok := adb.data[key]
+	adb.mx.RUnlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	return memCopy(val), nil
+}
+
+func (adb *MemAuxDB) GetBatch(keys []string) (map[string][]byte, error) {
+	adb.mx.RLock()
+	defer adb.mx.RUnlock()
+
+	res := make(map[string][]byte)
+	for _, key := range keys {
+		val, ok := adb.data[key]
+		if ok {
+			res[key] = memCopy(val)
+		}
+	}
+
+	return res, nil
+}
+
+func (adb *MemAuxDB) Has(key string) (bool, error) {
+	adb.mx.RLock()
+	_, ok := adb.data[key]
+	adb.mx.RUnlock()
+	return ok, nil
+}
+
+func (adb *MemAuxDB) Put(kvs []AuxEntry, replace bool) (int, error) {
+	adb.mx.Lock()
+	defer adb.mx.Unlock()
+
+	count := 0
+	for _, kv := range kvs {
+		_, ok := adb.data[kv.Key]
+		switch {
+		case !ok:
+			count += 1
+		case !replace:
+			continue
+		}
+
+		adb.data[kv.Key] = memCopy(kv.Val)
+	}
+
+	return count, nil
+}
+
+func (adb *MemAuxDB) Delete(keys []string) (int, error) {
+	adb.mx.Lock()
+	defer adb.mx.Unlock()
+
+	count := 0
+	for _, key := range keys {
+		_, ok := adb.data[key]
+		if ok {
+			delete(adb.data, key)
+			count += 1
+		}
+	}
+
+	return count, nil
+}
+
+func (adb *MemAuxDB) Scan(start string, limit int) ([]AuxEntry, error) {
+	adb.mx.RLock()
+	defer adb.mx.RUnlock()
+
+	keys := make([]string, 0, len(adb.data))
+	for key, _ := range adb.data {
+		if key > start {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	res := make([]AuxEntry, len(keys))
+	for x, key := range keys {
+		res[x] = AuxEntry{key, memCopy(adb.data[key])}
+	}
+
+	return res, nil
+}
+
+func (adb *MemAuxDB) Backup(dir string) error {
+	return BackupUnsupported
+}
+
+func (adb *MemAuxDB) Close() error {
+	return nil
+}
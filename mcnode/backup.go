@@ -0,0 +1,386 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	bolt "github.com/boltdb/bolt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	BackupUnsupported = errors.New("Backup not supported for in-memory stores")
+	BackupExists      = errors.New("Backup destination already exists")
+	RestoreConflict   = errors.New("Restore would overwrite existing files")
+	BadBackup         = errors.New("Bad backup archive")
+)
+
+// Node backups
+// A backup is a snapshot of the node home taken while the node is online,
+// with the same layout as the home so that it can be restored by copying.
+// The statement db is backed up before the datastore, so that all objects
+// referenced by statements in the backup are also in the backup.
+// The sqlite statement db is copied with the sqlite online backup api, the
+// bolt dbs in a read transaction, and the rocks datastore with a checkpoint.
+// The node configuration and identities are only included on request.
+// Backups with a .tar, .tar.gz or .tgz suffix are written as tarballs.
+type BackupOptions struct {
+	Path     string `json:"path"`
+	Config   bool   `json:"config,omitempty"`
+	Identity bool   `json:"identity,omitempty"`
+}
+
+var (
+	backupConfig   = []string{"config.json"}
+	backupIdentity = []string{"identity.node", "identity.publisher"}
+)
+
+func (node *Node) doBackup(opts BackupOptions) error {
+	if node.home == ":memory:" {
+		return BackupUnsupported
+	}
+
+	_, err := os.Stat(opts.Path)
+	switch {
+	case err == nil:
+		return BackupExists
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if !backupTarball(opts.Path) {
+		return node.doBackupDir(opts.Path, opts)
+	}
+
+	tmpdir, err := ioutil.TempDir(path.Dir(opts.Path), ".backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	err = node.doBackupDir(path.Join(tmpdir, "home"), opts)
+	if err != nil {
+		return err
+	}
+
+	return backupWriteTarball(path.Join(tmpdir, "home"), opts.Path)
+}
+
+func (node *Node) doBackupDir(dir string, opts BackupOptions) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = node.db.Backup(dir)
+	if err != nil {
+		return err
+	}
+
+	err = node.ds.Backup(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, db := range []AuxDB{node.lazydb.db, node.pindb.db, node.retractdb.db} {
+		err = db.Backup(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	var files []string
+	if opts.Config {
+		files = append(files, backupConfig...)
+	}
+	if opts.Identity {
+		files = append(files, backupIdentity...)
+	}
+
+	for _, file := range files {
+		err = backupCopyFile(path.Join(node.home, file), path.Join(dir, file))
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Backed up node to %s", opts.Path)
+	return nil
+}
+
+func boltBackup(db *bolt.DB, dest string) error {
+	err := os.MkdirAll(path.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(dest, 0644)
+	})
+}
+
+// backupCopyFile copies a file, skipping files that don't exist
+func backupCopyFile(src, dest string) error {
+	data, err := ioutil.ReadFile(src)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dest, data, info.Mode())
+}
+
+func backupTarball(name string) bool {
+	return strings.HasSuffix(name, ".tar") || backupTarballGzip(name)
+}
+
+func backupTarballGzip(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+func backupWriteTarball(dir string, dest string) (err error) {
+	tmp := dest + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	var w io.Writer = out
+	var gzw *gzip.Writer
+	if backupTarballGzip(dest) {
+		gzw = gzip.NewWriter(out)
+		w = gzw
+	}
+
+	tw := tar.NewWriter(w)
+	err = filepath.Walk(dir, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rpath, err := filepath.Rel(dir, fpath)
+		if err != nil || rpath == "." {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rpath)
+
+		err = tw.WriteHeader(hdr)
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		in, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	if gzw != nil {
+		err = gzw.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = out.Sync()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, dest)
+}
+
+// restoreBackup restores a backup directory or tarball to a node home; the
+// node must not be running. Existing files are never overwritten, so a
+// backup without identities can be restored to a home with identities, but
+// the stores must be moved out of the way first.
+func restoreBackup(home string, src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return restoreBackupDir(home, src)
+	}
+
+	return restoreBackupTarball(home, src)
+}
+
+func restoreBackupDir(home string, src string) error {
+	err := filepath.Walk(src, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rpath, err := filepath.Rel(src, fpath)
+		if err != nil {
+			return err
+		}
+
+		return restoreCheckPath(home, rpath, info.IsDir())
+	})
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rpath, err := filepath.Rel(src, fpath)
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(home, rpath)
+		if info.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+
+		in, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		return restoreWriteFile(dest, in, info.Mode())
+	})
+}
+
+func restoreBackupTarball(home string, src string) error {
+	// verify the archive before touching the home
+	err := restoreReadTarball(src, func(hdr *tar.Header, r io.Reader) error {
+		return restoreCheckPath(home, hdr.Name, hdr.Typeflag == tar.TypeDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	return restoreReadTarball(src, func(hdr *tar.Header, r io.Reader) error {
+		dest := filepath.Join(home, filepath.FromSlash(hdr.Name))
+		if hdr.Typeflag == tar.TypeDir {
+			return os.MkdirAll(dest, 0755)
+		}
+
+		return restoreWriteFile(dest, r, os.FileMode(hdr.Mode))
+	})
+}
+
+func restoreReadTarball(src string, f func(*tar.Header, io.Reader) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var r io.Reader = in
+	if backupTarballGzip(src) {
+		gzr, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg:
+		default:
+			return BadBackup
+		}
+
+		err = f(hdr, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func restoreCheckPath(home string, rpath string, dir bool) error {
+	rpath = filepath.Clean(filepath.FromSlash(rpath))
+	if filepath.IsAbs(rpath) || rpath == ".." || strings.HasPrefix(rpath, ".."+string(filepath.Separator)) {
+		return BadBackup
+	}
+
+	if dir {
+		return nil
+	}
+
+	_, err := os.Stat(filepath.Join(home, rpath))
+	switch {
+	case err == nil:
+		return RestoreConflict
+	case os.IsNotExist(err):
+		return nil
+	default:
+		return err
+	}
+}
+
+func restoreWriteFile(dest string, r io.Reader, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, r)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
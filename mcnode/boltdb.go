@@ -0,0 +1,1032 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	bolt "github.com/boltdb/bolt"
+	ggproto "github.com/gogo/protobuf/proto"
+	mcq "github.com/mediachain/concat/mc/query"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// Pure Go statement db backend, on top of bolt.
+// Queries are executed by compiled query plans instead of sql.
+// The Statement bucket maps ids to the envelope counter and statement data,
+// the Envelope bucket maps counters to ids, and the Namespace bucket maps
+// namespace/0x00/counter keys to ids for namespace scans.
+// The counter is a big endian uint64 allocated from the Envelope bucket
+// sequence, so that it increases monotonically like the sqlite counter.
+// The Version bucket maps wki/0x00/publisher/0x00/version keys to ids, where
+// the version is the big endian timestamp followed by the id length and the
+// id, so that the versions of each publisher are in version order and the
+// latest version is the last key in the publisher's range.
+type BoltDB struct {
+	db     *bolt.DB
+	dbpath string
+	mx     sync.RWMutex // protects db against reopening in full vacuum
+}
+
+var (
+	boltStatementBucket = []byte("Statement")
+	boltEnvelopeBucket  = []byte("Envelope")
+	boltNamespaceBucket = []byte("Namespace")
+	boltVersionBucket   = []byte("Version")
+	boltLatestBucket    = []byte("Latest")
+	boltBuckets         = [][]byte{boltStatementBucket, boltEnvelopeBucket, boltNamespaceBucket, boltVersionBucket, boltLatestBucket}
+)
+
+func (bdb *BoltDB) Open(home string) error {
+	dbdir := path.Join(home, "stmt")
+	err := os.MkdirAll(dbdir, 0755)
+	if err != nil {
+		return err
+	}
+
+	bdb.dbpath = path.Join(dbdir, "stmt.bolt")
+	return bdb.openDB()
+}
+
+func (bdb *BoltDB) openDB() error {
+	db, err := bolt.Open(bdb.dbpath, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// dbs created before the version or latest index need to be indexed
+		exists := tx.Bucket(boltStatementBucket) != nil
+		index := exists && tx.Bucket(boltVersionBucket) == nil
+		indexLatest := exists && tx.Bucket(boltLatestBucket) == nil
+
+		for _, name := range boltBuckets {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case index:
+			log.Printf("Indexing statement versions")
+			return boltIndexVersions(tx)
+
+		case indexLatest:
+			log.Printf("Indexing latest statement versions")
+			return boltIndexLatest(tx)
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	bdb.db = db
+	return nil
+}
+
+func (bdb *BoltDB) Put(stmt *pb.Statement) error {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		ok, err := boltPutStatement(tx, stmt)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return DupStatement
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) PutBatch(stmts []*pb.Statement) error {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		for _, stmt := range stmts {
+			ok, err := boltPutStatement(tx, stmt)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return DupStatement
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) Merge(stmt *pb.Statement) (ok bool, err error) {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	err = bdb.db.Update(func(tx *bolt.Tx) error {
+		ok, err = boltPutStatement(tx, stmt)
+		return err
+	})
+
+	return
+}
+
+func (bdb *BoltDB) MergeBatch(stmts []*pb.Statement) (count int, err error) {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	err = bdb.db.Update(func(tx *bolt.Tx) error {
+		count = 0
+		for _, stmt := range stmts {
+			ok, err := boltPutStatement(tx, stmt)
+			if err != nil {
+				return err
+			}
+			if ok {
+				count += 1
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// boltPutStatement inserts a statement unless it already exists
+func boltPutStatement(tx *bolt.Tx, stmt *pb.Statement) (bool, error) {
+	stmts := tx.Bucket(boltStatementBucket)
+	id := []byte(stmt.Id)
+	if stmts.Get(id) != nil {
+		return false, nil
+	}
+
+	data, err := ggproto.Marshal(stmt)
+	if err != nil {
+		return false, err
+	}
+
+	envs := tx.Bucket(boltEnvelopeBucket)
+	seq, err := envs.NextSequence()
+	if err != nil {
+		return false, err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, seq)
+
+	val := make([]byte, 8+len(data))
+	copy(val, counter)
+	copy(val[8:], data)
+
+	err = stmts.Put(id, val)
+	if err != nil {
+		return false, err
+	}
+
+	err = envs.Put(counter, id)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Bucket(boltNamespaceBucket).Put(boltNamespaceKey(stmt.Namespace, counter), id)
+	if err != nil {
+		return false, err
+	}
+
+	err = boltPutVersions(tx, stmt)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func boltPutVersions(tx *bolt.Tx, stmt *pb.Statement) error {
+	vers := tx.Bucket(boltVersionBucket)
+	id := []byte(stmt.Id)
+	for wki, _ := range mcq.StatementRefs(stmt) {
+		err := vers.Put(boltVersionKey(wki, stmt), id)
+		if err != nil {
+			return err
+		}
+	}
+	return boltPutLatest(tx, stmt)
+}
+
+// boltPutLatest updates the latest version of the wkis referenced by a
+// statement, unless it is a retraction or older than the current version
+func boltPutLatest(tx *bolt.Tx, stmt *pb.Statement) error {
+	if !isVersion(stmt) {
+		return nil
+	}
+
+	latest := tx.Bucket(boltLatestBucket)
+	for wki, _ := range mcq.StatementRefs(stmt) {
+		key := boltVersionPrefix(wki, stmt.Publisher)
+		val := latest.Get(key)
+		if len(val) > 8 && !versionLess(int64(binary.BigEndian.Uint64(val[:8])), string(val[8:]), stmt.Timestamp, stmt.Id) {
+			continue
+		}
+
+		err := latest.Put(key, boltLatestValue(stmt))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltDeleteVersions deletes the version keys of a statement; the latest
+// versions that pointed to it are deleted and collected in stale, to be
+// recomputed with boltUpdateLatest once the deletion is done.
+func boltDeleteVersions(tx *bolt.Tx, stmt *pb.Statement, stale map[string]bool) error {
+	vers := tx.Bucket(boltVersionBucket)
+	latest := tx.Bucket(boltLatestBucket)
+	for wki, _ := range mcq.StatementRefs(stmt) {
+		err := vers.Delete(boltVersionKey(wki, stmt))
+		if err != nil {
+			return err
+		}
+
+		key := boltVersionPrefix(wki, stmt.Publisher)
+		val := latest.Get(key)
+		if len(val) > 8 && string(val[8:]) == stmt.Id {
+			err = latest.Delete(key)
+			if err != nil {
+				return err
+			}
+			stale[string(key)] = true
+		}
+	}
+	return nil
+}
+
+// boltUpdateLatest recomputes the latest version of a (wki, publisher) from
+// the remaining versions
+func boltUpdateLatest(tx *bolt.Tx, prefix []byte) error {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	end[len(end)-1] = 1
+
+	// step back from the end of the range over retractions, which are not
+	// versions
+	var stmt *pb.Statement
+	cursor := tx.Bucket(boltVersionBucket).Cursor()
+	key, id := cursor.Seek(end)
+	if key == nil {
+		key, id = cursor.Last()
+	} else {
+		key, id = cursor.Prev()
+	}
+
+	for key != nil && bytes.HasPrefix(key, prefix) {
+		xstmts, err := boltGetStatements(tx, [][]byte{id})
+		if err != nil {
+			return err
+		}
+
+		if isVersion(xstmts[0]) {
+			stmt = xstmts[0]
+			break
+		}
+
+		key, id = cursor.Prev()
+	}
+
+	if stmt == nil {
+		return nil
+	}
+
+	return tx.Bucket(boltLatestBucket).Put(prefix, boltLatestValue(stmt))
+}
+
+func boltLatestValue(stmt *pb.Statement) []byte {
+	val := make([]byte, 8+len(stmt.Id))
+	binary.BigEndian.PutUint64(val, uint64(stmt.Timestamp))
+	copy(val[8:], stmt.Id)
+	return val
+}
+
+func boltIndexVersions(tx *bolt.Tx) error {
+	return tx.Bucket(boltStatementBucket).ForEach(func(key, val []byte) error {
+		rec, err := boltDecodeRecord(val)
+		if err != nil {
+			return fmt.Errorf("Error decoding statement %s: %s", key, err.Error())
+		}
+
+		return boltPutVersions(tx, rec.Stmt)
+	})
+}
+
+func boltIndexLatest(tx *bolt.Tx) error {
+	return tx.Bucket(boltStatementBucket).ForEach(func(key, val []byte) error {
+		rec, err := boltDecodeRecord(val)
+		if err != nil {
+			return fmt.Errorf("Error decoding statement %s: %s", key, err.Error())
+		}
+
+		return boltPutLatest(tx, rec.Stmt)
+	})
+}
+
+func boltVersionKey(wki string, stmt *pb.Statement) []byte {
+	prefix := boltVersionPrefix(wki, stmt.Publisher)
+	key := make([]byte, len(prefix)+10+len(stmt.Id))
+	copy(key, prefix)
+	ver := key[len(prefix):]
+	binary.BigEndian.PutUint64(ver, uint64(stmt.Timestamp))
+	binary.BigEndian.PutUint16(ver[8:], uint16(len(stmt.Id)))
+	copy(ver[10:], stmt.Id)
+	return key
+}
+
+// boltVersionPrefix returns the key prefix for the versions of a wki by a
+// publisher, or by all publishers if the publisher is empty
+func boltVersionPrefix(wki string, publisher string) []byte {
+	if publisher == "" {
+		return append([]byte(wki), 0)
+	}
+
+	key := make([]byte, len(wki)+1+len(publisher)+1)
+	copy(key, wki)
+	copy(key[len(wki)+1:], publisher)
+	return key
+}
+
+func boltNamespaceKey(ns string, counter []byte) []byte {
+	key := make([]byte, len(ns)+1+len(counter))
+	copy(key, ns)
+	copy(key[len(ns)+1:], counter)
+	return key
+}
+
+func boltDecodeRecord(val []byte) (*mcq.StatementRecord, error) {
+	if len(val) < 8 {
+		return nil, BadRecord
+	}
+
+	stmt := new(pb.Statement)
+	err := ggproto.Unmarshal(val[8:], stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := int64(binary.BigEndian.Uint64(val[:8]))
+	return &mcq.StatementRecord{Counter: counter, Stmt: stmt}, nil
+}
+
+func (bdb *BoltDB) Get(id string) (stmt *pb.Statement, err error) {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	err = bdb.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(boltStatementBucket).Get([]byte(id))
+		if val == nil {
+			return UnknownStatement
+		}
+
+		rec, err := boltDecodeRecord(val)
+		if err != nil {
+			return err
+		}
+
+		stmt = rec.Stmt
+		return nil
+	})
+
+	return
+}
+
+// boltEvalPlan feeds the statements that may match a plan to its evaluator,
+// using the namespace index when the query namespace allows it.
+func boltEvalPlan(tx *bolt.Tx, plan *mcq.QueryPlan, ev *mcq.QueryEvaluator) error {
+	stmts := tx.Bucket(boltStatementBucket)
+
+	var cursor *bolt.Cursor
+	var prefix []byte
+	ns, exact := plan.ScanPrefix()
+	switch {
+	case ns == "" && !exact:
+		cursor = tx.Bucket(boltEnvelopeBucket).Cursor()
+	case exact:
+		cursor = tx.Bucket(boltNamespaceBucket).Cursor()
+		prefix = append([]byte(ns), 0)
+	default:
+		cursor = tx.Bucket(boltNamespaceBucket).Cursor()
+		prefix = []byte(ns)
+	}
+
+	var key, id []byte
+	if prefix == nil {
+		key, id = cursor.First()
+	} else {
+		key, id = cursor.Seek(prefix)
+	}
+
+	for ; key != nil && bytes.HasPrefix(key, prefix); key, id = cursor.Next() {
+		val := stmts.Get(id)
+		if val == nil {
+			return UnknownStatement
+		}
+
+		rec, err := boltDecodeRecord(val)
+		if err != nil {
+			return err
+		}
+
+		if !ev.Add(rec) {
+			break
+		}
+	}
+
+	ev.End()
+	return nil
+}
+
+func (bdb *BoltDB) Query(q *mcq.Query) ([]interface{}, error) {
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	res := make([]interface{}, 0)
+	err = bdb.db.View(func(tx *bolt.Tx) error {
+		ev := plan.Begin(func(val interface{}) bool {
+			res = append(res, val)
+			return true
+		})
+		return boltEvalPlan(tx, plan, ev)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (bdb *BoltDB) QueryStream(ctx context.Context, q *mcq.Query) (<-chan interface{}, error) {
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+
+		bdb.mx.RLock()
+		defer bdb.mx.RUnlock()
+
+		err := bdb.db.View(func(tx *bolt.Tx) error {
+			ev := plan.Begin(func(val interface{}) bool {
+				select {
+				case ch <- val:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+			return boltEvalPlan(tx, plan, ev)
+		})
+
+		if err != nil {
+			sendStreamError(ctx, ch, err.Error())
+		}
+	}()
+
+	return ch, nil
+}
+
+func (bdb *BoltDB) QueryOne(q *mcq.Query) (interface{}, error) {
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	var res interface{}
+	err = bdb.db.View(func(tx *bolt.Tx) error {
+		ev := plan.Begin(func(val interface{}) bool {
+			res = val
+			return false
+		})
+		return boltEvalPlan(tx, plan, ev)
+	})
+
+	switch {
+	case err != nil:
+		return nil, err
+	case res == nil:
+		return nil, NoResult
+	default:
+		return res, nil
+	}
+}
+
+func (bdb *BoltDB) Delete(q *mcq.Query) (count int, err error) {
+	if q.Op != mcq.OpDelete {
+		return 0, BadQuery
+	}
+
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return 0, err
+	}
+
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	err = bdb.db.Update(func(tx *bolt.Tx) error {
+		ids := make([]string, 0)
+		ev := plan.Begin(func(val interface{}) bool {
+			ids = append(ids, val.(string))
+			return true
+		})
+
+		err := boltEvalPlan(tx, plan, ev)
+		if err != nil {
+			return err
+		}
+
+		// delete after the scan; bolt cursors are invalidated by mutation
+		stmts := tx.Bucket(boltStatementBucket)
+		envs := tx.Bucket(boltEnvelopeBucket)
+		nss := tx.Bucket(boltNamespaceBucket)
+
+		// versions that need a new latest statement
+		stale := make(map[string]bool)
+		for _, id := range ids {
+			xid := []byte(id)
+			val := stmts.Get(xid)
+			if val == nil {
+				continue
+			}
+
+			rec, err := boltDecodeRecord(val)
+			if err != nil {
+				return err
+			}
+
+			counter := val[:8]
+			err = nss.Delete(boltNamespaceKey(rec.Stmt.Namespace, counter))
+			if err != nil {
+				return err
+			}
+
+			err = boltDeleteVersions(tx, rec.Stmt, stale)
+			if err != nil {
+				return err
+			}
+
+			err = envs.Delete(counter)
+			if err != nil {
+				return err
+			}
+
+			err = stmts.Delete(xid)
+			if err != nil {
+				return err
+			}
+		}
+
+		for key, _ := range stale {
+			err = boltUpdateLatest(tx, []byte(key))
+			if err != nil {
+				return err
+			}
+		}
+
+		count = len(ids)
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (bdb *BoltDB) QueryLatest(wki string, publisher string) (stmts []*pb.Statement, err error) {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	err = bdb.db.View(func(tx *bolt.Tx) error {
+		ids := make([][]byte, 0)
+		prefix := boltVersionPrefix(wki, publisher)
+		cursor := tx.Bucket(boltLatestBucket).Cursor()
+		for key, val := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, val = cursor.Next() {
+			if len(val) <= 8 {
+				return BadRecord
+			}
+			ids = append(ids, val[8:])
+		}
+
+		stmts, err = boltGetStatements(tx, ids)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stmts, nil
+}
+
+func (bdb *BoltDB) QueryHistory(wki string, publisher string) (stmts []*pb.Statement, err error) {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	err = bdb.db.View(func(tx *bolt.Tx) error {
+		ids := make([][]byte, 0)
+		prefix := boltVersionPrefix(wki, publisher)
+		cursor := tx.Bucket(boltVersionBucket).Cursor()
+		for key, id := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, id = cursor.Next() {
+			ids = append(ids, id)
+		}
+
+		stmts, err = boltGetStatements(tx, ids)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the index is ordered by publisher first
+	stmts = filterVersions(stmts)
+	sortVersions(stmts)
+	return stmts, nil
+}
+
+func boltGetStatements(tx *bolt.Tx, ids [][]byte) ([]*pb.Statement, error) {
+	stmts := tx.Bucket(boltStatementBucket)
+	res := make([]*pb.Statement, len(ids))
+	for x, id := range ids {
+		val := stmts.Get(id)
+		if val == nil {
+			return nil, UnknownStatement
+		}
+
+		rec, err := boltDecodeRecord(val)
+		if err != nil {
+			return nil, err
+		}
+
+		res[x] = rec.Stmt
+	}
+
+	return res, nil
+}
+
+// Vacuum: bolt reuses freed pages, so an incremental vacuum is a no-op.
+// A full vacuum compacts the database into a fresh file.
+func (bdb *BoltDB) Vacuum(full bool) error {
+	if !full {
+		return nil
+	}
+
+	bdb.mx.Lock()
+	defer bdb.mx.Unlock()
+
+	tmppath := bdb.dbpath + ".vacuum"
+	os.Remove(tmppath)
+
+	err := bdb.compactDB(tmppath)
+	if err != nil {
+		os.Remove(tmppath)
+		return err
+	}
+
+	err = bdb.db.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmppath, bdb.dbpath)
+	if err != nil {
+		return err
+	}
+
+	return bdb.openDB()
+}
+
+func (bdb *BoltDB) compactDB(tmppath string) error {
+	const batch = 1024
+
+	xdb, err := bolt.Open(tmppath, 0644, nil)
+	if err != nil {
+		return err
+	}
+	defer xdb.Close()
+
+	return bdb.db.View(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			src := tx.Bucket(name)
+
+			err := xdb.Update(func(xtx *bolt.Tx) error {
+				dest, err := xtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return dest.SetSequence(src.Sequence())
+			})
+			if err != nil {
+				return err
+			}
+
+			// copy in batches to bound the size of write transactions
+			cursor := src.Cursor()
+			key, val := cursor.First()
+			for key != nil {
+				err = xdb.Update(func(xtx *bolt.Tx) error {
+					dest := xtx.Bucket(name)
+					for x := 0; x < batch && key != nil; x++ {
+						err := dest.Put(key, val)
+						if err != nil {
+							return err
+						}
+						key, val = cursor.Next()
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Check verifies that every statement is indexed by its counter, namespace
+// and versions, and that there are no index entries without a statement.
+// Signatures are verified if verify is not nil.
+func (bdb *BoltDB) Check(verify StatementVerifier) (*DBCheckReport, error) {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	report := new(DBCheckReport)
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		stmts := tx.Bucket(boltStatementBucket)
+		envs := tx.Bucket(boltEnvelopeBucket)
+		nss := tx.Bucket(boltNamespaceBucket)
+		vers := tx.Bucket(boltVersionBucket)
+		latest := tx.Bucket(boltLatestBucket)
+
+		err := stmts.ForEach(func(key, val []byte) error {
+			id := string(key)
+			report.Statements += 1
+
+			rec, err := boltDecodeRecord(val)
+			if err != nil || rec.Stmt.Id != id {
+				report.BadData = append(report.BadData, id)
+				return nil
+			}
+
+			counter := val[:8]
+			if !bytes.Equal(envs.Get(counter), key) ||
+				!bytes.Equal(nss.Get(boltNamespaceKey(rec.Stmt.Namespace, counter)), key) {
+				report.BadEnvelope = append(report.BadEnvelope, id)
+			}
+
+			for wki, _ := range mcq.StatementRefs(rec.Stmt) {
+				if !bytes.Equal(vers.Get(boltVersionKey(wki, rec.Stmt)), key) {
+					report.BadRefs = append(report.BadRefs, id)
+					break
+				}
+			}
+
+			report.checkSignature(rec.Stmt, verify)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		orphans := make(map[string]bool)
+		err = envs.ForEach(func(counter, id []byte) error {
+			val := stmts.Get(id)
+			if val == nil || len(val) < 8 || !bytes.Equal(val[:8], counter) {
+				orphans[string(id)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = nss.ForEach(func(key, id []byte) error {
+			val := stmts.Get(id)
+			if val == nil {
+				orphans[string(id)] = true
+				return nil
+			}
+
+			rec, err := boltDecodeRecord(val)
+			if err != nil {
+				// already reported as bad data
+				return nil
+			}
+
+			if !bytes.Equal(key, boltNamespaceKey(rec.Stmt.Namespace, val[:8])) {
+				orphans[string(id)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = vers.ForEach(func(key, id []byte) error {
+			val := stmts.Get(id)
+			if val == nil {
+				orphans[string(id)] = true
+				return nil
+			}
+
+			rec, err := boltDecodeRecord(val)
+			if err != nil {
+				// already reported as bad data
+				return nil
+			}
+
+			wkiend := bytes.IndexByte(key, 0)
+			if wkiend < 0 {
+				orphans[string(id)] = true
+				return nil
+			}
+
+			wki := string(key[:wkiend])
+			if !mcq.StatementRefs(rec.Stmt)[wki] ||
+				!bytes.Equal(key, boltVersionKey(wki, rec.Stmt)) {
+				orphans[string(id)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = latest.ForEach(func(key, val []byte) error {
+			switch {
+			case len(val) <= 8:
+				orphans[string(key)] = true
+
+			case stmts.Get(val[8:]) == nil:
+				orphans[string(val[8:])] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for id, _ := range orphans {
+			report.Orphans = append(report.Orphans, id)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Rebuild regenerates the Envelope, Namespace, Version and Latest indexes
+// from the statement data. Statement counters are preserved, unless they collide with
+// another statement, in which case the statement is assigned a new counter.
+func (bdb *BoltDB) Rebuild() (count int, err error) {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	err = bdb.db.Update(func(tx *bolt.Tx) error {
+		count = 0
+		seq := tx.Bucket(boltEnvelopeBucket).Sequence()
+
+		for _, name := range [][]byte{boltEnvelopeBucket, boltNamespaceBucket, boltVersionBucket, boltLatestBucket} {
+			err := tx.DeleteBucket(name)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		stmts := tx.Bucket(boltStatementBucket)
+		envs := tx.Bucket(boltEnvelopeBucket)
+		nss := tx.Bucket(boltNamespaceBucket)
+
+		// statements are rewritten after the scan; bolt cursors are
+		// invalidated by mutation
+		recount := make([]*pb.Statement, 0)
+		err := stmts.ForEach(func(key, val []byte) error {
+			rec, err := boltDecodeRecord(val)
+			if err != nil {
+				return fmt.Errorf("Error decoding statement %s: %s", key, err.Error())
+			}
+
+			if rec.Stmt.Id != string(key) {
+				return fmt.Errorf("Statement id mismatch: %s", key)
+			}
+
+			counter := val[:8]
+			if envs.Get(counter) != nil {
+				recount = append(recount, rec.Stmt)
+				return nil
+			}
+
+			if uint64(rec.Counter) > seq {
+				seq = uint64(rec.Counter)
+			}
+
+			err = envs.Put(counter, key)
+			if err != nil {
+				return err
+			}
+
+			err = nss.Put(boltNamespaceKey(rec.Stmt.Namespace, counter), key)
+			if err != nil {
+				return err
+			}
+
+			err = boltPutVersions(tx, rec.Stmt)
+			if err != nil {
+				return err
+			}
+
+			count += 1
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = envs.SetSequence(seq)
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range recount {
+			err = stmts.Delete([]byte(stmt.Id))
+			if err != nil {
+				return err
+			}
+
+			_, err = boltPutStatement(tx, stmt)
+			if err != nil {
+				return err
+			}
+
+			count += 1
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Backup copies the db to dir/stmt/stmt.bolt in a read transaction
+func (bdb *BoltDB) Backup(dir string) error {
+	bdb.mx.RLock()
+	defer bdb.mx.RUnlock()
+
+	return boltBackup(bdb.db, path.Join(dir, "stmt", "stmt.bolt"))
+}
+
+func (bdb *BoltDB) Close() error {
+	bdb.mx.Lock()
+	defer bdb.mx.Unlock()
+
+	return bdb.db.Close()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	bolt "github.com/boltdb/bolt"
+	mc "github.com/mediachain/concat/mc"
+	"os"
+	"path"
+	"time"
+)
+
+// Pure Go datastore backend, on top of bolt.
+// Objects are stored in a single bucket keyed by their hash digest, same as
+// the keys in RocksDS.
+type BoltDS struct {
+	db       *bolt.DB
+	compress bool
+}
+
+var boltDataBucket = []byte("Data")
+
+func (ds *BoltDS) Open(home string) error {
+	dbdir := path.Join(home, "data-bolt")
+	err := os.MkdirAll(dbdir, 0755)
+	if err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(path.Join(dbdir, "data.db"), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltDataBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	ds.db = db
+	return nil
+}
+
+func (ds *BoltDS) Put(data []byte) (Key, error) {
+	key := mc.Hash(data)
+	err := ds.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDataBucket).Put(key[2:], ds.encode(data))
+	})
+	return Key(key), err
+}
+
+func (ds *BoltDS) PutBatch(batch [][]byte) ([]Key, error) {
+	keys := make([]Key, len(batch))
+	err := ds.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDataBucket)
+		for x, data := range batch {
+			key := mc.Hash(data)
+			err := bucket.Put(key[2:], ds.encode(data))
+			if err != nil {
+				return err
+			}
+			keys[x] = Key(key)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (ds *BoltDS) Has(key Key) (have bool, err error) {
+	err = ds.db.View(func(tx *bolt.Tx) error {
+		have = tx.Bucket(boltDataBucket).Get(key[2:]) != nil
+		return nil
+	})
+	return
+}
+
+// Get returns nil if the object is not present, like RocksDS
+func (ds *BoltDS) Get(key Key) (data []byte, err error) {
+	err = ds.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(boltDataBucket).Get(key[2:])
+		if val != nil {
+			// bolt values are only valid for the life of the transaction
+			data = make([]byte, len(val))
+			copy(data, val)
+		}
+		return nil
+	})
+
+	if data != nil {
+		data = dsDecompress(key, data)
+	}
+
+	return
+}
+
+func (ds *BoltDS) encode(data []byte) []byte {
+	if ds.compress {
+		return dsCompress(data)
+	}
+	return data
+}
+
+func (ds *BoltDS) Delete(key Key) error {
+	return ds.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDataBucket).Delete(key[2:])
+	})
+}
+
+// Sync is a no-op; bolt syncs on every commit
+func (ds *BoltDS) Sync() error {
+	return nil
+}
+
+// IterKeys scans the keys in batches, each in its own read transaction and
+// resuming after the last key of the previous batch, so that a slow consumer
+// doesn't hold a transaction open and writes during the iteration can reuse
+// freed pages.
+func (ds *BoltDS) IterKeys(ctx context.Context) (<-chan Key, <-chan error, error) {
+	const batch = 1024
+
+	ch := make(chan Key)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(ch)
+
+		var start []byte
+		for {
+			keys, err := ds.scanKeys(start, batch)
+			if err != nil {
+				errc <- err
+				return
+			}
+
+			for _, key := range keys {
+				select {
+				case ch <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if len(keys) < batch {
+				return
+			}
+			start = keys[len(keys)-1][2:]
+		}
+	}()
+	return ch, errc, nil
+}
+
+// scanKeys returns up to limit keys after start, or from the first key if
+// start is nil
+func (ds *BoltDS) scanKeys(start []byte, limit int) (keys []Key, err error) {
+	err = ds.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltDataBucket).Cursor()
+
+		var k []byte
+		if start == nil {
+			k, _ = cursor.First()
+		} else {
+			k, _ = cursor.Seek(start)
+			if k != nil && bytes.Equal(k, start) {
+				k, _ = cursor.Next()
+			}
+		}
+
+		for ; k != nil && len(keys) < limit; k, _ = cursor.Next() {
+			// HashFromBytes copies the key out of the transaction
+			keys = append(keys, Key(mc.HashFromBytes(k)))
+		}
+		return nil
+	})
+	return
+}
+
+// Compact is a no-op; bolt reuses freed pages
+func (ds *BoltDS) Compact() {}
+
+// Backup copies the datastore to dir/data-bolt/data.db in a read transaction
+func (ds *BoltDS) Backup(dir string) error {
+	return boltBackup(ds.db, path.Join(dir, "data-bolt", "data.db"))
+}
+
+func (ds *BoltDS) Close() {
+	ds.db.Close()
+}
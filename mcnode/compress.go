@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	mc "github.com/mediachain/concat/mc"
+	"io"
+	"io/ioutil"
+)
+
+// Datastore value compression
+// Compressed values are deflated and framed with a magic prefix; keys are
+// always the hash of the uncompressed content. A framed value is only
+// accepted if it inflates to content that hashes to its key, so raw values
+// that happen to start with the magic are returned as is.
+// Values are only stored compressed when that saves space, which lets raw
+// and compressed values coexist; compression can be turned on and off at
+// any time, and existing values are compressed as they are rewritten
+// (eg by migration).
+var dsCompressMagic = []byte{0x00, 'm', 'c', 'z'}
+
+func dsCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(dsCompressMagic)
+
+	err := deflateData(&buf, data)
+	if err != nil || buf.Len() >= len(data) {
+		return data
+	}
+
+	return buf.Bytes()
+}
+
+func dsDecompress(key Key, val []byte) []byte {
+	if !bytes.HasPrefix(val, dsCompressMagic) {
+		return val
+	}
+
+	data, err := inflateData(val[len(dsCompressMagic):])
+	if err != nil || !bytes.Equal([]byte(key), []byte(mc.Hash(data))) {
+		return val
+	}
+
+	return data
+}
+
+func deflateData(buf *bytes.Buffer, data []byte) error {
+	w, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func inflateData(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// compressed transfer in /mediachain/node/data
+// Clients ask for compression in the request and servers flag compressed
+// objects, so that peers that don't know about compression interoperate.
+func deflateTransfer(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	err := deflateData(&buf, data)
+	if err != nil || buf.Len() >= len(data) {
+		return data, false
+	}
+
+	return buf.Bytes(), true
+}
+
+// inflateTransfer decompresses a transferred object; objects are bounded by
+// the maximum message size, compressed or not.
+func inflateTransfer(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	xdata, err := ioutil.ReadAll(io.LimitReader(r, mc.MaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(xdata) > mc.MaxMessageSize {
+		return nil, BadData
+	}
+
+	return xdata, nil
+}
@@ -1,3 +1,6 @@
+//go:build !nosqlite
+// +build !nosqlite
+
 package main
 
 import (
@@ -18,10 +21,18 @@ type SQLDB struct {
 	insertStmtData     *sql.Stmt
 	insertStmtEnvelope *sql.Stmt
 	insertStmtRefs     *sql.Stmt
+	insertStmtRetracts *sql.Stmt
+	insertStmtLatest   *sql.Stmt
 	selectStmtData     *sql.Stmt
+	selectStmtLatest   *sql.Stmt
+	selectLatest       *sql.Stmt
+	selectHistory      *sql.Stmt
 	deleteStmtData     *sql.Stmt
 	deleteStmtEnvelope *sql.Stmt
 	deleteStmtRefs     *sql.Stmt
+	deleteStmtRetracts *sql.Stmt
+	deleteStmtLatest   *sql.Stmt
+	selectVersions     *sql.Stmt
 	wlock              sync.Mutex
 }
 
@@ -53,13 +64,28 @@ func (sdb *SQLDB) Put(stmt *pb.Statement) error {
 		return err
 	}
 
-	xstmt = tx.Stmt(sdb.insertStmtRefs)
+	insertRefs := tx.Stmt(sdb.insertStmtRefs)
+	insertLatest := tx.Stmt(sdb.insertStmtLatest)
 	for wki, _ := range mcq.StatementRefs(stmt) {
-		_, err = xstmt.Exec(stmt.Id, wki)
+		_, err = insertRefs.Exec(stmt.Id, wki)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
+
+		if isVersion(stmt) {
+			_, err = insertLatest.Exec(wki, stmt.Publisher, stmt.Id, stmt.Timestamp)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	err = sqlInsertRetracts(tx.Stmt(sdb.insertStmtRetracts), stmt)
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	return tx.Commit()
@@ -77,6 +103,8 @@ func (sdb *SQLDB) PutBatch(stmts []*pb.Statement) error {
 	insertData := tx.Stmt(sdb.insertStmtData)
 	insertEnvelope := tx.Stmt(sdb.insertStmtEnvelope)
 	insertRefs := tx.Stmt(sdb.insertStmtRefs)
+	insertRetracts := tx.Stmt(sdb.insertStmtRetracts)
+	insertLatest := tx.Stmt(sdb.insertStmtLatest)
 
 	for _, stmt := range stmts {
 		bytes, err := ggproto.Marshal(stmt)
@@ -103,6 +131,20 @@ func (sdb *SQLDB) PutBatch(stmts []*pb.Statement) error {
 				tx.Rollback()
 				return err
 			}
+
+			if isVersion(stmt) {
+				_, err = insertLatest.Exec(wki, stmt.Publisher, stmt.Id, stmt.Timestamp)
+				if err != nil {
+					tx.Rollback()
+					return err
+				}
+			}
+		}
+
+		err = sqlInsertRetracts(insertRetracts, stmt)
+		if err != nil {
+			tx.Rollback()
+			return err
 		}
 	}
 
@@ -232,10 +274,28 @@ func (sdb *SQLDB) Delete(q *mcq.Query) (count int, err error) {
 	delData := tx.Stmt(sdb.deleteStmtData)
 	delEnvelope := tx.Stmt(sdb.deleteStmtEnvelope)
 	delRefs := tx.Stmt(sdb.deleteStmtRefs)
+	delRetracts := tx.Stmt(sdb.deleteStmtRetracts)
+	delLatest := tx.Stmt(sdb.deleteStmtLatest)
+	selLatest := tx.Stmt(sdb.selectStmtLatest)
+
+	// versions that need a new latest statement
+	stale := make(map[sqlLatestKey]bool)
 
 	for val := range ch {
 		switch id := val.(type) {
 		case string:
+			err = sqlSelectLatest(selLatest, id, stale)
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+
+			_, err = delLatest.Exec(id)
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+
 			_, err = delData.Exec(id)
 			if err != nil {
 				tx.Rollback()
@@ -254,6 +314,12 @@ func (sdb *SQLDB) Delete(q *mcq.Query) (count int, err error) {
 				return 0, err
 			}
 
+			_, err = delRetracts.Exec(id)
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+
 			count += 1
 
 		case StreamError:
@@ -266,6 +332,16 @@ func (sdb *SQLDB) Delete(q *mcq.Query) (count int, err error) {
 		}
 	}
 
+	selVersions := tx.Stmt(sdb.selectVersions)
+	insertLatest := tx.Stmt(sdb.insertStmtLatest)
+	for key, _ := range stale {
+		err = sqlUpdateLatest(selVersions, insertLatest, key)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return 0, err
@@ -274,43 +350,95 @@ func (sdb *SQLDB) Delete(q *mcq.Query) (count int, err error) {
 	return count, nil
 }
 
-func (sdb *SQLDB) Close() error {
-	return sdb.db.Close()
+type sqlLatestKey struct {
+	wki       string
+	publisher string
 }
 
-func (sdb *SQLDB) createTables() error {
-	_, err := sdb.db.Exec("CREATE TABLE Statement (id VARCHAR(128) PRIMARY KEY, data VARBINARY)")
+func sqlSelectLatest(selectLatest *sql.Stmt, id string, keys map[sqlLatestKey]bool) error {
+	rows, err := selectLatest.Query(id)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	_, err = sdb.db.Exec("CREATE TABLE Envelope (counter INTEGER PRIMARY KEY AUTOINCREMENT, id VARCHAR(128), namespace VARCHAR, publisher VARCHAR, source VARCHAR, timestamp INTEGER)")
-	if err != nil {
-		return err
+	for rows.Next() {
+		var key sqlLatestKey
+		err = rows.Scan(&key.wki, &key.publisher)
+		if err != nil {
+			return err
+		}
+		keys[key] = true
 	}
 
-	_, err = sdb.db.Exec("CREATE UNIQUE INDEX EnvelopeId ON Envelope (id)")
+	return rows.Err()
+}
+
+// sqlUpdateLatest recomputes the latest version of a (wki, publisher) from
+// the remaining statements
+func sqlUpdateLatest(selectVersions, insertLatest *sql.Stmt, key sqlLatestKey) error {
+	stmts, err := sqlQueryStatements(selectVersions, key.wki, key.publisher)
 	if err != nil {
 		return err
 	}
 
-	_, err = sdb.db.Exec("CREATE INDEX EnvelopeNS ON Envelope (namespace)")
+	for _, stmt := range stmts {
+		if isVersion(stmt) {
+			_, err = insertLatest.Exec(key.wki, key.publisher, stmt.Id, stmt.Timestamp)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sdb *SQLDB) QueryLatest(wki string, publisher string) ([]*pb.Statement, error) {
+	return sqlQueryStatements(sdb.selectLatest, wki, publisher)
+}
+
+func (sdb *SQLDB) QueryHistory(wki string, publisher string) ([]*pb.Statement, error) {
+	stmts, err := sqlQueryStatements(sdb.selectHistory, wki, publisher)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = sdb.db.Exec("CREATE TABLE Refs (id VARCHAR(128), wki VARCHAR)")
+	return filterVersions(stmts), nil
+}
+
+func sqlQueryStatements(sq *sql.Stmt, args ...interface{}) ([]*pb.Statement, error) {
+	rows, err := sq.Query(args...)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*pb.Statement, 0)
+	for rows.Next() {
+		var bytes []byte
+		err = rows.Scan(&bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		stmt := new(pb.Statement)
+		err = ggproto.Unmarshal(bytes, stmt)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, stmt)
 	}
 
-	_, err = sdb.db.Exec("CREATE INDEX RefsId ON Refs (id)")
+	err = rows.Err()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = sdb.db.Exec("CREATE INDEX RefsWki ON Refs (wki)")
-	return err
+	return res, nil
+}
+
+func (sdb *SQLDB) Close() error {
+	return sdb.db.Close()
 }
 
 func (sdb *SQLDB) prepareStatements() error {
@@ -332,12 +460,42 @@ func (sdb *SQLDB) prepareStatements() error {
 	}
 	sdb.insertStmtRefs = stmt
 
+	stmt, err = sdb.db.Prepare("INSERT INTO Retracts VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	sdb.insertStmtRetracts = stmt
+
+	stmt, err = sdb.db.Prepare(fmt.Sprintf(sqlLatestUpsert, "Latest"))
+	if err != nil {
+		return err
+	}
+	sdb.insertStmtLatest = stmt
+
 	stmt, err = sdb.db.Prepare("SELECT data FROM Statement WHERE id = ?")
 	if err != nil {
 		return err
 	}
 	sdb.selectStmtData = stmt
 
+	stmt, err = sdb.db.Prepare("SELECT wki, publisher FROM Latest WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	sdb.selectStmtLatest = stmt
+
+	stmt, err = sdb.db.Prepare("SELECT Statement.data FROM Latest JOIN Statement ON Latest.id = Statement.id WHERE Latest.wki = ?1 AND (?2 = '' OR Latest.publisher = ?2) ORDER BY Latest.publisher")
+	if err != nil {
+		return err
+	}
+	sdb.selectLatest = stmt
+
+	stmt, err = sdb.db.Prepare("SELECT Statement.data FROM Refs JOIN Envelope ON Refs.id = Envelope.id JOIN Statement ON Refs.id = Statement.id WHERE Refs.wki = ?1 AND (?2 = '' OR Envelope.publisher = ?2) ORDER BY Envelope.timestamp, LENGTH(Envelope.id), Envelope.id")
+	if err != nil {
+		return err
+	}
+	sdb.selectHistory = stmt
+
 	stmt, err = sdb.db.Prepare("DELETE FROM Statement WHERE id = ?")
 	if err != nil {
 		return err
@@ -356,12 +514,37 @@ func (sdb *SQLDB) prepareStatements() error {
 	}
 	sdb.deleteStmtRefs = stmt
 
+	stmt, err = sdb.db.Prepare("DELETE FROM Retracts WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	sdb.deleteStmtRetracts = stmt
+
+	stmt, err = sdb.db.Prepare("DELETE FROM Latest WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	sdb.deleteStmtLatest = stmt
+
+	// the versions of a (wki, publisher), latest first, for recomputing the
+	// latest version from the remaining statements
+	stmt, err = sdb.db.Prepare("SELECT Statement.data FROM Refs JOIN Envelope ON Refs.id = Envelope.id JOIN Statement ON Refs.id = Statement.id WHERE Refs.wki = ? AND Envelope.publisher = ? ORDER BY Envelope.timestamp DESC, LENGTH(Envelope.id) DESC, Envelope.id DESC")
+	if err != nil {
+		return err
+	}
+	sdb.selectVersions = stmt
+
 	return nil
 }
 
 // SQLite backend
+func newSQLiteDB() (StatementDB, error) {
+	return &SQLiteDB{}, nil
+}
+
 type SQLiteDB struct {
 	SQLDB
+	dbpath string
 }
 
 func (sdb *SQLiteDB) Open(home string) error {
@@ -398,11 +581,11 @@ func (sdb *SQLiteDB) Open(home string) error {
 		if err != nil {
 			return err
 		}
+	}
 
-		err = sdb.createTables()
-		if err != nil {
-			return err
-		}
+	err = sdb.migrateSchema()
+	if err != nil {
+		return err
 	}
 
 	return sdb.prepareStatements()
@@ -415,9 +598,52 @@ func (sdb *SQLiteDB) openDB(dbpath string) error {
 	}
 
 	sdb.db = db
+	sdb.dbpath = dbpath
 	return nil
 }
 
+// Backup copies the db to dir/stmt/stmt.db with the sqlite online backup api
+func (sdb *SQLiteDB) Backup(dir string) error {
+	if sdb.dbpath == ":memory:" {
+		return BackupUnsupported
+	}
+
+	return sqliteBackup(sdb.dbpath, path.Join(dir, "stmt", "stmt.db"))
+}
+
+func sqliteBackup(src, dest string) error {
+	err := os.MkdirAll(path.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+
+	drv := &sqlite3.SQLiteDriver{}
+	xsrc, err := drv.Open(src)
+	if err != nil {
+		return err
+	}
+	defer xsrc.Close()
+
+	xdest, err := drv.Open(dest)
+	if err != nil {
+		return err
+	}
+	defer xdest.Close()
+
+	bk, err := xdest.(*sqlite3.SQLiteConn).Backup("main", xsrc.(*sqlite3.SQLiteConn), "main")
+	if err != nil {
+		return err
+	}
+
+	_, err = bk.Step(-1)
+	if err != nil {
+		bk.Finish()
+		return err
+	}
+
+	return bk.Finish()
+}
+
 func (sdb *SQLiteDB) tuneDB() error {
 	_, err := sdb.db.Exec("PRAGMA journal_mode=WAL")
 	if err != nil {
@@ -452,6 +678,8 @@ func (sdb *SQLiteDB) MergeBatch(stmts []*pb.Statement) (count int, err error) {
 	insertData := tx.Stmt(sdb.insertStmtData)
 	insertEnvelope := tx.Stmt(sdb.insertStmtEnvelope)
 	insertRefs := tx.Stmt(sdb.insertStmtRefs)
+	insertRetracts := tx.Stmt(sdb.insertStmtRetracts)
+	insertLatest := tx.Stmt(sdb.insertStmtLatest)
 
 	for _, stmt := range stmts {
 		bytes, err := ggproto.Marshal(stmt)
@@ -482,6 +710,20 @@ func (sdb *SQLiteDB) MergeBatch(stmts []*pb.Statement) (count int, err error) {
 				tx.Rollback()
 				return 0, err
 			}
+
+			if isVersion(stmt) {
+				_, err = insertLatest.Exec(wki, stmt.Publisher, stmt.Id, stmt.Timestamp)
+				if err != nil {
+					tx.Rollback()
+					return 0, err
+				}
+			}
+		}
+
+		err = sqlInsertRetracts(insertRetracts, stmt)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
 		}
 
 		count += 1
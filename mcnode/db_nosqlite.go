@@ -0,0 +1,14 @@
+//go:build nosqlite
+// +build nosqlite
+
+package main
+
+import (
+	"errors"
+)
+
+// Builds with the nosqlite tag omit SQLiteDB and the cgo sqlite dependency;
+// use the bolt statement db backend instead.
+func newSQLiteDB() (StatementDB, error) {
+	return nil, errors.New("SQLite statement db backend not available in this build")
+}
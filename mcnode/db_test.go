@@ -0,0 +1,285 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package main
+
+import (
+	pb "github.com/mediachain/concat/proto"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteRebuild(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	db := &SQLiteDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+
+	version, err := db.schemaVersion()
+	checkError(t, "schemaVersion", err)
+	checkBool(t, "schemaVersion", version == len(sqlMigrations))
+
+	a := makeTestStatement("a", "foo", "A", 100)
+	b := makeTestStatement("b", "foo", "B", 200)
+	c := makeTestStatement("c", "bar", "A", 300)
+	err = db.PutBatch([]*pb.Statement{a, b, c})
+	checkError(t, "PutBatch", err)
+
+	// damage the derived tables
+	_, err = db.db.Exec("INSERT INTO Envelope VALUES (NULL, 'x', 'foo', 'X', 'X', 400)")
+	checkError(t, "Exec", err)
+	_, err = db.db.Exec("DELETE FROM Envelope WHERE id = 'a'")
+	checkError(t, "Exec", err)
+	_, err = db.db.Exec("DELETE FROM Refs WHERE id = 'b'")
+	checkError(t, "Exec", err)
+
+	count, err := db.Rebuild()
+	checkError(t, "Rebuild", err)
+	checkBool(t, "Rebuild count", count == 3)
+
+	res, err := db.Query(parseQuery(t, "SELECT (id, counter) FROM * ORDER BY counter"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query", len(res) == 3)
+	if len(res) == 3 {
+		// b and c keep their counters, a is appended without reusing 4
+		xres := []interface{}{
+			map[string]interface{}{"id": "b", "counter": int64(2)},
+			map[string]interface{}{"id": "c", "counter": int64(3)},
+			map[string]interface{}{"id": "a", "counter": int64(5)}}
+		checkBool(t, "Query", reflect.DeepEqual(res, xres))
+	}
+
+	res, err = db.Query(parseQuery(t, "SELECT id FROM * WHERE wki = wki:b"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query refs", len(res) == 1 && res[0] == "b")
+
+	err = db.Close()
+	checkError(t, "Close", err)
+
+	// databases created before schema versioning are at version 1
+	xdb := &SQLiteDB{}
+	err = xdb.openDB(path.Join(home, "stmt", "stmt.db"))
+	checkError(t, "openDB", err)
+	_, err = xdb.db.Exec("DROP TABLE Schema")
+	checkError(t, "Exec", err)
+	version, err = xdb.schemaVersion()
+	checkError(t, "schemaVersion", err)
+	checkBool(t, "schemaVersion", version == 1)
+	xdb.Close()
+}
+
+func TestSQLiteCheck(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	db := &SQLiteDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+	defer db.Close()
+
+	a := makeTestStatement("a", "foo", "A", 100)
+	b := makeTestStatement("b", "foo", "B", 200)
+	c := makeTestStatement("c", "bar", "A", 300)
+	err = db.PutBatch([]*pb.Statement{a, b, c})
+	checkError(t, "PutBatch", err)
+
+	report, err := db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check", report.Statements == 3 && report.OK())
+
+	// damage the derived tables
+	_, err = db.db.Exec("UPDATE Envelope SET namespace = 'baz' WHERE id = 'a'")
+	checkError(t, "Exec", err)
+	_, err = db.db.Exec("DELETE FROM Refs WHERE id = 'b'")
+	checkError(t, "Exec", err)
+	_, err = db.db.Exec("INSERT INTO Refs VALUES ('x', 'wki:x')")
+	checkError(t, "Exec", err)
+
+	report, err = db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check envelope", reflect.DeepEqual(report.BadEnvelope, []string{"a"}))
+	checkBool(t, "Check refs", reflect.DeepEqual(report.BadRefs, []string{"b"}))
+	checkBool(t, "Check orphans", reflect.DeepEqual(report.Orphans, []string{"x"}))
+	checkBool(t, "Check data", len(report.BadData) == 0)
+
+	// signatures are checked with the verifier
+	report, err = db.Check(func(stmt *pb.Statement) (bool, error) {
+		return stmt.Id != "c", nil
+	})
+	checkError(t, "Check", err)
+	checkBool(t, "Check signature", reflect.DeepEqual(report.BadSignature, []string{"c"}))
+
+	_, err = db.Rebuild()
+	checkError(t, "Rebuild", err)
+
+	report, err = db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check after rebuild", report.Statements == 3 && report.OK())
+}
+
+func TestSQLiteLatest(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	db := &SQLiteDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+
+	a1 := makeTestVersion("A:100:9", "A", "wki:x", 100)
+	a2 := makeTestVersion("A:100:10", "A", "wki:x", 100)
+	b1 := makeTestVersion("B:200:0", "B", "wki:x", 200)
+	// retractions are not versions
+	r := makeTestRetraction("A:300:0", "A", []string{"wki:x"}, 300)
+	err = db.PutBatch([]*pb.Statement{a1, a2, b1, r})
+	checkError(t, "PutBatch", err)
+
+	// older versions merged later don't replace the latest
+	a0 := makeTestVersion("A:50:0", "A", "wki:x", 50)
+	_, err = db.MergeBatch([]*pb.Statement{a0})
+	checkError(t, "MergeBatch", err)
+
+	res, err := db.QueryLatest("wki:x", "")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest", reflect.DeepEqual(stmtIds(res), []string{"A:100:10", "B:200:0"}))
+
+	res, err = db.QueryLatest("wki:x", "A")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest publisher", reflect.DeepEqual(stmtIds(res), []string{"A:100:10"}))
+
+	res, err = db.QueryHistory("wki:x", "")
+	checkError(t, "QueryHistory", err)
+	checkBool(t, "QueryHistory", reflect.DeepEqual(stmtIds(res), []string{"A:50:0", "A:100:9", "A:100:10", "B:200:0"}))
+
+	res, err = db.QueryHistory("wki:x", "B")
+	checkError(t, "QueryHistory", err)
+	checkBool(t, "QueryHistory publisher", reflect.DeepEqual(stmtIds(res), []string{"B:200:0"}))
+
+	// deleting the latest version falls back to the previous one
+	_, err = db.Delete(parseQuery(t, "DELETE FROM * WHERE id = A:100:10"))
+	checkError(t, "Delete", err)
+
+	res, err = db.QueryLatest("wki:x", "A")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest after delete", reflect.DeepEqual(stmtIds(res), []string{"A:100:9"}))
+
+	report, err := db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check", report.OK())
+
+	err = db.Close()
+	checkError(t, "Close", err)
+
+	// the view and the retraction index are populated when migrating from
+	// version 1, which indexed retractions as wkis
+	xdb := &SQLiteDB{}
+	err = xdb.openDB(path.Join(home, "stmt", "stmt.db"))
+	checkError(t, "openDB", err)
+	_, err = xdb.db.Exec("DROP TABLE Latest")
+	checkError(t, "Exec", err)
+	_, err = xdb.db.Exec("DROP TABLE Retracts")
+	checkError(t, "Exec", err)
+	_, err = xdb.db.Exec("INSERT INTO Refs VALUES ('A:300:0', 'wki:x')")
+	checkError(t, "Exec", err)
+	_, err = xdb.db.Exec("UPDATE Schema SET version = 1")
+	checkError(t, "Exec", err)
+	xdb.Close()
+
+	db = &SQLiteDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+	defer db.Close()
+
+	res, err = db.QueryLatest("wki:x", "")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest after migration", reflect.DeepEqual(stmtIds(res), []string{"A:100:9", "B:200:0"}))
+
+	xres, err := db.Query(parseQuery(t, "SELECT id FROM * WHERE retracts = wki:x"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query retracts after migration", len(xres) == 1 && xres[0] == "A:300:0")
+
+	xres, err = db.Query(parseQuery(t, "SELECT id FROM * WHERE wki = wki:x AND publisher = A"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query wki after migration", len(xres) == 2)
+
+	report, err = db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check after migration", report.OK())
+}
+
+func TestSQLiteNamespaceStats(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	db := &SQLiteDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+	defer db.Close()
+	node.db = db
+
+	checkNamespaceStats(t, node)
+}
+
+func TestSQLiteBackup(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	db := &SQLiteDB{}
+	err = db.Open(path.Join(home, "node"))
+	checkError(t, "Open", err)
+	defer db.Close()
+
+	a := makeTestStatement("a", "foo", "A", 100)
+	b := makeTestStatement("b", "foo", "B", 200)
+	err = db.PutBatch([]*pb.Statement{a, b})
+	checkError(t, "PutBatch", err)
+
+	backup := path.Join(home, "backup")
+	err = db.Backup(backup)
+	checkError(t, "Backup", err)
+
+	// the backup is taken online; later writes are not included
+	c := makeTestStatement("c", "bar", "A", 300)
+	err = db.Put(c)
+	checkError(t, "Put", err)
+
+	for _, dest := range []string{backup, path.Join(home, "backup.tar.gz")} {
+		if dest != backup {
+			err = backupWriteTarball(backup, dest)
+			checkError(t, "backupWriteTarball", err)
+		}
+
+		restored := path.Join(home, "restored")
+		err = restoreBackup(restored, dest)
+		checkError(t, "restoreBackup", err)
+
+		err = restoreBackup(restored, dest)
+		checkBool(t, "restoreBackup conflict", err == RestoreConflict)
+
+		xdb := &SQLiteDB{}
+		err = xdb.Open(restored)
+		checkError(t, "Open", err)
+
+		val, err := xdb.QueryOne(parseQuery(t, "SELECT COUNT(*) FROM *"))
+		checkError(t, "QueryOne", err)
+		checkBool(t, "QueryOne count", val == 2)
+
+		_, err = xdb.Get("b")
+		checkError(t, "Get", err)
+		xdb.Close()
+
+		os.RemoveAll(restored)
+	}
+}
@@ -1,3 +1,6 @@
+//go:build !norocks
+// +build !norocks
+
 package main
 
 import (
@@ -11,11 +14,16 @@ import (
 	"strconv"
 )
 
+func newRocksDS(compress bool) (Datastore, error) {
+	return &RocksDS{compress: compress}, nil
+}
+
 type RocksDS struct {
-	db *rocksdb.DB
-	ro *rocksdb.ReadOptions
-	wo *rocksdb.WriteOptions
-	fo *rocksdb.FlushOptions
+	db       *rocksdb.DB
+	ro       *rocksdb.ReadOptions
+	wo       *rocksdb.WriteOptions
+	fo       *rocksdb.FlushOptions
+	compress bool
 }
 
 func (ds *RocksDS) Open(home string) error {
@@ -66,7 +74,7 @@ func (ds *RocksDS) Open(home string) error {
 
 func (ds *RocksDS) Put(data []byte) (Key, error) {
 	key := mc.Hash(data)
-	err := ds.db.Put(ds.wo, key[2:], data)
+	err := ds.db.Put(ds.wo, key[2:], ds.encode(data))
 	return Key(key), err
 }
 
@@ -77,7 +85,7 @@ func (ds *RocksDS) PutBatch(batch [][]byte) ([]Key, error) {
 
 	for x, data := range batch {
 		key := mc.Hash(data)
-		wb.Put(key[2:], data)
+		wb.Put(key[2:], ds.encode(data))
 		keys[x] = Key(key)
 	}
 
@@ -102,7 +110,19 @@ func (ds *RocksDS) Has(key Key) (bool, error) {
 }
 
 func (ds *RocksDS) Get(key Key) ([]byte, error) {
-	return ds.db.GetBytes(ds.ro, key[2:])
+	val, err := ds.db.GetBytes(ds.ro, key[2:])
+	if err != nil || val == nil {
+		return val, err
+	}
+
+	return dsDecompress(key, val), nil
+}
+
+func (ds *RocksDS) encode(data []byte) []byte {
+	if ds.compress {
+		return dsCompress(data)
+	}
+	return data
 }
 
 func (ds *RocksDS) Delete(key Key) error {
@@ -113,20 +133,21 @@ func (ds *RocksDS) Sync() error {
 	return ds.db.Flush(ds.fo)
 }
 
-func (ds *RocksDS) IterKeys(ctx context.Context) (<-chan Key, error) {
+func (ds *RocksDS) IterKeys(ctx context.Context) (<-chan Key, <-chan error, error) {
 	err := ds.Sync()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	ch := make(chan Key)
+	errc := make(chan error, 1)
 	go func() {
+		defer close(errc)
 		defer close(ch)
 
 		it := ds.db.NewIterator(ds.ro)
 		defer it.Close()
 
-	loop:
 		for it.SeekToFirst(); it.Valid(); it.Next() {
 			kslice := it.Key()
 			key := mc.HashFromBytes(kslice.Data())
@@ -134,17 +155,34 @@ func (ds *RocksDS) IterKeys(ctx context.Context) (<-chan Key, error) {
 			select {
 			case ch <- Key(key):
 			case <-ctx.Done():
-				break loop
+				return
 			}
 		}
+
+		err := it.Err()
+		if err != nil {
+			errc <- err
+		}
 	}()
-	return ch, nil
+	return ch, errc, nil
 }
 
 func (ds *RocksDS) Compact() {
 	ds.db.CompactRange(rocksdb.Range{})
 }
 
+// Backup creates a checkpoint of the datastore in dir/data; sst files are
+// hard linked when dir is in the same filesystem.
+func (ds *RocksDS) Backup(dir string) error {
+	ckp, err := ds.db.NewCheckpoint()
+	if err != nil {
+		return err
+	}
+	defer ckp.Destroy()
+
+	return ckp.CreateCheckpoint(path.Join(dir, "data"), 0)
+}
+
 func (ds *RocksDS) Close() {
 	ds.db.Close()
 }
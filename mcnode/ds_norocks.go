@@ -0,0 +1,14 @@
+//go:build norocks
+// +build norocks
+
+package main
+
+import (
+	"errors"
+)
+
+// Builds with the norocks tag omit RocksDS and the gorocksdb dependency;
+// use the bolt datastore backend instead.
+func newRocksDS(compress bool) (Datastore, error) {
+	return nil, errors.New("RocksDB datastore backend not available in this build")
+}
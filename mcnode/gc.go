@@ -2,36 +2,216 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	sqlite3 "github.com/mattn/go-sqlite3"
+	mc "github.com/mediachain/concat/mc"
 	mcq "github.com/mediachain/concat/mc/query"
 	pb "github.com/mediachain/concat/proto"
 	multihash "github.com/multiformats/go-multihash"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
 )
 
 var (
-	NodeMustBeOffline = errors.New("Node must be offline")
+	GCInProgress = errors.New("Garbage collection already in progress")
 )
 
+// doGC collects objects unreferenced by any statement and not pinned; it
+// runs online.
+// Objects referenced by statements deleted during the gc survive until the
+// next gc.
 func (node *Node) doGC(ctx context.Context) (int, error) {
-	if node.status != StatusOffline {
-		return 0, NodeMustBeOffline
+	writers, err := node.gcs.begin()
+	if err != nil {
+		return 0, err
 	}
+	defer node.gcs.end()
 
-	gc := &GCDB{}
-	err := gc.Open(node.home)
+	gc := &GCDB{pins: node.pindb}
+	err = gc.Open(node.home)
 	if err != nil {
 		return 0, err
 	}
 	defer gc.Close()
 
+	err = gcWait(ctx, writers)
+	if err != nil {
+		return 0, err
+	}
+
 	err = gc.Merge(ctx, node.db)
 	if err != nil {
 		return 0, err
 	}
 
-	return gc.GC(ctx, node.ds)
+	return gc.GC(ctx, node.ds, &node.gcs)
+}
+
+// doGCDryRun returns the keys of the objects that would be collected by a gc,
+// without deleting anything.
+func (node *Node) doGCDryRun(ctx context.Context) ([]string, error) {
+	gc := &GCDB{pins: node.pindb}
+	err := gc.Open(node.home)
+	if err != nil {
+		return nil, err
+	}
+	defer gc.Close()
+
+	err = gc.Merge(ctx, node.db)
+	if err != nil {
+		return nil, err
+	}
+
+	garbage, err := gc.scan(ctx, node.ds, &node.gcs)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(garbage))
+	for key58, _ := range garbage {
+		keys = append(keys, key58)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// GC epochs
+// While a gc is running, writers record the keys of the objects they write
+// and of the objects referenced by the statements they commit, so that they
+// are not collected by the sweep. Writers that started before the gc may
+// have written objects or statements without recording them; the gc waits
+// for them to finish before building the reference set.
+// Writers register with beginWrite and record keys before writing objects
+// or committing statements, so that a sweep never sees a committed reference
+// to an unrecorded object.
+type GCState struct {
+	mx      sync.Mutex
+	live    map[string]bool // nil when no gc is running
+	writers *sync.WaitGroup // writers in the current epoch
+}
+
+// beginWrite registers a writer in the current epoch; the writer must call
+// Done on the result when it is finished.
+func (gcs *GCState) beginWrite() *sync.WaitGroup {
+	gcs.mx.Lock()
+	defer gcs.mx.Unlock()
+
+	if gcs.writers == nil {
+		gcs.writers = new(sync.WaitGroup)
+	}
+
+	wg := gcs.writers
+	wg.Add(1)
+	return wg
+}
+
+// begin starts a new epoch and returns the writers of the previous one
+func (gcs *GCState) begin() (*sync.WaitGroup, error) {
+	gcs.mx.Lock()
+	defer gcs.mx.Unlock()
+
+	if gcs.live != nil {
+		return nil, GCInProgress
+	}
+
+	gcs.live = make(map[string]bool)
+	writers := gcs.writers
+	gcs.writers = new(sync.WaitGroup)
+	return writers, nil
+}
+
+func (gcs *GCState) end() {
+	gcs.mx.Lock()
+	gcs.live = nil
+	gcs.mx.Unlock()
+}
+
+func (gcs *GCState) running() bool {
+	gcs.mx.Lock()
+	defer gcs.mx.Unlock()
+	return gcs.live != nil
+}
+
+func (gcs *GCState) touchKeys(keys map[string]Key) {
+	gcs.mx.Lock()
+	if gcs.live != nil {
+		for key58, _ := range keys {
+			gcs.live[key58] = true
+		}
+	}
+	gcs.mx.Unlock()
+}
+
+func (gcs *GCState) touchData(data []byte) {
+	if !gcs.running() {
+		return
+	}
+
+	key58 := multihash.Multihash(mc.Hash(data)).B58String()
+	gcs.mx.Lock()
+	if gcs.live != nil {
+		gcs.live[key58] = true
+	}
+	gcs.mx.Unlock()
+}
+
+func (gcs *GCState) touchStatements(stmts []*pb.Statement) {
+	if !gcs.running() {
+		return
+	}
+
+	keys := make(map[string]bool)
+	for _, stmt := range stmts {
+		gcStatementKeys(stmt, keys)
+	}
+
+	gcs.mx.Lock()
+	if gcs.live != nil {
+		for key58, _ := range keys {
+			gcs.live[key58] = true
+		}
+	}
+	gcs.mx.Unlock()
+}
+
+func (gcs *GCState) isLive(key58 string) bool {
+	gcs.mx.Lock()
+	defer gcs.mx.Unlock()
+	return gcs.live[key58]
+}
+
+// sweep deletes an object unless it is live; the lock is held across the
+// check and the delete, so that writers can't touch the object in between.
+func (gcs *GCState) sweep(ds Datastore, key58 string, key Key) (bool, error) {
+	gcs.mx.Lock()
+	defer gcs.mx.Unlock()
+
+	if gcs.live[key58] {
+		return false, nil
+	}
+
+	return true, ds.Delete(key)
+}
+
+func gcWait(ctx context.Context, wg *sync.WaitGroup) error {
+	if wg == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (node *Node) doCompact() error {
@@ -39,41 +219,47 @@ func (node *Node) doCompact() error {
 	return nil
 }
 
+// GCDB holds the reference set of a gc in a temporary aux db, so that it
+// doesn't have to fit in memory.
 type GCDB struct {
-	db        *sql.DB
-	insertKey *sql.Stmt
-	countKeys *sql.Stmt
+	db   AuxDB  // referenced keys
+	dir  string // temporary db directory
+	pins *PinDB // gc roots; may be nil
 }
 
-func (gc *GCDB) Open(home string) error {
-	db, err := sql.Open("sqlite3", "") // use temporary db
-	if err != nil {
-		return err
-	}
-	gc.db = db
-
-	_, err = db.Exec("CREATE TABLE Refs (key VARCHAR(64) PRIMARY KEY)")
-	if err != nil {
-		return err
-	}
+var gcRef = []byte{1}
 
-	insertKey, err := db.Prepare("INSERT INTO Refs VALUES (?)")
-	if err != nil {
-		return err
+func (gc *GCDB) Open(home string) error {
+	if home != ":memory:" {
+		dir, err := ioutil.TempDir("", "mcnode-gc")
+		if err != nil {
+			return err
+		}
+		gc.dir = dir
+		home = dir
 	}
-	gc.insertKey = insertKey
 
-	countKeys, err := db.Prepare("SELECT COUNT(1) FROM Refs WHERE key = ?")
+	db, err := openAuxDB(home, "gc")
 	if err != nil {
+		gc.Close()
 		return err
 	}
-	gc.countKeys = countKeys
+	gc.db = db
 
 	return nil
 }
 
 func (gc *GCDB) Close() error {
-	return gc.db.Close()
+	var err error
+	if gc.db != nil {
+		err = gc.db.Close()
+	}
+
+	if gc.dir != "" {
+		os.RemoveAll(gc.dir)
+	}
+
+	return err
 }
 
 func (gc *GCDB) Merge(ctx context.Context, db StatementDB) error {
@@ -93,7 +279,7 @@ func (gc *GCDB) Merge(ctx context.Context, db StatementDB) error {
 	for val := range ch {
 		switch val := val.(type) {
 		case *pb.Statement:
-			gc.addKeys(val, keys)
+			gcStatementKeys(val, keys)
 			if len(keys) >= batch {
 				err := gc.mergeKeys(keys)
 				if err != nil {
@@ -117,35 +303,39 @@ func (gc *GCDB) Merge(ctx context.Context, db StatementDB) error {
 	return nil
 }
 
-func (gc *GCDB) addKeys(stmt *pb.Statement, keys map[string]bool) error {
+func gcStatementKeys(stmt *pb.Statement, keys map[string]bool) error {
 	switch body := stmt.Body.Body.(type) {
 	case *pb.StatementBody_Simple:
-		gc.addSimpleKeys(body.Simple, keys)
+		gcSimpleKeys(body.Simple, keys)
 		return nil
 
 	case *pb.StatementBody_Compound:
 		ss := body.Compound.Body
 		for _, s := range ss {
-			gc.addSimpleKeys(s, keys)
+			gcSimpleKeys(s, keys)
 		}
 		return nil
 
 	case *pb.StatementBody_Envelope:
 		stmts := body.Envelope.Body
 		for _, stmt := range stmts {
-			err := gc.addKeys(stmt, keys)
+			err := gcStatementKeys(stmt, keys)
 			if err != nil {
 				return err
 			}
 		}
 		return nil
 
+	case *pb.StatementBody_Retract:
+		// retractions reference no objects
+		return nil
+
 	default:
 		return BadStatementBody
 	}
 }
 
-func (gc *GCDB) addSimpleKeys(s *pb.SimpleStatement, keys map[string]bool) {
+func gcSimpleKeys(s *pb.SimpleStatement, keys map[string]bool) {
 	keys[s.Object] = true
 	for _, dep := range s.Deps {
 		keys[dep] = true
@@ -153,50 +343,40 @@ func (gc *GCDB) addSimpleKeys(s *pb.SimpleStatement, keys map[string]bool) {
 }
 
 func (gc *GCDB) mergeKeys(keys map[string]bool) error {
-	tx, err := gc.db.Begin()
-	if err != nil {
-		return err
-	}
-
-	insertKey := tx.Stmt(gc.insertKey)
-
+	kvs := make([]AuxEntry, 0, len(keys))
 	for key, _ := range keys {
-		_, err := insertKey.Exec(key)
-		if err != nil {
-			xerr, ok := err.(sqlite3.Error)
-			if ok && xerr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
-				continue
-			}
-			tx.Rollback()
-			return err
-		}
+		kvs = append(kvs, AuxEntry{key, gcRef})
 	}
 
-	return tx.Commit()
+	_, err := gc.db.Put(kvs, false)
+	return err
 }
 
-func (gc *GCDB) GC(ctx context.Context, ds Datastore) (count int, err error) {
-	keys, err := ds.IterKeys(ctx)
+// GC sweeps the datastore, deleting objects that are neither in the
+// reference set nor live in the gc epoch. Garbage is collected during the
+// scan and deleted afterwards, so that the sweep doesn't write to the
+// datastore while holding an iterator.
+func (gc *GCDB) GC(ctx context.Context, ds Datastore, gcs *GCState) (count int, err error) {
+	garbage, err := gc.scan(ctx, ds, gcs)
 	if err != nil {
 		return
 	}
 
-	for key := range keys {
-		var valid bool
-		valid, err = gc.validKey(key)
+	for key58, key := range garbage {
+		err = ctx.Err()
 		if err != nil {
 			return
 		}
 
-		if valid {
-			continue
-		}
-
-		err = ds.Delete(key)
+		var deleted bool
+		deleted, err = gcs.sweep(ds, key58, key)
 		if err != nil {
 			return
 		}
-		count += 1
+
+		if deleted {
+			count += 1
+		}
 	}
 
 	if count > 0 {
@@ -206,15 +386,52 @@ func (gc *GCDB) GC(ctx context.Context, ds Datastore) (count int, err error) {
 	return
 }
 
-func (gc *GCDB) validKey(key Key) (bool, error) {
-	key58 := multihash.Multihash(key).B58String()
-	row := gc.countKeys.QueryRow(key58)
+// scan returns the objects in the datastore that are neither in the
+// reference set, nor pinned, nor live in the gc epoch.
+func (gc *GCDB) scan(ctx context.Context, ds Datastore, gcs *GCState) (map[string]Key, error) {
+	keys, errc, err := ds.IterKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	garbage := make(map[string]Key)
+	for key := range keys {
+		key58 := multihash.Multihash(key).B58String()
 
-	var count int
-	err := row.Scan(&count)
+		valid, err := gc.validKey(key58)
+		if err != nil {
+			return nil, err
+		}
+
+		if valid || gcs.isLive(key58) {
+			continue
+		}
+
+		garbage[key58] = key
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = <-errc
+	if err != nil {
+		return nil, err
+	}
+
+	return garbage, nil
+}
+
+func (gc *GCDB) validKey(key58 string) (bool, error) {
+	ref, err := gc.db.Has(key58)
 	if err != nil {
 		return false, err
 	}
 
-	return count > 0, nil
+	if ref || gc.pins == nil {
+		return ref, nil
+	}
+
+	return gc.pins.Has(key58)
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	pb "github.com/mediachain/concat/proto"
+	"regexp"
+	"sort"
+)
+
+var (
+	BadWKI = errors.New("Illegal wki")
+)
+
+// Statement versions
+// Publishers republish updated metadata for the same wki, so every
+// statement referencing a wki is a version of the publisher's metadata for
+// it. Retractions don't reference wkis and are never versions. The
+// statement dbs maintain a view of the latest version per (publisher, wki)
+// as statements are put, merged and deleted, and can retrieve the full
+// version history of a wki.
+// Versions are ordered by timestamp; ties are broken by id length and then
+// by id, so that statements published in the same second are ordered by
+// their counter.
+type VersionQuery struct {
+	WKI       string `json:"wki"`
+	Publisher string `json:"publisher,omitempty"` // all publishers if empty
+}
+
+var wkirx *regexp.Regexp
+
+func init() {
+	// same as the MCQL wki lexeme
+	wkirx = regexp.MustCompile("^[-a-zA-Z0-9:_/.]+$")
+}
+
+func isVersion(stmt *pb.Statement) bool {
+	_, ok := stmt.Body.Body.(*pb.StatementBody_Retract)
+	return !ok
+}
+
+// filterVersions removes the statements that are not versions from a batch
+func filterVersions(stmts []*pb.Statement) []*pb.Statement {
+	res := make([]*pb.Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		if isVersion(stmt) {
+			res = append(res, stmt)
+		}
+	}
+	return res
+}
+
+func versionLess(ts1 int64, id1 string, ts2 int64, id2 string) bool {
+	switch {
+	case ts1 != ts2:
+		return ts1 < ts2
+	case len(id1) != len(id2):
+		return len(id1) < len(id2)
+	default:
+		return id1 < id2
+	}
+}
+
+func stmtVersionLess(a, b *pb.Statement) bool {
+	return versionLess(a.Timestamp, a.Id, b.Timestamp, b.Id)
+}
+
+type stmtVersions []*pb.Statement
+
+func (v stmtVersions) Len() int {
+	return len(v)
+}
+
+func (v stmtVersions) Less(i, j int) bool {
+	return stmtVersionLess(v[i], v[j])
+}
+
+func (v stmtVersions) Swap(i, j int) {
+	v[i], v[j] = v[j], v[i]
+}
+
+func sortVersions(stmts []*pb.Statement) {
+	sort.Sort(stmtVersions(stmts))
+}
+
+// doLatest returns the latest version of a wki for each publisher, or for
+// the specified publisher
+func (node *Node) doLatest(q VersionQuery) ([]*pb.Statement, error) {
+	if !wkirx.Match([]byte(q.WKI)) {
+		return nil, BadWKI
+	}
+
+	return node.db.QueryLatest(q.WKI, q.Publisher)
+}
+
+// doHistory returns all versions of a wki in version order, optionally
+// restricted to a publisher
+func (node *Node) doHistory(q VersionQuery) ([]*pb.Statement, error) {
+	if !wkirx.Match([]byte(q.WKI)) {
+		return nil, BadWKI
+	}
+
+	return node.db.QueryHistory(q.WKI, q.Publisher)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	"log"
+)
+
+// Lazy object retrieval
+// In lazy mode, merges only pull statements; the objects they reference are
+// indexed by the peer we merged them from and retrieved on first access.
+type LazyDB struct {
+	db AuxDB // object key -> source peer
+}
+
+func (node *Node) openLazyDB() error {
+	node.lazydb = &LazyDB{}
+	return node.lazydb.Open(node.home)
+}
+
+func (ldb *LazyDB) Open(home string) error {
+	db, err := openAuxDB(home, "lazy")
+	if err != nil {
+		return err
+	}
+
+	ldb.db = db
+	return nil
+}
+
+// Put records pid as the source of a batch of object keys
+func (ldb *LazyDB) Put(pid p2p_peer.ID, keys map[string]Key) error {
+	src := []byte(pid.Pretty())
+	kvs := make([]AuxEntry, 0, len(keys))
+	for key58, _ := range keys {
+		kvs = append(kvs, AuxEntry{key58, src})
+	}
+
+	_, err := ldb.db.Put(kvs, true)
+	return err
+}
+
+// Get returns the peer an object was merged from
+func (ldb *LazyDB) Get(key58 string) (p2p_peer.ID, error) {
+	src, err := ldb.db.Get(key58)
+	switch {
+	case err != nil:
+		return "", err
+	case src == nil:
+		return "", UnknownObject
+	}
+
+	return p2p_peer.IDB58Decode(string(src))
+}
+
+func (ldb *LazyDB) Close() error {
+	return ldb.db.Close()
+}
+
+// doFetchData retrieves a missing object from the peer we merged the
+// referencing statement from, falling back to DHT providers.
+// Returns nil if the object could not be found.
+func (node *Node) doFetchData(ctx context.Context, key58 string, key Key) ([]byte, error) {
+	if node.status == StatusOffline {
+		return nil, nil
+	}
+
+	keys := map[string]Key{key58: key}
+
+	pid, err := node.lazydb.Get(key58)
+	switch err {
+	case nil:
+		count, err := node.doRawMerge(ctx, pid, keys)
+		if err != nil {
+			log.Printf("Error fetching %s from %s: %s", key58, pid.Pretty(), err.Error())
+		}
+		if count > 0 {
+			return node.ds.Get(key)
+		}
+
+	case UnknownObject:
+
+	default:
+		return nil, err
+	}
+
+	_, err = node.doMergeDataProviders(ctx, pid, keys)
+	switch err {
+	case nil:
+		return node.ds.Get(key)
+
+	case MissingData:
+		return nil, nil
+
+	default:
+		return nil, err
+	}
+}
@@ -18,6 +18,12 @@ func main() {
 	cport := flag.Int("c", 9002, "Peer control interface port [http]")
 	bindaddr := flag.String("b", "127.0.0.1", "Peer control bind address [http]")
 	hdir := flag.String("d", "~/.mediachain/mcnode", "Node home")
+	dbkind := flag.String("db", "sqlite", "Statement db backend [sqlite|bolt|memory]")
+	dskind := flag.String("ds", "rocks", "Datastore backend [rocks|bolt|memory]")
+	dscompress := flag.Bool("ds-compress", false, "Store datastore objects compressed")
+	dsmigrate := flag.String("ds-migrate", "", "Copy all objects from the given datastore backend to the -ds backend and exit")
+	dsscrub := flag.String("ds-scrub", "", "Verify the hash of all objects in the datastore and exit [check|quarantine]")
+	restore := flag.String("restore", "", "Restore the node home from a backup directory or tarball and exit")
 	ver := flag.Bool("version", false, "print version and exit")
 	flag.Parse()
 
@@ -47,6 +53,47 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *restore != "" {
+		err = restoreBackup(home, *restore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Restored %s to %s", *restore, home)
+		os.Exit(0)
+	}
+
+	if *dsmigrate != "" {
+		count, bad, err := migrateDatastore(home, *dsmigrate, *dskind, *dscompress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Migrated %d objects from %s to %s", count, *dsmigrate, *dskind)
+		if bad > 0 {
+			log.Printf("Skipped %d corrupt objects", bad)
+		}
+		os.Exit(0)
+	}
+
+	if *dsscrub != "" {
+		if *dsscrub != "check" && *dsscrub != "quarantine" {
+			log.Fatalf("Unknown scrub mode: %s", *dsscrub)
+		}
+
+		report, err := scrubDatastoreOffline(home, *dskind, *dsscrub == "quarantine")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, key58 := range report.Corrupt {
+			fmt.Println(key58)
+		}
+		log.Printf("Scrubbed %d objects: %d corrupt, %d quarantined", report.Objects, len(report.Corrupt), report.Quarantined)
+		if len(report.Corrupt) > report.Quarantined {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	id, err := mc.MakePeerIdentity(home)
 	if err != nil {
 		log.Fatal(err)
@@ -64,12 +111,27 @@ func main() {
 		log.Fatal(err)
 	}
 
-	err = node.openDB()
+	err = node.openDB(*dbkind)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = node.openDS(*dskind, *dscompress)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = node.openLazyDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = node.openPinDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = node.openDS()
+	err = node.openRetractDB()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -84,20 +146,33 @@ func main() {
 	router.HandleFunc("/publish/{namespace}", node.httpPublish)
 	router.HandleFunc("/publish/{namespace}/{combine}", node.httpPublishCompound)
 	router.HandleFunc("/import", node.httpImport)
+	router.HandleFunc("/import/archive", node.httpImportArchive)
+	router.HandleFunc("/export", node.httpExport)
 	router.HandleFunc("/stmt/{statementId}", node.httpStatement)
 	router.HandleFunc("/query", node.httpQuery)
 	router.HandleFunc("/query/{peerId}", node.httpRemoteQuery)
+	router.HandleFunc("/latest", node.httpLatest)
+	router.HandleFunc("/history", node.httpHistory)
 	router.HandleFunc("/merge/{peerId}", node.httpMerge)
 	router.HandleFunc("/push/{peerId}", node.httpPush)
 	router.HandleFunc("/delete", node.httpDelete)
+	router.HandleFunc("/retract/{namespace}", node.httpRetract)
 	router.HandleFunc("/vacuum/incremental", node.httpVacuumIncremental)
 	router.HandleFunc("/vacuum/full", node.httpVacuumFull)
+	router.HandleFunc("/db/check", node.httpCheckDB)
+	router.HandleFunc("/db/check/verify", node.httpCheckDB)
+	router.HandleFunc("/db/reindex", node.httpReindexDB)
 	router.HandleFunc("/data/put", node.httpPutData)
 	router.HandleFunc("/data/get", node.httpGetDataBatch)
 	router.HandleFunc("/data/get/{objectId}", node.httpGetData)
 	router.HandleFunc("/data/merge/{peerId}", node.httpMergeData)
 	router.HandleFunc("/data/keys", node.httpDataKeys)
+	router.HandleFunc("/data/scrub", node.httpScrubData)
+	router.HandleFunc("/data/scrub/{action}", node.httpScrubData)
 	router.HandleFunc("/data/gc", node.httpGCData)
+	router.HandleFunc("/data/gc/dryrun", node.httpGCDryRun)
+	router.HandleFunc("/data/pin", node.httpPinData)
+	router.HandleFunc("/data/unpin", node.httpUnpinData)
 	router.HandleFunc("/data/compact", node.httpCompactData)
 	router.HandleFunc("/data/sync", node.httpSyncData)
 	router.HandleFunc("/status", node.httpStatus)
@@ -105,10 +180,16 @@ func main() {
 	router.HandleFunc("/config/dir", node.httpConfigDir)
 	router.HandleFunc("/config/nat", node.httpConfigNAT)
 	router.HandleFunc("/config/info", node.httpConfigInfo)
+	router.HandleFunc("/config/provide", node.httpConfigProvide)
+	router.HandleFunc("/config/lazy", node.httpConfigLazy)
+	router.HandleFunc("/config/compress", node.httpConfigCompress)
+	router.HandleFunc("/config/nsstats", node.httpConfigNSStats)
 	router.HandleFunc("/auth", node.httpAuth)
 	router.HandleFunc("/auth/{peerId}", node.httpAuthPeer)
 	router.HandleFunc("/manifest", node.httpManifest)
 	router.HandleFunc("/manifest/self", node.httpManifestSelf)
+	router.HandleFunc("/manifest/revoke", node.httpManifestRevoke)
+	router.HandleFunc("/manifest/ns", node.httpManifestNS)
 	router.HandleFunc("/manifest/{peerId}", node.httpManifestPeer)
 	router.HandleFunc("/dir/list", node.httpDirList)
 	router.HandleFunc("/dir/list/{namespace}", node.httpDirList)
@@ -122,6 +203,7 @@ func main() {
 	router.HandleFunc("/net/identify/{peerId}", node.httpNetIdentify)
 	router.HandleFunc("/net/ping/{peerId}", node.httpNetPing)
 	router.HandleFunc("/net/find", node.httpNetFindPeers)
+	router.HandleFunc("/backup", node.httpBackup)
 	router.HandleFunc("/shutdown", node.httpShutdown)
 
 	log.Printf("Serving client interface at %s", haddr)
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	mc "github.com/mediachain/concat/mc"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	BadManifest          = errors.New("Bad manifest; expired or revoked")
+	BadNamespaceManifest = errors.New("Bad namespace manifest")
+)
+
+// Manifest expiry and revocation
+// The node keeps a list of manifest revocations alongside its manifests,
+// and presents both to directories when registering; directories verify the
+// revocations and drop the revoked manifests, wherever they were registered
+// from. Expired and revoked manifests are never set, served or registered.
+// Revocations are applied locally without verification, as they are part of
+// the node's own configuration.
+func (node *Node) validManifests() []*pb.Manifest {
+	return filterManifests(node.mfs, node.mfrevs, time.Now())
+}
+
+func filterManifests(mfs []*pb.Manifest, revs []*pb.ManifestRevocation, now time.Time) []*pb.Manifest {
+	res := make([]*pb.Manifest, 0, len(mfs))
+	for _, mf := range mfs {
+		if checkManifest(mf, revs, now) {
+			res = append(res, mf)
+		}
+	}
+	return res
+}
+
+func checkManifest(mf *pb.Manifest, revs []*pb.ManifestRevocation, now time.Time) bool {
+	if mc.IsManifestExpired(mf, now) {
+		return false
+	}
+
+	for _, rev := range revs {
+		if mc.RevokesManifest(rev, mf) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Namespace ownership
+// Namespace manifests claim a namespace prefix for an entity and list the
+// publishers authorized to publish in it; a prefix covers the namespace
+// itself and all its subnamespaces. When the node is configured with
+// namespace manifests, statements in claimed namespaces by unlisted
+// publishers are rejected in merges, pushes and imports. The most specific
+// claim for a namespace applies; if multiple entities claim the same prefix,
+// publishers listed by any of them are authorized.
+// Manifests are verified when they are set, and their claims lapse when they
+// expire or are revoked.
+type NamespacePolicy struct {
+	claims []NamespaceClaim
+}
+
+type NamespaceClaim struct {
+	mf         *pb.Manifest
+	prefix     string
+	publishers map[string]bool
+}
+
+func makeNamespacePolicy(mfs []*pb.Manifest, revs []*pb.ManifestRevocation, now time.Time) *NamespacePolicy {
+	claims := make([]NamespaceClaim, 0, len(mfs))
+	for _, mf := range filterManifests(mfs, revs, now) {
+		nsmf := mf.Body.GetNamespace()
+		if nsmf == nil {
+			continue
+		}
+
+		pubs := make(map[string]bool)
+		for _, pub := range nsmf.Publishers {
+			pubs[pub] = true
+		}
+
+		claims = append(claims, NamespaceClaim{mf, nsmf.Namespace, pubs})
+	}
+
+	if len(claims) == 0 {
+		return nil
+	}
+
+	return &NamespacePolicy{claims}
+}
+
+// allow checks whether a statement's publisher is authorized in its
+// namespace; a nil policy allows everything.
+func (np *NamespacePolicy) allow(stmt *pb.Statement, now time.Time) bool {
+	if np == nil {
+		return true
+	}
+
+	match := -1
+	allow := false
+	for _, claim := range np.claims {
+		if !claim.covers(stmt.Namespace) || mc.IsManifestExpired(claim.mf, now) {
+			continue
+		}
+
+		switch {
+		case len(claim.prefix) > match:
+			match = len(claim.prefix)
+			allow = claim.publishers[stmt.Publisher]
+
+		case len(claim.prefix) == match:
+			allow = allow || claim.publishers[stmt.Publisher]
+		}
+	}
+
+	return match < 0 || allow
+}
+
+func (claim NamespaceClaim) covers(ns string) bool {
+	return ns == claim.prefix || strings.HasPrefix(ns, claim.prefix+".")
+}
+
+// filterUnauthorized removes statements by unauthorized publishers from a
+// batch
+func (np *NamespacePolicy) filterUnauthorized(stmts []*pb.Statement) []*pb.Statement {
+	if np == nil {
+		return stmts
+	}
+
+	now := time.Now()
+	res := stmts[:0:0]
+	for _, stmt := range stmts {
+		if np.allow(stmt, now) {
+			res = append(res, stmt)
+		} else {
+			log.Printf("Rejecting statement %s; publisher not authorized in %s", stmt.Id, stmt.Namespace)
+		}
+	}
+
+	return res
+}
+
+func (node *Node) namespacePolicy() *NamespacePolicy {
+	node.mx.Lock()
+	defer node.mx.Unlock()
+	return node.nspolicy
+}
+
+// updateNamespacePolicy rebuilds the namespace policy from the configured
+// namespace manifests and revocations
+func (node *Node) updateNamespacePolicy() {
+	np := makeNamespacePolicy(node.nsmfs, node.mfrevs, time.Now())
+	node.mx.Lock()
+	node.nspolicy = np
+	node.mx.Unlock()
+}
+
+// verifyNamespaceManifest checks a namespace manifest before it is set,
+// looking up the entity key.
+func (node *Node) verifyNamespaceManifest(mf *pb.Manifest) error {
+	if mf.Body == nil || mf.Body.GetNamespace() == nil {
+		return BadNamespaceManifest
+	}
+
+	nsmf := mf.Body.GetNamespace()
+	if !nsrx.Match([]byte(nsmf.Namespace)) {
+		return BadNamespace
+	}
+
+	for _, pub := range nsmf.Publishers {
+		_, err := mc.PublisherKey(pub)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !checkManifest(mf, node.mfrevs, time.Now()) {
+		return BadManifest
+	}
+
+	pubk, err := mc.LookupEntityKey(mf.Entity, mf.KeyId)
+	if err != nil {
+		return err
+	}
+
+	ok, err := mc.VerifyManifest(mf, pubk)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return BadNamespaceManifest
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	ggproto "github.com/gogo/protobuf/proto"
+	mc "github.com/mediachain/concat/mc"
+	mcq "github.com/mediachain/concat/mc/query"
+	pb "github.com/mediachain/concat/proto"
+	"sort"
+	"sync"
+)
+
+// In-memory statement db, for testing and ephemeral nodes.
+// Statements are kept marshalled so that, like the persistent backends,
+// every read returns a fresh copy.
+// Queries are executed by compiled query plans against a snapshot of the
+// records, so that streams don't block writers.
+// The latest versions are indexed by wki and publisher.
+type MemDB struct {
+	mx      sync.RWMutex
+	stmts   map[string]*memRecord
+	recs    []*memRecord // in counter order
+	latest  map[string]map[string]*memRecord
+	counter int64
+}
+
+type memRecord struct {
+	id        string
+	counter   int64
+	publisher string
+	timestamp int64
+	refs      []string
+	version   bool
+	data      []byte
+}
+
+func (rec *memRecord) decode() (*mcq.StatementRecord, error) {
+	stmt := new(pb.Statement)
+	err := ggproto.Unmarshal(rec.data, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcq.StatementRecord{Counter: rec.counter, Stmt: stmt}, nil
+}
+
+func (mdb *MemDB) Open(home string) error {
+	mdb.stmts = make(map[string]*memRecord)
+	mdb.latest = make(map[string]map[string]*memRecord)
+	return nil
+}
+
+func (mdb *MemDB) Put(stmt *pb.Statement) error {
+	return mdb.PutBatch([]*pb.Statement{stmt})
+}
+
+func (mdb *MemDB) PutBatch(stmts []*pb.Statement) error {
+	recs, err := mdb.makeRecords(stmts)
+	if err != nil {
+		return err
+	}
+
+	mdb.mx.Lock()
+	defer mdb.mx.Unlock()
+
+	for _, rec := range recs {
+		_, dup := mdb.stmts[rec.id]
+		if dup {
+			return DupStatement
+		}
+	}
+
+	for _, rec := range recs {
+		mdb.insert(rec)
+	}
+
+	return nil
+}
+
+func (mdb *MemDB) Merge(stmt *pb.Statement) (bool, error) {
+	count, err := mdb.MergeBatch([]*pb.Statement{stmt})
+	return count > 0, err
+}
+
+func (mdb *MemDB) MergeBatch(stmts []*pb.Statement) (count int, err error) {
+	recs, err := mdb.makeRecords(stmts)
+	if err != nil {
+		return 0, err
+	}
+
+	mdb.mx.Lock()
+	defer mdb.mx.Unlock()
+
+	for _, rec := range recs {
+		_, dup := mdb.stmts[rec.id]
+		if dup {
+			continue
+		}
+
+		mdb.insert(rec)
+		count += 1
+	}
+
+	return count, nil
+}
+
+func (mdb *MemDB) makeRecords(stmts []*pb.Statement) ([]*memRecord, error) {
+	recs := make([]*memRecord, len(stmts))
+	for x, stmt := range stmts {
+		data, err := ggproto.Marshal(stmt)
+		if err != nil {
+			return nil, err
+		}
+		recs[x] = &memRecord{
+			id:        stmt.Id,
+			publisher: stmt.Publisher,
+			timestamp: stmt.Timestamp,
+			refs:      mcq.StatementRefs(stmt).List(),
+			version:   isVersion(stmt),
+			data:      data}
+	}
+	return recs, nil
+}
+
+// insert must be called with the lock held
+func (mdb *MemDB) insert(rec *memRecord) {
+	mdb.counter++
+	rec.counter = mdb.counter
+	mdb.stmts[rec.id] = rec
+	mdb.recs = append(mdb.recs, rec)
+	mdb.indexLatest(rec)
+}
+
+// indexLatest must be called with the lock held
+func (mdb *MemDB) indexLatest(rec *memRecord) {
+	if !rec.version {
+		return
+	}
+
+	for _, wki := range rec.refs {
+		pubs, ok := mdb.latest[wki]
+		if !ok {
+			pubs = make(map[string]*memRecord)
+			mdb.latest[wki] = pubs
+		}
+
+		cur, ok := pubs[rec.publisher]
+		if !ok || versionLess(cur.timestamp, cur.id, rec.timestamp, rec.id) {
+			pubs[rec.publisher] = rec
+		}
+	}
+}
+
+// reindexLatest must be called with the lock held
+func (mdb *MemDB) reindexLatest() {
+	mdb.latest = make(map[string]map[string]*memRecord)
+	for _, rec := range mdb.recs {
+		mdb.indexLatest(rec)
+	}
+}
+
+func (mdb *MemDB) Get(id string) (*pb.Statement, error) {
+	mdb.mx.RLock()
+	rec, ok := mdb.stmts[id]
+	mdb.mx.RUnlock()
+
+	if !ok {
+		return nil, UnknownStatement
+	}
+
+	xrec, err := rec.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	return xrec.Stmt, nil
+}
+
+func (mdb *MemDB) snapshot() []*memRecord {
+	mdb.mx.RLock()
+	defer mdb.mx.RUnlock()
+
+	// records are immutable and deletion rebuilds the slice, so the snapshot
+	// only needs the current slice header
+	return mdb.recs
+}
+
+func memEvalPlan(recs []*memRecord, ev *mcq.QueryEvaluator) error {
+	for _, rec := range recs {
+		xrec, err := rec.decode()
+		if err != nil {
+			return err
+		}
+
+		if !ev.Add(xrec) {
+			break
+		}
+	}
+
+	ev.End()
+	return nil
+}
+
+func (mdb *MemDB) Query(q *mcq.Query) ([]interface{}, error) {
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]interface{}, 0)
+	ev := plan.Begin(func(val interface{}) bool {
+		res = append(res, val)
+		return true
+	})
+
+	err = memEvalPlan(mdb.snapshot(), ev)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (mdb *MemDB) QueryStream(ctx context.Context, q *mcq.Query) (<-chan interface{}, error) {
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := mdb.snapshot()
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+
+		ev := plan.Begin(func(val interface{}) bool {
+			select {
+			case ch <- val:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+
+		err := memEvalPlan(recs, ev)
+		if err != nil {
+			sendStreamError(ctx, ch, err.Error())
+		}
+	}()
+
+	return ch, nil
+}
+
+func (mdb *MemDB) QueryOne(q *mcq.Query) (interface{}, error) {
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var res interface{}
+	ev := plan.Begin(func(val interface{}) bool {
+		res = val
+		return false
+	})
+
+	err = memEvalPlan(mdb.snapshot(), ev)
+	switch {
+	case err != nil:
+		return nil, err
+	case res == nil:
+		return nil, NoResult
+	default:
+		return res, nil
+	}
+}
+
+func (mdb *MemDB) Delete(q *mcq.Query) (int, error) {
+	if q.Op != mcq.OpDelete {
+		return 0, BadQuery
+	}
+
+	plan, err := mcq.CompileQueryPlan(q)
+	if err != nil {
+		return 0, err
+	}
+
+	mdb.mx.Lock()
+	defer mdb.mx.Unlock()
+
+	del := make(map[string]bool)
+	ev := plan.Begin(func(val interface{}) bool {
+		del[val.(string)] = true
+		return true
+	})
+
+	err = memEvalPlan(mdb.recs, ev)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(del) == 0 {
+		return 0, nil
+	}
+
+	recs := make([]*memRecord, 0, len(mdb.recs)-len(del))
+	for _, rec := range mdb.recs {
+		if del[rec.id] {
+			delete(mdb.stmts, rec.id)
+		} else {
+			recs = append(recs, rec)
+		}
+	}
+	mdb.recs = recs
+
+	// deletion is a full scan anyway
+	mdb.reindexLatest()
+
+	return len(del), nil
+}
+
+func (mdb *MemDB) QueryLatest(wki string, publisher string) ([]*pb.Statement, error) {
+	mdb.mx.RLock()
+	pubs := mdb.latest[wki]
+	keys := make([]string, 0, len(pubs))
+	for pub, _ := range pubs {
+		if publisher == "" || pub == publisher {
+			keys = append(keys, pub)
+		}
+	}
+	sort.Strings(keys)
+
+	recs := make([]*memRecord, len(keys))
+	for x, pub := range keys {
+		recs[x] = pubs[pub]
+	}
+	mdb.mx.RUnlock()
+
+	return memDecodeStatements(recs)
+}
+
+func (mdb *MemDB) QueryHistory(wki string, publisher string) ([]*pb.Statement, error) {
+	recs := make([]*memRecord, 0)
+	for _, rec := range mdb.snapshot() {
+		if !rec.version || (publisher != "" && rec.publisher != publisher) {
+			continue
+		}
+
+		for _, ref := range rec.refs {
+			if ref == wki {
+				recs = append(recs, rec)
+				break
+			}
+		}
+	}
+
+	stmts, err := memDecodeStatements(recs)
+	if err != nil {
+		return nil, err
+	}
+
+	sortVersions(stmts)
+	return stmts, nil
+}
+
+func memDecodeStatements(recs []*memRecord) ([]*pb.Statement, error) {
+	stmts := make([]*pb.Statement, len(recs))
+	for x, rec := range recs {
+		xrec, err := rec.decode()
+		if err != nil {
+			return nil, err
+		}
+		stmts[x] = xrec.Stmt
+	}
+	return stmts, nil
+}
+
+func (mdb *MemDB) Vacuum(full bool) error {
+	return nil
+}
+
+// Check verifies the statement records; the latest version index is
+// maintained with the records, so there are no derived indexes to check.
+// Signatures are verified if verify is not nil.
+func (mdb *MemDB) Check(verify StatementVerifier) (*DBCheckReport, error) {
+	report := new(DBCheckReport)
+	for _, rec := range mdb.snapshot() {
+		report.Statements += 1
+
+		xrec, err := rec.decode()
+		if err != nil || xrec.Stmt.Id != rec.id {
+			report.BadData = append(report.BadData, rec.id)
+			continue
+		}
+
+		report.checkSignature(xrec.Stmt, verify)
+	}
+
+	return report, nil
+}
+
+// Rebuild regenerates the latest version index
+func (mdb *MemDB) Rebuild() (int, error) {
+	mdb.mx.Lock()
+	defer mdb.mx.Unlock()
+
+	mdb.reindexLatest()
+	return len(mdb.recs), nil
+}
+
+func (mdb *MemDB) Backup(dir string) error {
+	return BackupUnsupported
+}
+
+func (mdb *MemDB) Close() error {
+	return nil
+}
+
+// In-memory datastore, for testing and ephemeral nodes
+// Objects are keyed by their hash digest, same as the keys in RocksDS.
+type MemDS struct {
+	mx   sync.RWMutex
+	data map[string][]byte
+}
+
+func (ds *MemDS) Open(home string) error {
+	ds.data = make(map[string][]byte)
+	return nil
+}
+
+func (ds *MemDS) Put(data []byte) (Key, error) {
+	key := mc.Hash(data)
+
+	ds.mx.Lock()
+	ds.data[string(key[2:])] = memCopy(data)
+	ds.mx.Unlock()
+
+	return Key(key), nil
+}
+
+func (ds *MemDS) PutBatch(batch [][]byte) ([]Key, error) {
+	keys := make([]Key, len(batch))
+
+	ds.mx.Lock()
+	defer ds.mx.Unlock()
+
+	for x, data := range batch {
+		key := mc.Hash(data)
+		ds.data[string(key[2:])] = memCopy(data)
+		keys[x] = Key(key)
+	}
+
+	return keys, nil
+}
+
+func (ds *MemDS) Has(key Key) (bool, error) {
+	ds.mx.RLock()
+	_, ok := ds.data[string(key[2:])]
+	ds.mx.RUnlock()
+	return ok, nil
+}
+
+// Get returns nil if the object is not present, like RocksDS
+func (ds *MemDS) Get(key Key) ([]byte, error) {
+	ds.mx.RLock()
+	data, ok := ds.data[string(key[2:])]
+	ds.mx.RUnlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	return memCopy(data), nil
+}
+
+func memCopy(data []byte) []byte {
+	xdata := make([]byte, len(data))
+	copy(xdata, data)
+	return xdata
+}
+
+func (ds *MemDS) Delete(key Key) error {
+	ds.mx.Lock()
+	delete(ds.data, string(key[2:]))
+	ds.mx.Unlock()
+	return nil
+}
+
+func (ds *MemDS) Sync() error {
+	return nil
+}
+
+func (ds *MemDS) IterKeys(ctx context.Context) (<-chan Key, <-chan error, error) {
+	ds.mx.RLock()
+	keys := make([]Key, 0, len(ds.data))
+	for key, _ := range ds.data {
+		keys = append(keys, Key(mc.HashFromBytes([]byte(key))))
+	}
+	ds.mx.RUnlock()
+
+	ch := make(chan Key)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(ch)
+
+		for _, key := range keys {
+			select {
+			case ch <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, errc, nil
+}
+
+func (ds *MemDS) Compact() {}
+
+func (ds *MemDS) Backup(dir string) error {
+	return BackupUnsupported
+}
+
+func (ds *MemDS) Close() {}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	mc "github.com/mediachain/concat/mc"
+	multihash "github.com/multiformats/go-multihash"
+	"log"
+)
+
+// newDatastore creates a datastore for a backend; with compress, values
+// are stored compressed. The memory backend doesn't compress.
+func newDatastore(backend string, compress bool) (Datastore, error) {
+	switch backend {
+	case "rocks":
+		return newRocksDS(compress)
+	case "bolt":
+		return &BoltDS{compress: compress}, nil
+	case "memory":
+		return &MemDS{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown datastore backend: %s", backend)
+	}
+}
+
+// migrateDatastore copies all objects in the src datastore backend to the
+// dest backend; the source datastore is left intact.
+// With compress, objects are stored compressed in the dest backend.
+// Corrupt objects are skipped and counted in bad; they can be found and
+// refetched with a scrub of the source datastore.
+func migrateDatastore(home string, src, dest string, compress bool) (count int, bad int, err error) {
+	if src == dest {
+		return 0, 0, fmt.Errorf("Cannot migrate datastore to the same backend: %s", src)
+	}
+
+	sds, err := newDatastore(src, false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	dds, err := newDatastore(dest, compress)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = sds.Open(home)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer sds.Close()
+
+	err = dds.Open(home)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer dds.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keys, errc, err := sds.IterKeys(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	const batchsz = 1024
+	batch := make([][]byte, 0, batchsz)
+	bkeys := make([]Key, 0, batchsz)
+
+	flush := func() error {
+		xkeys, err := dds.PutBatch(batch)
+		if err != nil {
+			return err
+		}
+
+		for x, key := range xkeys {
+			if !bytes.Equal(key, bkeys[x]) {
+				return BadData
+			}
+		}
+
+		count += len(batch)
+		log.Printf("Migrated %d objects", count)
+
+		batch = batch[:0]
+		bkeys = bkeys[:0]
+		return nil
+	}
+
+	for key := range keys {
+		data, err := sds.Get(key)
+		if err != nil {
+			return count, bad, err
+		}
+
+		if data == nil {
+			// deleted since iteration started
+			continue
+		}
+
+		if !bytes.Equal([]byte(key), []byte(mc.Hash(data))) {
+			log.Printf("Skipping corrupt object %s", multihash.Multihash(key).B58String())
+			bad += 1
+			continue
+		}
+
+		batch = append(batch, data)
+		bkeys = append(bkeys, key)
+
+		if len(batch) == batchsz {
+			err = flush()
+			if err != nil {
+				return count, bad, err
+			}
+		}
+	}
+
+	// a failed iteration leaves the migration incomplete
+	err = <-errc
+	if err != nil {
+		return count, bad, err
+	}
+
+	if len(batch) > 0 {
+		err = flush()
+		if err != nil {
+			return count, bad, err
+		}
+	}
+
+	err = dds.Sync()
+	return count, bad, err
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	ggio "github.com/gogo/protobuf/io"
 	p2p_net "github.com/libp2p/go-libp2p-net"
 	p2p_peer "github.com/libp2p/go-libp2p-peer"
@@ -14,6 +15,8 @@ import (
 	pb "github.com/mediachain/concat/proto"
 	multiaddr "github.com/multiformats/go-multiaddr"
 	"log"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -137,6 +140,7 @@ func (node *Node) goPublic() error {
 			go node.registerPeer(node.netCtx, dir)
 		}
 		go node.registerPeerDHT(node.netCtx)
+		go node.provideObjects(node.netCtx)
 		node.status = StatusPublic
 
 		log.Println("Node is public")
@@ -205,10 +209,15 @@ func (node *Node) registerPeerImpl(ctx context.Context, dir p2p_pstore.PeerInfo)
 
 			ns := node.publicNamespaces()
 			pbpub.Namespaces = ns
+			if node.nsstats {
+				pbpub.Stats = node.publicNamespaceStats(ns)
+			} else {
+				pbpub.Stats = nil
+			}
 
-			mfs := node.mfs
+			mfs := node.validManifests()
 
-			msg := pb.RegisterPeer{&pbpi, &pbpub, mfs}
+			msg := pb.RegisterPeer{&pbpi, &pbpub, mfs, node.info, node.mfrevs}
 
 			err = w.WriteMsg(&msg)
 			if err != nil {
@@ -244,6 +253,120 @@ func (node *Node) publicNamespaces() []string {
 	return pns
 }
 
+// Namespace stats cache
+// The namespace stats are computed with per-namespace queries, so they are
+// cached between registrations and only recomputed for the namespaces
+// written to since. Writers touch the namespaces after committing; the
+// generation counters make sure that stats computed concurrently with a
+// write are not cached.
+type NamespaceStatsCache struct {
+	mx    sync.Mutex
+	stats map[string]*pb.NamespaceStats
+	gen   map[string]int64
+	epoch int64 // bumped when all namespaces are touched
+}
+
+type nsStatsToken struct {
+	epoch int64
+	gen   int64
+}
+
+func (nsc *NamespaceStatsCache) get(ns string) (*pb.NamespaceStats, nsStatsToken) {
+	nsc.mx.Lock()
+	defer nsc.mx.Unlock()
+	return nsc.stats[ns], nsStatsToken{nsc.epoch, nsc.gen[ns]}
+}
+
+func (nsc *NamespaceStatsCache) put(stats *pb.NamespaceStats, tok nsStatsToken) {
+	nsc.mx.Lock()
+	defer nsc.mx.Unlock()
+
+	if tok.epoch != nsc.epoch || tok.gen != nsc.gen[stats.Namespace] {
+		return
+	}
+
+	if nsc.stats == nil {
+		nsc.stats = make(map[string]*pb.NamespaceStats)
+	}
+	nsc.stats[stats.Namespace] = stats
+}
+
+// touch invalidates the stats of the namespaces of a batch of statements
+func (nsc *NamespaceStatsCache) touch(stmts []*pb.Statement) {
+	nsc.mx.Lock()
+	defer nsc.mx.Unlock()
+
+	if nsc.gen == nil {
+		nsc.gen = make(map[string]int64)
+	}
+
+	for _, stmt := range stmts {
+		delete(nsc.stats, stmt.Namespace)
+		nsc.gen[stmt.Namespace] += 1
+	}
+}
+
+// touchAll invalidates the stats of all namespaces
+func (nsc *NamespaceStatsCache) touchAll() {
+	nsc.mx.Lock()
+	defer nsc.mx.Unlock()
+
+	nsc.stats = nil
+	nsc.epoch += 1
+}
+
+// publicNamespaceStats returns the statement count and last update
+// timestamp of the namespaces
+func (node *Node) publicNamespaceStats(nss []string) []*pb.NamespaceStats {
+	stats := make([]*pb.NamespaceStats, 0, len(nss))
+	for _, ns := range nss {
+		xstats, tok := node.nsscache.get(ns)
+		if xstats != nil {
+			stats = append(stats, xstats)
+			continue
+		}
+
+		count, err := node.queryNamespaceInt64(fmt.Sprintf("SELECT COUNT(*) FROM %s", ns))
+		if err != nil {
+			log.Printf("Namespace stats error for %s: %s", ns, err.Error())
+			continue
+		}
+
+		ts, err := node.queryNamespaceInt64(fmt.Sprintf("SELECT MAX(timestamp) FROM %s", ns))
+		if err != nil {
+			log.Printf("Namespace stats error for %s: %s", ns, err.Error())
+			continue
+		}
+
+		xstats = &pb.NamespaceStats{ns, count, ts}
+		node.nsscache.put(xstats, tok)
+		stats = append(stats, xstats)
+	}
+
+	return stats
+}
+
+func (node *Node) queryNamespaceInt64(qs string) (int64, error) {
+	q, err := mcq.ParseQuery(qs)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := node.db.QueryOne(q)
+	if err != nil {
+		return 0, err
+	}
+
+	switch val := res.(type) {
+	case int:
+		return int64(val), nil
+	case int64:
+		return val, nil
+	default:
+		return 0, BadResult
+	}
+}
+
 var nsQuery *mcq.Query
 
 func init() {
@@ -619,6 +742,79 @@ func (node *Node) doDirListNS(ctx context.Context) ([]string, error) {
 	return node.doDirCollect(ctx, node.doDirListNSImpl)
 }
 
+// doDirListNSStats lists the namespace stats in all directories, merging
+// the peer rankings of namespaces listed by multiple directories.
+func (node *Node) doDirListNSStats(ctx context.Context) ([]*pb.NamespaceListing, error) {
+	strs, err := node.doDirCollect(ctx,
+		func(ctx context.Context, dir p2p_pstore.PeerInfo) ([]string, error) {
+			nss, err := node.doDirListNSStatsImpl(ctx, dir)
+			if err != nil {
+				return nil, err
+			}
+
+			strs := make([]string, len(nss))
+			for x, ns := range nss {
+				bytes, err := json.Marshal(ns)
+				if err != nil {
+					return nil, err
+				}
+				strs[x] = string(bytes)
+			}
+
+			return strs, nil
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	nsmap := make(map[string]map[string]*pb.NamespacePeer)
+	for _, str := range strs {
+		lst := new(pb.NamespaceListing)
+		err := json.Unmarshal([]byte(str), lst)
+		if err != nil {
+			return nil, err
+		}
+
+		peers, ok := nsmap[lst.Namespace]
+		if !ok {
+			peers = make(map[string]*pb.NamespacePeer)
+			nsmap[lst.Namespace] = peers
+		}
+
+		for _, peer := range lst.Peers {
+			xpeer, ok := peers[peer.Id]
+			if !ok || peer.LastUpdate > xpeer.LastUpdate {
+				peers[peer.Id] = peer
+			}
+		}
+	}
+
+	nss := make([]string, 0, len(nsmap))
+	for ns, _ := range nsmap {
+		nss = append(nss, ns)
+	}
+	sort.Strings(nss)
+
+	res := make([]*pb.NamespaceListing, len(nss))
+	for x, ns := range nss {
+		lst := &pb.NamespaceListing{Namespace: ns}
+		for _, peer := range nsmap[ns] {
+			lst.Peers = append(lst.Peers, peer)
+			if peer.Statements > lst.Statements {
+				lst.Statements = peer.Statements
+			}
+			if peer.LastUpdate > lst.LastUpdate {
+				lst.LastUpdate = peer.LastUpdate
+			}
+		}
+		mc.SortNamespacePeers(lst.Peers)
+		res[x] = lst
+	}
+
+	return res, nil
+}
+
 func (node *Node) doDirListMF(ctx context.Context, entity string) ([]*pb.Manifest, error) {
 	strs, err := node.doDirCollect(ctx,
 		func(ctx context.Context, dir p2p_pstore.PeerInfo) ([]string, error) {
@@ -656,6 +852,121 @@ func (node *Node) doDirListMF(ctx context.Context, entity string) ([]*pb.Manifes
 	return mfs, nil
 }
 
+// doDirListVerbose lists a page of the peers in all directories with their
+// metadata, ordered by peer id; peers registered with multiple directories
+// are listed once, with the most recent registration. A limit of 0 lists all
+// peers after the offset.
+func (node *Node) doDirListVerbose(ctx context.Context, ns string, offset, limit int) ([]DirectoryPeer, error) {
+	// a single directory pages the listing itself; with multiple directories,
+	// the page is within the first offset+limit peers of each directory.
+	dirs := node.dir
+	xoffset, xlimit := 0, 0
+	switch {
+	case len(dirs) == 1:
+		xoffset, xlimit = offset, limit
+	case limit > 0:
+		xlimit = offset + limit
+	}
+
+	strs, err := node.doDirCollect(ctx,
+		func(ctx context.Context, dir p2p_pstore.PeerInfo) ([]string, error) {
+			peers, err := node.doDirListVerboseImpl(ctx, dir, ns, xoffset, xlimit)
+			if err != nil {
+				return nil, err
+			}
+
+			strs := make([]string, len(peers))
+			for x, peer := range peers {
+				bytes, err := json.Marshal(peer)
+				if err != nil {
+					return nil, err
+				}
+				strs[x] = string(bytes)
+			}
+
+			return strs, nil
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	pmap := make(map[string]DirectoryPeer)
+	for _, str := range strs {
+		var peer DirectoryPeer
+		err := json.Unmarshal([]byte(str), &peer)
+		if err != nil {
+			return nil, err
+		}
+
+		xpeer, ok := pmap[peer.ID]
+		if !ok || peer.LastSeen > xpeer.LastSeen {
+			pmap[peer.ID] = peer
+		}
+	}
+
+	pids := make([]string, 0, len(pmap))
+	for pid, _ := range pmap {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+
+	if len(dirs) > 1 {
+		switch {
+		case offset >= len(pids):
+			pids = nil
+		case limit > 0 && offset+limit < len(pids):
+			pids = pids[offset : offset+limit]
+		default:
+			pids = pids[offset:]
+		}
+	}
+
+	peers := make([]DirectoryPeer, len(pids))
+	for x, pid := range pids {
+		peers[x] = pmap[pid]
+	}
+
+	return peers, nil
+}
+
+// doDirListVerboseExcludeSelf lists a page of the peers in all directories,
+// excluding the node itself; the page is taken from a listing of the first
+// offset+limit+1 peers, with the node filtered out.
+func (node *Node) doDirListVerboseExcludeSelf(ctx context.Context, ns string, offset, limit int) ([]DirectoryPeer, error) {
+	xlimit := 0
+	if limit > 0 {
+		xlimit = offset + limit + 1
+	}
+
+	peers, err := node.doDirListVerbose(ctx, ns, 0, xlimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return excludePeerPage(peers, node.PeerIdentity.Pretty(), offset, limit), nil
+}
+
+// excludePeerPage removes a peer from a listing and returns the page at
+// offset; a limit of 0 returns all peers after the offset.
+func excludePeerPage(peers []DirectoryPeer, pid string, offset, limit int) []DirectoryPeer {
+	res := make([]DirectoryPeer, 0, len(peers))
+	for _, peer := range peers {
+		if peer.ID != pid {
+			res = append(res, peer)
+		}
+	}
+
+	switch {
+	case offset >= len(res):
+		return nil
+	case limit > 0 && offset+limit < len(res):
+		return res[offset : offset+limit]
+	default:
+		return res[offset:]
+	}
+}
+
 func (node *Node) doDirCollect(ctx context.Context, proc func(ctx context.Context, dir p2p_pstore.PeerInfo) ([]string, error)) ([]string, error) {
 	dirs := node.dir
 
@@ -756,6 +1067,81 @@ func (node *Node) doDirListImpl(ctx context.Context, dir p2p_pstore.PeerInfo, ns
 	return res.Peers, nil
 }
 
+// doDirListVerboseImpl retrieves a page of the peer listing from a directory,
+// fetching as many directory pages as needed; a limit of 0 retrieves all
+// peers after the offset.
+func (node *Node) doDirListVerboseImpl(ctx context.Context, dir p2p_pstore.PeerInfo, ns string, offset, limit int) ([]DirectoryPeer, error) {
+	s, err := node.doDirConnect(ctx, dir, "/mediachain/dir/listpeers")
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	w := ggio.NewDelimitedWriter(s)
+	r := ggio.NewDelimitedReader(s, mc.MaxMessageSize)
+
+	var req pb.ListPeersVerboseRequest
+	var res pb.ListPeersVerboseResponse
+
+	req.Namespace = ns
+	req.Offset = int64(offset)
+
+	peers := make([]DirectoryPeer, 0)
+	for {
+		if limit > 0 {
+			req.Limit = int64(limit - len(peers))
+		}
+
+		err = w.WriteMsg(&req)
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.ReadMsg(&res)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, lst := range res.Peers {
+			if lst.Info == nil {
+				return nil, BadResponse
+			}
+
+			pinfo, err := mc.PBToPeerInfo(lst.Info)
+			if err != nil {
+				return nil, err
+			}
+
+			peer := DirectoryPeer{
+				ID:       pinfo.ID.Pretty(),
+				Addrs:    make([]string, len(pinfo.Addrs)),
+				Info:     lst.NodeInfo,
+				Manifest: lst.Manifest,
+				LastSeen: lst.LastSeen,
+				Latency:  lst.Latency,
+			}
+
+			for x, addr := range pinfo.Addrs {
+				peer.Addrs[x] = addr.String()
+			}
+
+			if lst.Publisher != nil {
+				peer.Publisher = lst.Publisher.Id
+				peer.Namespaces = lst.Publisher.Namespaces
+			}
+
+			peers = append(peers, peer)
+		}
+
+		req.Offset += int64(len(res.Peers))
+		if len(res.Peers) == 0 || req.Offset >= res.Total || (limit > 0 && len(peers) >= limit) {
+			return peers, nil
+		}
+
+		res.Reset()
+	}
+}
+
 func (node *Node) doDirListNSImpl(ctx context.Context, dir p2p_pstore.PeerInfo) ([]string, error) {
 	s, err := node.doDirConnect(ctx, dir, "/mediachain/dir/listns")
 	if err != nil {
@@ -782,6 +1168,43 @@ func (node *Node) doDirListNSImpl(ctx context.Context, dir p2p_pstore.PeerInfo)
 	return res.Namespaces, nil
 }
 
+func (node *Node) doDirListNSStatsImpl(ctx context.Context, dir p2p_pstore.PeerInfo) ([]*pb.NamespaceListing, error) {
+	s, err := node.doDirConnect(ctx, dir, "/mediachain/dir/listns")
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	w := ggio.NewDelimitedWriter(s)
+	r := ggio.NewDelimitedReader(s, mc.MaxMessageSize)
+
+	var req pb.ListNamespacesRequest
+	var res pb.ListNamespacesResponse
+
+	req.Stats = true
+
+	err = w.WriteMsg(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.ReadMsg(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	// directories that don't support stats only list the namespaces
+	if len(res.Stats) == 0 {
+		nss := make([]*pb.NamespaceListing, len(res.Namespaces))
+		for x, ns := range res.Namespaces {
+			nss[x] = &pb.NamespaceListing{Namespace: ns}
+		}
+		return nss, nil
+	}
+
+	return res.Stats, nil
+}
+
 func (node *Node) doDirListMFImpl(ctx context.Context, dir p2p_pstore.PeerInfo, entity string) ([]*pb.Manifest, error) {
 	s, err := node.doDirConnect(ctx, dir, "/mediachain/dir/listmf")
 	if err != nil {
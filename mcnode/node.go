@@ -43,6 +43,19 @@ type Node struct {
 	ds        Datastore
 	auth      PeerAuth
 	mfs       []*pb.Manifest
+	mfrevs    []*pb.ManifestRevocation
+	nsmfs     []*pb.Manifest   // namespace manifests
+	nspolicy  *NamespacePolicy // nil if namespaces are not enforced
+	provide   *ProvideConfig
+	provwake  chan struct{} // wakes the provider loop on config changes
+	lazy      bool
+	lazydb    *LazyDB
+	pindb     *PinDB
+	retractdb *RetractDB
+	compress  bool // request compressed data transfers
+	nsstats   bool // advertise namespace stats to directories
+	nsscache  NamespaceStatsCache
+	gcs       GCState
 	mx        sync.Mutex
 	counter   int
 }
@@ -58,10 +71,50 @@ type StatementDB interface {
 	Merge(*pb.Statement) (bool, error)
 	MergeBatch([]*pb.Statement) (int, error)
 	Delete(*mcq.Query) (int, error)
+	QueryLatest(wki string, publisher string) ([]*pb.Statement, error)
+	QueryHistory(wki string, publisher string) ([]*pb.Statement, error)
 	Vacuum(full bool) error
+	Check(verify StatementVerifier) (*DBCheckReport, error)
+	Rebuild() (int, error)
+	Backup(dir string) error
 	Close() error
 }
 
+// StatementVerifier checks statement signatures in db integrity checks
+type StatementVerifier func(*pb.Statement) (bool, error)
+
+// DBCheckReport is the result of a statement db integrity check;
+// problems are reported as lists of statement ids.
+type DBCheckReport struct {
+	Statements   int      `json:"statements"`
+	BadData      []string `json:"badData,omitempty"`      // undecodable or id mismatch
+	BadEnvelope  []string `json:"badEnvelope,omitempty"`  // missing or inconsistent envelope
+	BadRefs      []string `json:"badRefs,omitempty"`      // missing or inconsistent refs
+	BadSignature []string `json:"badSignature,omitempty"` // signature verification failed
+	Orphans      []string `json:"orphans,omitempty"`      // index entries without a statement
+}
+
+func (report *DBCheckReport) OK() bool {
+	return len(report.BadData) == 0 &&
+		len(report.BadEnvelope) == 0 &&
+		len(report.BadRefs) == 0 &&
+		len(report.BadSignature) == 0 &&
+		len(report.Orphans) == 0
+}
+
+// checkSignature records a statement with a bad signature in the report;
+// verification errors (eg an unparseable publisher) count as failures.
+func (report *DBCheckReport) checkSignature(stmt *pb.Statement, verify StatementVerifier) {
+	if verify == nil {
+		return
+	}
+
+	ok, err := verify(stmt)
+	if err != nil || !ok {
+		report.BadSignature = append(report.BadSignature, stmt.Id)
+	}
+}
+
 type Key multihash.Multihash
 type Datastore interface {
 	Open(home string) error
@@ -70,9 +123,13 @@ type Datastore interface {
 	Has(Key) (bool, error)
 	Get(Key) ([]byte, error)
 	Delete(Key) error
-	IterKeys(ctx context.Context) (<-chan Key, error)
+	// IterKeys streams the keys in the datastore; if the iteration fails
+	// before the end, the error is delivered on the error channel once the
+	// key channel is closed.
+	IterKeys(ctx context.Context) (<-chan Key, <-chan error, error)
 	Sync() error
 	Compact()
+	Backup(dir string) error
 	Close()
 }
 
@@ -95,6 +152,18 @@ type NodeInfo struct {
 	Info      string `json:"info"`
 }
 
+// DirectoryPeer is a verbose directory listing entry
+type DirectoryPeer struct {
+	ID         string         `json:"id"`
+	Addrs      []string       `json:"addrs"`
+	Publisher  string         `json:"publisher,omitempty"`
+	Namespaces []string       `json:"namespaces,omitempty"`
+	Info       string         `json:"info,omitempty"`
+	Manifest   []*pb.Manifest `json:"manifest,omitempty"`
+	LastSeen   int64          `json:"lastSeen,omitempty"`
+	Latency    int64          `json:"latency,omitempty"` // ms, as probed by the directory
+}
+
 type NetIdentify struct {
 	ID              string
 	PublicKey       []byte
@@ -114,6 +183,8 @@ var (
 	BadNamespace     = errors.New("Illegal namespace")
 	BadResult        = errors.New("Bad result set")
 	BadStatement     = errors.New("Bad statement; verification failed")
+	BadRecord        = errors.New("Bad statement record")
+	DupStatement     = errors.New("Duplicate statement")
 	NoResult         = errors.New("Empty result set")
 	MissingData      = errors.New("Missing statement metadata")
 	UnexpectedData   = errors.New("Unexpected data object")
@@ -121,6 +192,8 @@ var (
 	BadPush          = errors.New("Bad push value; unexpected object")
 	BadResponse      = errors.New("Bad response; unexpected object")
 	BadRuleset       = errors.New("Bad auth ruleset; unexpected object")
+	BadProvideConfig = errors.New("Bad provider configuration")
+	BadPagination    = errors.New("Bad pagination parameters")
 	NodeOffline      = errors.New("Node is offline")
 	NoDirectory      = errors.New("No directory server")
 	DirectoryError   = errors.New("Directory error")
@@ -172,8 +245,21 @@ func (node *Node) doPublish(ns string, body interface{}) (string, error) {
 		return "", err
 	}
 
+	defer node.gcs.beginWrite().Done()
+	node.gcs.touchStatements([]*pb.Statement{stmt})
+	defer node.nsscache.touch([]*pb.Statement{stmt})
+
 	err = node.db.Put(stmt)
-	return stmt.Id, err
+	if err != nil {
+		return "", err
+	}
+
+	_, err = node.applyRetractions([]*pb.Statement{stmt})
+	if err != nil {
+		return "", err
+	}
+
+	return stmt.Id, nil
 }
 
 func (node *Node) doPublishBatch(ns string, lst []interface{}) ([]string, error) {
@@ -188,31 +274,75 @@ func (node *Node) doPublishBatch(ns string, lst []interface{}) ([]string, error)
 		sids[x] = stmt.Id
 	}
 
+	defer node.gcs.beginWrite().Done()
+	node.gcs.touchStatements(stmts)
+	defer node.nsscache.touch(stmts)
+
 	err := node.db.PutBatch(stmts)
 	if err != nil {
 		return nil, err
 	}
 
-	return sids, err
+	_, err = node.applyRetractions(stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	return sids, nil
 }
 
 func (node *Node) doImport(stmts []*pb.Statement, pkcache map[string]p2p_crypto.PubKey) (int, error) {
+	stmts, err := node.checkImport(stmts, pkcache)
+	if err != nil {
+		return 0, err
+	}
+
+	return node.mergeImport(stmts)
+}
+
+// checkImport verifies a batch of imported statements and returns the
+// statements to merge, skipping retracted statements and statements by
+// publishers not authorized in their namespace.
+func (node *Node) checkImport(stmts []*pb.Statement, pkcache map[string]p2p_crypto.PubKey) ([]*pb.Statement, error) {
 	for _, stmt := range stmts {
 		if !node.checkStatement(stmt) {
-			return 0, BadStatement
+			return nil, BadStatement
 		}
 
 		verify, err := node.verifyStatementCacheKeys(stmt, pkcache)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
 		if !verify {
-			return 0, BadStatement
+			return nil, BadStatement
 		}
 	}
 
-	return node.db.MergeBatch(stmts)
+	stmts, err := node.filterRetracted(stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.namespacePolicy().filterUnauthorized(stmts), nil
+}
+
+func (node *Node) mergeImport(stmts []*pb.Statement) (int, error) {
+	defer node.gcs.beginWrite().Done()
+	node.gcs.touchStatements(stmts)
+	defer node.nsscache.touch(stmts)
+
+	count, err := node.db.MergeBatch(stmts)
+	if err != nil {
+		return count, err
+	}
+
+	_, err = node.applyRetractions(stmts)
+	if err != nil {
+		return count, err
+	}
+
+	return count, nil
 }
 
 func (node *Node) makeStatement(ns string, body interface{}) (*pb.Statement, error) {
@@ -237,6 +367,9 @@ func (node *Node) makeStatement(ns string, body interface{}) (*pb.Statement, err
 	case *pb.ArchiveStatement:
 		stmt.Body = &pb.StatementBody{&pb.StatementBody_Archive{body}}
 
+	case *pb.RetractStatement:
+		stmt.Body = &pb.StatementBody{&pb.StatementBody_Retract{body}}
+
 	default:
 		return nil, BadStatementBody
 	}
@@ -311,24 +444,93 @@ func (node *Node) verifyStatementSig(stmt *pb.Statement, pubk p2p_crypto.PubKey)
 	return pubk.Verify(bytes, sig)
 }
 
-func (node *Node) openDB() error {
-	node.db = &SQLiteDB{}
+func (node *Node) openDB(backend string) error {
+	switch backend {
+	case "sqlite":
+		db, err := newSQLiteDB()
+		if err != nil {
+			return err
+		}
+		node.db = db
+	case "bolt":
+		node.db = &BoltDB{}
+	case "memory":
+		node.db = &MemDB{}
+	default:
+		return fmt.Errorf("Unknown statement db backend: %s", backend)
+	}
+
 	return node.db.Open(node.home)
 }
 
-func (node *Node) openDS() error {
-	node.ds = &RocksDS{}
+func (node *Node) openDS(backend string, compress bool) error {
+	ds, err := newDatastore(backend, compress)
+	if err != nil {
+		return err
+	}
+
+	node.ds = ds
 	return node.ds.Open(node.home)
 }
 
+// newMemoryNode creates a node with an ephemeral identity and in-memory
+// statement db and datastore; nothing is written to disk, including the
+// node configuration.
+func newMemoryNode(laddr multiaddr.Multiaddr) (*Node, error) {
+	id, err := mc.NewPeerIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	pubid, err := mc.NewPublisherIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &Node{PeerIdentity: id, publisher: pubid, home: ":memory:", laddr: laddr}
+
+	err = node.openDB("memory")
+	if err != nil {
+		return nil, err
+	}
+
+	err = node.openDS("memory", false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = node.openLazyDB()
+	if err != nil {
+		return nil, err
+	}
+
+	err = node.openPinDB()
+	if err != nil {
+		return nil, err
+	}
+
+	err = node.openRetractDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
 // persistent configuration
 type NodeConfig struct {
-	Info     string                 `json:"info,omitempty"`
-	NAT      string                 `json:"nat,omitempty"`
-	Dir      string                 `json:"dir,omitempty"` // backwards compatibility
-	Dirs     []string               `json:"dirs,omitempty"`
-	Auth     map[string]interface{} `json:"auth,omitempty"`
-	Manifest []*pb.Manifest         `json:"manifest,omitempty"`
+	Info       string                   `json:"info,omitempty"`
+	NAT        string                   `json:"nat,omitempty"`
+	Dir        string                   `json:"dir,omitempty"` // backwards compatibility
+	Dirs       []string                 `json:"dirs,omitempty"`
+	Auth       map[string]interface{}   `json:"auth,omitempty"`
+	Manifest   []*pb.Manifest           `json:"manifest,omitempty"`
+	Revoke     []*pb.ManifestRevocation `json:"revoke,omitempty"`
+	NSManifest []*pb.Manifest           `json:"nsmanifest,omitempty"`
+	Provide    *ProvideConfig           `json:"provide,omitempty"`
+	Lazy       bool                     `json:"lazy,omitempty"`
+	Compress   bool                     `json:"compress,omitempty"`
+	NSStats    bool                     `json:"nsstats,omitempty"`
 }
 
 func (node *Node) saveConfig() error {
@@ -344,12 +546,22 @@ func (node *Node) saveConfig() error {
 	}
 	cfg.Auth = node.auth.toJSON()
 	cfg.Manifest = node.mfs
+	cfg.Revoke = node.mfrevs
+	cfg.NSManifest = node.nsmfs
+	cfg.Provide = node.provideConfig()
+	cfg.Lazy = node.lazy
+	cfg.Compress = node.compress
+	cfg.NSStats = node.nsstats
 
 	bytes, err := json.Marshal(cfg)
 	if err != nil {
 		return err
 	}
 
+	if node.home == ":memory:" {
+		return nil
+	}
+
 	cfgpath := path.Join(node.home, "config.json")
 	return ioutil.WriteFile(cfgpath, bytes, 0644)
 }
@@ -405,6 +617,13 @@ func (node *Node) loadConfig() error {
 	}
 
 	node.mfs = cfg.Manifest
+	node.mfrevs = cfg.Revoke
+	node.nsmfs = cfg.NSManifest
+	node.updateNamespacePolicy()
+	node.setProvideConfig(cfg.Provide)
+	node.lazy = cfg.Lazy
+	node.compress = cfg.Compress
+	node.nsstats = cfg.NSStats
 
 	return nil
 }
@@ -416,6 +635,18 @@ func (node *Node) doShutdown() {
 		log.Printf("Error closing StatementDB: %s", err.Error())
 	}
 	node.ds.Close()
+	err = node.lazydb.Close()
+	if err != nil {
+		log.Printf("Error closing LazyDB: %s", err.Error())
+	}
+	err = node.pindb.Close()
+	if err != nil {
+		log.Printf("Error closing PinDB: %s", err.Error())
+	}
+	err = node.retractdb.Close()
+	if err != nil {
+		log.Printf("Error closing RetractDB: %s", err.Error())
+	}
 	os.Exit(0)
 }
 
@@ -0,0 +1,1275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	bolt "github.com/boltdb/bolt"
+	p2p_crypto "github.com/libp2p/go-libp2p-crypto"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	p2p_pstore "github.com/libp2p/go-libp2p-peerstore"
+	mc "github.com/mediachain/concat/mc"
+	mcq "github.com/mediachain/concat/mc/query"
+	pb "github.com/mediachain/concat/proto"
+	multihash "github.com/multiformats/go-multihash"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func checkError(t *testing.T, where string, err error) {
+	if err != nil {
+		t.Fatalf("%s: %s", where, err.Error())
+	}
+}
+
+func checkBool(t *testing.T, where string, e bool) {
+	if !e {
+		t.Errorf("%s: check failed", where)
+	}
+}
+
+func makeTestStatement(id, ns, pub string, ts int64) *pb.Statement {
+	return &pb.Statement{
+		Id:        id,
+		Publisher: pub,
+		Namespace: ns,
+		Body:      &pb.StatementBody{&pb.StatementBody_Simple{&pb.SimpleStatement{Object: "Qm" + id, Refs: []string{"wki:" + id}}}},
+		Timestamp: ts}
+}
+
+func makeTestVersion(id, pub, wki string, ts int64) *pb.Statement {
+	return &pb.Statement{
+		Id:        id,
+		Publisher: pub,
+		Namespace: "foo",
+		Body:      &pb.StatementBody{&pb.StatementBody_Simple{&pb.SimpleStatement{Object: "Qm" + id, Refs: []string{wki}}}},
+		Timestamp: ts}
+}
+
+func makeTestRetraction(id, pub string, ids []string, ts int64) *pb.Statement {
+	return &pb.Statement{
+		Id:        id,
+		Publisher: pub,
+		Namespace: "foo",
+		Body:      &pb.StatementBody{&pb.StatementBody_Retract{&pb.RetractStatement{Statements: ids}}},
+		Timestamp: ts}
+}
+
+func stmtIds(stmts []*pb.Statement) []string {
+	ids := make([]string, len(stmts))
+	for x, stmt := range stmts {
+		ids[x] = stmt.Id
+	}
+	return ids
+}
+
+func parseQuery(t *testing.T, qs string) *mcq.Query {
+	q, err := mcq.ParseQuery(qs)
+	checkError(t, qs, err)
+	return q
+}
+
+func TestMemDB(t *testing.T) {
+	db := &MemDB{}
+	err := db.Open(":memory:")
+	checkError(t, "Open", err)
+
+	a := makeTestStatement("a", "foo.a", "A", 100)
+	b := makeTestStatement("b", "foo.b", "B", 200)
+	c := makeTestStatement("c", "bar", "A", 300)
+
+	err = db.PutBatch([]*pb.Statement{a, b})
+	checkError(t, "PutBatch", err)
+
+	err = db.Put(a)
+	checkBool(t, "Put duplicate", err == DupStatement)
+
+	ok, err := db.Merge(a)
+	checkError(t, "Merge", err)
+	checkBool(t, "Merge duplicate", !ok)
+
+	count, err := db.MergeBatch([]*pb.Statement{b, c})
+	checkError(t, "MergeBatch", err)
+	checkBool(t, "MergeBatch count", count == 1)
+
+	stmt, err := db.Get("c")
+	checkError(t, "Get", err)
+	checkBool(t, "Get", stmt.Id == "c" && stmt.Namespace == "bar")
+
+	_, err = db.Get("d")
+	checkBool(t, "Get unknown", err == UnknownStatement)
+
+	res, err := db.Query(parseQuery(t, "SELECT id FROM foo.* ORDER BY counter DESC"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query result", len(res) == 2 && res[0] == "b" && res[1] == "a")
+
+	val, err := db.QueryOne(parseQuery(t, "SELECT COUNT(*) FROM *"))
+	checkError(t, "QueryOne", err)
+	checkBool(t, "QueryOne result", val == 3)
+
+	_, err = db.QueryOne(parseQuery(t, "SELECT id FROM baz"))
+	checkBool(t, "QueryOne empty", err == NoResult)
+
+	ch, err := db.QueryStream(context.Background(), parseQuery(t, "SELECT * FROM * WHERE publisher = A"))
+	checkError(t, "QueryStream", err)
+	ids := make([]string, 0)
+	for val := range ch {
+		ids = append(ids, val.(*pb.Statement).Id)
+	}
+	checkBool(t, "QueryStream result", len(ids) == 2 && ids[0] == "a" && ids[1] == "c")
+
+	count, err = db.Delete(parseQuery(t, "DELETE FROM * WHERE publisher = A"))
+	checkError(t, "Delete", err)
+	checkBool(t, "Delete count", count == 2)
+
+	res, err = db.Query(parseQuery(t, "SELECT (id, counter) FROM *"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query after delete", len(res) == 1)
+	if len(res) == 1 {
+		obj := res[0].(map[string]interface{})
+		checkBool(t, "Query after delete", obj["id"] == "b" && obj["counter"] == int64(2))
+	}
+}
+
+func TestMemDBLatest(t *testing.T) {
+	db := &MemDB{}
+	err := db.Open(":memory:")
+	checkError(t, "Open", err)
+
+	a1 := makeTestVersion("A:100:9", "A", "wki:x", 100)
+	a2 := makeTestVersion("A:100:10", "A", "wki:x", 100)
+	b1 := makeTestVersion("B:200:0", "B", "wki:x", 200)
+	a0 := makeTestVersion("A:50:0", "A", "wki:x", 50)
+	// retractions are not versions
+	r := makeTestRetraction("A:300:0", "A", []string{"wki:x"}, 300)
+	err = db.PutBatch([]*pb.Statement{a1, a2, b1, a0, r})
+	checkError(t, "PutBatch", err)
+
+	res, err := db.QueryLatest("wki:x", "")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest", reflect.DeepEqual(stmtIds(res), []string{"A:100:10", "B:200:0"}))
+
+	res, err = db.QueryHistory("wki:x", "A")
+	checkError(t, "QueryHistory", err)
+	checkBool(t, "QueryHistory", reflect.DeepEqual(stmtIds(res), []string{"A:50:0", "A:100:9", "A:100:10"}))
+
+	_, err = db.Delete(parseQuery(t, "DELETE FROM * WHERE id = A:100:10"))
+	checkError(t, "Delete", err)
+
+	res, err = db.QueryLatest("wki:x", "A")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest after delete", reflect.DeepEqual(stmtIds(res), []string{"A:100:9"}))
+}
+
+func TestFilterVersions(t *testing.T) {
+	a := makeTestVersion("A:100:0", "A", "wki:x", 100)
+	r := makeTestRetraction("A:300:0", "A", []string{"A:100:0"}, 300)
+	stmts := []*pb.Statement{r, a}
+
+	res := filterVersions(stmts)
+	checkBool(t, "filterVersions", reflect.DeepEqual(stmtIds(res), []string{"A:100:0"}))
+	checkBool(t, "filterVersions input", reflect.DeepEqual(stmtIds(stmts), []string{"A:300:0", "A:100:0"}))
+}
+
+func boltCounter(x uint64) []byte {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, x)
+	return counter
+}
+
+func TestBoltLatest(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	db := &BoltDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+	defer db.Close()
+
+	a1 := makeTestVersion("A:100:9", "A", "wki:x", 100)
+	a2 := makeTestVersion("A:100:10", "A", "wki:x", 100)
+	b1 := makeTestVersion("B:200:0", "B", "wki:x", 200)
+	a0 := makeTestVersion("A:50:0", "A", "wki:x", 50)
+	// retractions are not versions
+	r := makeTestRetraction("A:300:0", "A", []string{"wki:x"}, 300)
+	err = db.PutBatch([]*pb.Statement{a1, a2, b1, a0, r})
+	checkError(t, "PutBatch", err)
+
+	res, err := db.QueryLatest("wki:x", "")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest", reflect.DeepEqual(stmtIds(res), []string{"A:100:10", "B:200:0"}))
+
+	res, err = db.QueryHistory("wki:x", "A")
+	checkError(t, "QueryHistory", err)
+	checkBool(t, "QueryHistory", reflect.DeepEqual(stmtIds(res), []string{"A:50:0", "A:100:9", "A:100:10"}))
+
+	_, err = db.Delete(parseQuery(t, "DELETE FROM * WHERE id = A:100:10"))
+	checkError(t, "Delete", err)
+
+	res, err = db.QueryLatest("wki:x", "A")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest after delete", reflect.DeepEqual(stmtIds(res), []string{"A:100:9"}))
+
+	// merging an older version leaves the latest version in place
+	a3 := makeTestVersion("A:60:0", "A", "wki:x", 60)
+	_, err = db.MergeBatch([]*pb.Statement{a3})
+	checkError(t, "MergeBatch", err)
+
+	res, err = db.QueryLatest("wki:x", "A")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest after merge", reflect.DeepEqual(stmtIds(res), []string{"A:100:9"}))
+
+	// dbs without the latest index are indexed on open
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(boltLatestBucket)
+	})
+	checkError(t, "DeleteBucket", err)
+	checkError(t, "Close", db.Close())
+	checkError(t, "Open", db.Open(home))
+
+	res, err = db.QueryLatest("wki:x", "")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest after reindex", reflect.DeepEqual(stmtIds(res), []string{"A:100:9", "B:200:0"}))
+
+	// with only the retraction left there is no latest version
+	_, err = db.Delete(parseQuery(t, "DELETE FROM * WHERE publisher = A AND timestamp < 300"))
+	checkError(t, "Delete", err)
+
+	res, err = db.QueryLatest("wki:x", "A")
+	checkError(t, "QueryLatest", err)
+	checkBool(t, "QueryLatest after retraction", len(res) == 0)
+
+	report, err := db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check", report.OK())
+}
+
+func TestBoltRebuild(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	db := &BoltDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+	defer db.Close()
+
+	a := makeTestStatement("a", "foo", "A", 100)
+	b := makeTestStatement("b", "foo", "B", 200)
+	c := makeTestStatement("c", "bar", "A", 300)
+	err = db.PutBatch([]*pb.Statement{a, b, c})
+	checkError(t, "PutBatch", err)
+
+	// damage the indexes and give c the counter of b
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		envs := tx.Bucket(boltEnvelopeBucket)
+		err := envs.Delete(boltCounter(1))
+		if err != nil {
+			return err
+		}
+
+		err = envs.Put(boltCounter(9), []byte("x"))
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(boltVersionBucket).Delete(boltVersionKey("wki:b", b))
+		if err != nil {
+			return err
+		}
+
+		stmts := tx.Bucket(boltStatementBucket)
+		val := stmts.Get([]byte("c"))
+		xval := make([]byte, len(val))
+		copy(xval, val)
+		copy(xval, boltCounter(2))
+		return stmts.Put([]byte("c"), xval)
+	})
+	checkError(t, "Update", err)
+
+	count, err := db.Rebuild()
+	checkError(t, "Rebuild", err)
+	checkBool(t, "Rebuild count", count == 3)
+
+	res, err := db.Query(parseQuery(t, "SELECT (id, counter) FROM * ORDER BY counter"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query", len(res) == 3)
+	if len(res) == 3 {
+		// a and b keep their counters, c is appended after the last counter
+		xres := []interface{}{
+			map[string]interface{}{"id": "a", "counter": int64(1)},
+			map[string]interface{}{"id": "b", "counter": int64(2)},
+			map[string]interface{}{"id": "c", "counter": int64(4)}}
+		checkBool(t, "Query", reflect.DeepEqual(res, xres))
+	}
+
+	res, err = db.Query(parseQuery(t, "SELECT id FROM * WHERE wki = wki:b"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query refs", len(res) == 1 && res[0] == "b")
+
+	res, err = db.Query(parseQuery(t, "SELECT id FROM bar"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query namespace", len(res) == 1 && res[0] == "c")
+
+	report, err := db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check after rebuild", report.Statements == 3 && report.OK())
+}
+
+func TestBoltCheck(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	db := &BoltDB{}
+	err = db.Open(home)
+	checkError(t, "Open", err)
+	defer db.Close()
+
+	a := makeTestStatement("a", "foo", "A", 100)
+	b := makeTestStatement("b", "foo", "B", 200)
+	c := makeTestStatement("c", "bar", "A", 300)
+	err = db.PutBatch([]*pb.Statement{a, b, c})
+	checkError(t, "PutBatch", err)
+
+	report, err := db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check", report.Statements == 3 && report.OK())
+
+	// damage the indexes
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(boltNamespaceBucket).Delete(boltNamespaceKey("foo", boltCounter(1)))
+		if err != nil {
+			return err
+		}
+
+		vers := tx.Bucket(boltVersionBucket)
+		err = vers.Delete(boltVersionKey("wki:b", b))
+		if err != nil {
+			return err
+		}
+
+		x := makeTestStatement("x", "foo", "X", 400)
+		return vers.Put(boltVersionKey("wki:x", x), []byte("x"))
+	})
+	checkError(t, "Update", err)
+
+	report, err = db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check envelope", reflect.DeepEqual(report.BadEnvelope, []string{"a"}))
+	checkBool(t, "Check refs", reflect.DeepEqual(report.BadRefs, []string{"b"}))
+	checkBool(t, "Check orphans", reflect.DeepEqual(report.Orphans, []string{"x"}))
+	checkBool(t, "Check data", len(report.BadData) == 0)
+
+	// signatures are checked with the verifier
+	report, err = db.Check(func(stmt *pb.Statement) (bool, error) {
+		return stmt.Id != "c", nil
+	})
+	checkError(t, "Check", err)
+	checkBool(t, "Check signature", reflect.DeepEqual(report.BadSignature, []string{"c"}))
+
+	_, err = db.Rebuild()
+	checkError(t, "Rebuild", err)
+
+	report, err = db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check after rebuild", report.Statements == 3 && report.OK())
+
+	// records that don't decode to their statement are reported as bad data
+	// and can't be rebuilt
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		stmts := tx.Bucket(boltStatementBucket)
+		return stmts.Put([]byte("d"), stmts.Get([]byte("c")))
+	})
+	checkError(t, "Update", err)
+
+	report, err = db.Check(nil)
+	checkError(t, "Check", err)
+	checkBool(t, "Check bad data", reflect.DeepEqual(report.BadData, []string{"d"}))
+
+	_, err = db.Rebuild()
+	checkBool(t, "Rebuild bad data", err != nil)
+}
+
+func TestMemDS(t *testing.T) {
+	ds := &MemDS{}
+	err := ds.Open(":memory:")
+	checkError(t, "Open", err)
+
+	key, err := ds.Put([]byte("a"))
+	checkError(t, "Put", err)
+
+	keys, err := ds.PutBatch([][]byte{[]byte("b"), []byte("c")})
+	checkError(t, "PutBatch", err)
+	checkBool(t, "PutBatch", len(keys) == 2)
+
+	have, err := ds.Has(key)
+	checkError(t, "Has", err)
+	checkBool(t, "Has", have)
+
+	data, err := ds.Get(keys[1])
+	checkError(t, "Get", err)
+	checkBool(t, "Get", bytes.Equal(data, []byte("c")))
+
+	err = ds.Delete(key)
+	checkError(t, "Delete", err)
+
+	have, err = ds.Has(key)
+	checkError(t, "Has", err)
+	checkBool(t, "Has deleted", !have)
+
+	data, err = ds.Get(key)
+	checkError(t, "Get", err)
+	checkBool(t, "Get deleted", data == nil)
+
+	ch, errc, err := ds.IterKeys(context.Background())
+	checkError(t, "IterKeys", err)
+	count := 0
+	for key := range ch {
+		checkBool(t, "IterKeys", bytes.Equal(key, keys[0]) || bytes.Equal(key, keys[1]))
+		count++
+	}
+	checkError(t, "IterKeys", <-errc)
+	checkBool(t, "IterKeys count", count == 2)
+}
+
+func TestBoltDS(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	ds := &BoltDS{}
+	err = ds.Open(home)
+	checkError(t, "Open", err)
+	defer ds.Close()
+
+	// more than one iteration batch
+	batch := make([][]byte, 2500)
+	for x, _ := range batch {
+		batch[x] = []byte(fmt.Sprintf("%d", x))
+	}
+
+	keys, err := ds.PutBatch(batch)
+	checkError(t, "PutBatch", err)
+
+	// deleting while iterating, like the gc
+	ch, errc, err := ds.IterKeys(context.Background())
+	checkError(t, "IterKeys", err)
+	seen := make(map[string]bool)
+	for key := range ch {
+		seen[string(key)] = true
+		err = ds.Delete(key)
+		checkError(t, "Delete", err)
+	}
+	checkError(t, "IterKeys", <-errc)
+	checkBool(t, "IterKeys count", len(seen) == len(keys))
+
+	for _, key := range keys {
+		have, err := ds.Has(key)
+		checkError(t, "Has", err)
+		checkBool(t, "Has deleted", !have)
+	}
+}
+
+func TestMigrateDatastore(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	ds := &BoltDS{}
+	err = ds.Open(home)
+	checkError(t, "Open", err)
+
+	batch := make([][]byte, 1500)
+	for x, _ := range batch {
+		batch[x] = []byte(fmt.Sprintf("%d", x))
+	}
+
+	keys, err := ds.PutBatch(batch)
+	checkError(t, "PutBatch", err)
+
+	// corrupt an object
+	err = ds.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDataBucket).Put(keys[0][2:], []byte("x"))
+	})
+	checkError(t, "Update", err)
+
+	// iteration errors are reported after the keys
+	ch, errc, err := ds.IterKeys(context.Background())
+	checkError(t, "IterKeys", err)
+	<-ch
+	ds.Close()
+	for range ch {
+	}
+	checkBool(t, "IterKeys error", <-errc != nil)
+
+	count, bad, err := migrateDatastore(home, "bolt", "memory", false)
+	checkError(t, "migrateDatastore", err)
+	checkBool(t, "migrateDatastore count", count == len(batch)-1)
+	checkBool(t, "migrateDatastore bad", bad == 1)
+}
+
+func TestAuxDB(t *testing.T) {
+	home, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(home)
+
+	for _, xhome := range []string{":memory:", home} {
+		db, err := openAuxDB(xhome, "test")
+		checkError(t, "openAuxDB", err)
+
+		count, err := db.Put([]AuxEntry{{"a", []byte("1")}, {"b", []byte("2")}}, false)
+		checkError(t, "Put", err)
+		checkBool(t, "Put count", count == 2)
+
+		count, err = db.Put([]AuxEntry{{"a", []byte("3")}, {"c", []byte("4")}}, false)
+		checkError(t, "Put", err)
+		checkBool(t, "Put existing count", count == 1)
+
+		val, err := db.Get("a")
+		checkError(t, "Get", err)
+		checkBool(t, "Get no replace", bytes.Equal(val, []byte("1")))
+
+		_, err = db.Put([]AuxEntry{{"a", []byte("3")}}, true)
+		checkError(t, "Put", err)
+
+		val, err = db.Get("a")
+		checkError(t, "Get", err)
+		checkBool(t, "Get replace", bytes.Equal(val, []byte("3")))
+
+		res, err := db.Scan("", 2)
+		checkError(t, "Scan", err)
+		checkBool(t, "Scan", len(res) == 2 && res[0].Key == "a" && res[1].Key == "b")
+
+		res, err = db.Scan("b", 2)
+		checkError(t, "Scan", err)
+		checkBool(t, "Scan resume", len(res) == 1 && res[0].Key == "c")
+
+		count, err = db.Delete([]string{"a", "x"})
+		checkError(t, "Delete", err)
+		checkBool(t, "Delete count", count == 1)
+
+		have, err := db.Has("a")
+		checkError(t, "Has", err)
+		checkBool(t, "Has deleted", !have)
+
+		val, err = db.Get("a")
+		checkError(t, "Get", err)
+		checkBool(t, "Get deleted", val == nil)
+
+		err = db.Close()
+		checkError(t, "Close", err)
+	}
+}
+
+func TestScrubDatastore(t *testing.T) {
+	ds := &MemDS{}
+	err := ds.Open(":memory:")
+	checkError(t, "Open", err)
+
+	keys, err := ds.PutBatch([][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	checkError(t, "PutBatch", err)
+
+	// corrupt b
+	bkey := keys[1]
+	ds.data[string(bkey[2:])] = []byte("x")
+
+	report := new(ScrubReport)
+	corrupt, err := scrubDatastore(context.Background(), ds, report)
+	checkError(t, "scrubDatastore", err)
+	checkBool(t, "scrubDatastore objects", report.Objects == 3)
+	checkBool(t, "scrubDatastore corrupt", len(corrupt) == 1 && len(report.Corrupt) == 1)
+
+	key58 := multihash.Multihash(bkey).B58String()
+	checkBool(t, "scrubDatastore corrupt", report.Corrupt[0] == key58)
+
+	qdir, err := ioutil.TempDir("", "mcnode-test")
+	checkError(t, "TempDir", err)
+	defer os.RemoveAll(qdir)
+
+	count, err := quarantineObjects(ds, qdir, corrupt)
+	checkError(t, "quarantineObjects", err)
+	checkBool(t, "quarantineObjects count", count == 1)
+
+	have, err := ds.Has(bkey)
+	checkError(t, "Has", err)
+	checkBool(t, "Has quarantined", !have)
+
+	data, err := ioutil.ReadFile(path.Join(qdir, key58))
+	checkError(t, "ReadFile", err)
+	checkBool(t, "quarantined data", bytes.Equal(data, []byte("x")))
+}
+
+func TestScrubPublishers(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	keys, err := node.ds.PutBatch([][]byte{[]byte("a"), []byte("b")})
+	checkError(t, "PutBatch", err)
+
+	for _, key := range keys {
+		body := &pb.SimpleStatement{Object: multihash.Multihash(key).B58String()}
+		_, err = node.doPublish("foo", body)
+		checkError(t, "doPublish", err)
+	}
+
+	key58 := multihash.Multihash(keys[1]).B58String()
+	pubs, err := node.scrubPublishers(context.Background(), map[string]Key{key58: keys[1]})
+	checkError(t, "scrubPublishers", err)
+	checkBool(t, "scrubPublishers", len(pubs) == 1 && reflect.DeepEqual(pubs[key58], []string{node.publisher.ID58}))
+
+	// without directories there are no source peers to try
+	sources, err := node.scrubSources(context.Background(), map[string]Key{key58: keys[1]})
+	checkError(t, "scrubSources", err)
+	checkBool(t, "scrubSources", len(sources) == 0)
+}
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte("mediachain "), 100)
+	key := Key(mc.Hash(data))
+
+	val := dsCompress(data)
+	checkBool(t, "dsCompress", len(val) < len(data) && bytes.HasPrefix(val, dsCompressMagic))
+	checkBool(t, "dsDecompress", bytes.Equal(dsDecompress(key, val), data))
+
+	// raw values that look compressed are returned as is
+	raw := append(append([]byte{}, dsCompressMagic...), 0x01)
+	checkBool(t, "dsDecompress raw", bytes.Equal(dsDecompress(Key(mc.Hash(raw)), raw), raw))
+	checkBool(t, "dsDecompress raw", bytes.Equal(dsDecompress(Key(mc.Hash(val)), val), val))
+
+	// values are only compressed when that saves space
+	small := []byte("a")
+	checkBool(t, "dsCompress small", bytes.Equal(dsCompress(small), small))
+
+	xdata, ok := deflateTransfer(data)
+	checkBool(t, "deflateTransfer", ok)
+	xdata, err := inflateTransfer(xdata)
+	checkError(t, "inflateTransfer", err)
+	checkBool(t, "inflateTransfer", bytes.Equal(xdata, data))
+}
+
+func TestMemoryNode(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	body := &pb.SimpleStatement{Object: "QmAAA", Refs: []string{"wki:a"}}
+	sid, err := node.doPublish("foo.a", body)
+	checkError(t, "doPublish", err)
+
+	stmt, err := node.db.Get(sid)
+	checkError(t, "Get", err)
+
+	ok, err := node.verifyStatement(stmt)
+	checkError(t, "verifyStatement", err)
+	checkBool(t, "verifyStatement", ok)
+
+	// import the statement into another node
+	other, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	pkcache := make(map[string]p2p_crypto.PubKey)
+	count, err := other.doImport([]*pb.Statement{stmt}, pkcache)
+	checkError(t, "doImport", err)
+	checkBool(t, "doImport count", count == 1)
+
+	res, err := other.db.Query(parseQuery(t, "SELECT id FROM * WHERE wki = wki:a"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query", len(res) == 1 && res[0] == sid)
+
+	// tampered statements are rejected
+	stmt.Namespace = "foo.b"
+	_, err = other.doImport([]*pb.Statement{stmt}, pkcache)
+	checkBool(t, "doImport tampered", err == BadStatement)
+
+	// configuration changes are not persisted
+	node.info = "test"
+	err = node.saveConfig()
+	checkError(t, "saveConfig", err)
+}
+
+func TestOnlineGC(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	keys, err := node.ds.PutBatch([][]byte{[]byte("a"), []byte("b")})
+	checkError(t, "PutBatch", err)
+
+	body := &pb.SimpleStatement{Object: multihash.Multihash(keys[0]).B58String()}
+	_, err = node.doPublish("foo", body)
+	checkError(t, "doPublish", err)
+
+	ctx := context.Background()
+	count, err := node.doGC(ctx)
+	checkError(t, "doGC", err)
+	checkBool(t, "doGC count", count == 1)
+
+	have, err := node.ds.Has(keys[0])
+	checkError(t, "Has", err)
+	checkBool(t, "Has referenced", have)
+
+	have, err = node.ds.Has(keys[1])
+	checkError(t, "Has", err)
+	checkBool(t, "Has unreferenced", !have)
+
+	// objects written during a gc are not collected
+	_, err = node.gcs.begin()
+	checkError(t, "begin", err)
+
+	_, err = node.gcs.begin()
+	checkBool(t, "begin in progress", err == GCInProgress)
+
+	data := []byte("c")
+	node.gcs.touchData(data)
+	key, err := node.ds.Put(data)
+	checkError(t, "Put", err)
+
+	gc := &GCDB{}
+	err = gc.Open(node.home)
+	checkError(t, "Open", err)
+	defer gc.Close()
+
+	err = gc.Merge(ctx, node.db)
+	checkError(t, "Merge", err)
+
+	count, err = gc.GC(ctx, node.ds, &node.gcs)
+	checkError(t, "GC", err)
+	checkBool(t, "GC count", count == 0)
+	node.gcs.end()
+
+	have, err = node.ds.Has(key)
+	checkError(t, "Has", err)
+	checkBool(t, "Has live", have)
+}
+
+func TestGCPublish(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	keys, err := node.ds.PutBatch([][]byte{[]byte("a"), []byte("b")})
+	checkError(t, "PutBatch", err)
+
+	// a writer in flight holds the gc before it builds the reference set
+	writer := node.gcs.beginWrite()
+
+	ctx := context.Background()
+	type gcResult struct {
+		count int
+		err   error
+	}
+	gcch := make(chan gcResult, 1)
+	go func() {
+		count, err := node.doGC(ctx)
+		gcch <- gcResult{count, err}
+	}()
+
+	for !node.gcs.running() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// statements published during the gc keep their objects
+	body := &pb.SimpleStatement{Object: multihash.Multihash(keys[0]).B58String()}
+	_, err = node.doPublish("foo", body)
+	checkError(t, "doPublish", err)
+
+	bodies := []interface{}{&pb.SimpleStatement{Object: multihash.Multihash(keys[1]).B58String()}}
+	_, err = node.doPublishBatch("foo", bodies)
+	checkError(t, "doPublishBatch", err)
+
+	writer.Done()
+	res := <-gcch
+	checkError(t, "doGC", res.err)
+	checkBool(t, "doGC count", res.count == 0)
+
+	for _, key := range keys {
+		have, err := node.ds.Has(key)
+		checkError(t, "Has", err)
+		checkBool(t, "Has published", have)
+	}
+}
+
+func TestPinGC(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	keys, err := node.ds.PutBatch([][]byte{[]byte("a"), []byte("b")})
+	checkError(t, "PutBatch", err)
+
+	key58 := multihash.Multihash(keys[0]).B58String()
+	pins := map[string]Key{key58: keys[0]}
+	count, err := node.doPin(pins)
+	checkError(t, "doPin", err)
+	checkBool(t, "doPin count", count == 1)
+
+	count, err = node.doPin(pins)
+	checkError(t, "doPin", err)
+	checkBool(t, "doPin repeat count", count == 0)
+
+	ctx := context.Background()
+	ch, err := node.pindb.List(ctx)
+	checkError(t, "List", err)
+	var lst []interface{}
+	for val := range ch {
+		lst = append(lst, val)
+	}
+	checkBool(t, "List", len(lst) == 1 && lst[0] == key58)
+
+	garbage, err := node.doGCDryRun(ctx)
+	checkError(t, "doGCDryRun", err)
+	checkBool(t, "doGCDryRun", len(garbage) == 1 && garbage[0] == multihash.Multihash(keys[1]).B58String())
+
+	count, err = node.doGC(ctx)
+	checkError(t, "doGC", err)
+	checkBool(t, "doGC count", count == 1)
+
+	have, err := node.ds.Has(keys[0])
+	checkError(t, "Has", err)
+	checkBool(t, "Has pinned", have)
+
+	// unpinned objects are collected
+	count, err = node.doUnpin(pins)
+	checkError(t, "doUnpin", err)
+	checkBool(t, "doUnpin count", count == 1)
+
+	count, err = node.doGC(ctx)
+	checkError(t, "doGC", err)
+	checkBool(t, "doGC count", count == 1)
+}
+
+func TestRetract(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	body := &pb.SimpleStatement{Object: "QmAAA", Refs: []string{"wki:a"}}
+	sid, err := node.doPublish("foo.a", body)
+	checkError(t, "doPublish", err)
+
+	stmt, err := node.db.Get(sid)
+	checkError(t, "Get", err)
+
+	rid, err := node.doRetract("foo.a", []string{sid})
+	checkError(t, "doRetract", err)
+
+	_, err = node.db.Get(sid)
+	checkBool(t, "Get retracted", err == UnknownStatement)
+
+	res, err := node.db.Query(parseQuery(t, fmt.Sprintf("SELECT id FROM * WHERE retracts = %s", sid)))
+	checkError(t, "Query", err)
+	checkBool(t, "Query retraction", len(res) == 1 && res[0] == rid)
+
+	// retractions are not indexed as wkis
+	res, err = node.db.Query(parseQuery(t, fmt.Sprintf("SELECT id FROM * WHERE wki = %s", sid)))
+	checkError(t, "Query", err)
+	checkBool(t, "Query retraction wki", len(res) == 0)
+
+	// retractions are not versions of the statements they retract
+	vers, err := node.doLatest(VersionQuery{WKI: sid})
+	checkError(t, "doLatest", err)
+	checkBool(t, "doLatest retraction", len(vers) == 0)
+
+	// retracted statements are skipped on import
+	other, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	retraction, err := node.db.Get(rid)
+	checkError(t, "Get", err)
+
+	pkcache := make(map[string]p2p_crypto.PubKey)
+	count, err := other.doImport([]*pb.Statement{stmt, retraction}, pkcache)
+	checkError(t, "doImport", err)
+	checkBool(t, "doImport count", count == 2)
+
+	_, err = other.db.Get(sid)
+	checkBool(t, "Get retracted", err == UnknownStatement)
+
+	count, err = other.doImport([]*pb.Statement{stmt}, pkcache)
+	checkError(t, "doImport", err)
+	checkBool(t, "doImport retracted count", count == 0)
+
+	// statements by other publishers can't be retracted
+	sid, err = other.doPublish("foo.a", body)
+	checkError(t, "doPublish", err)
+
+	xstmt, err := other.db.Get(sid)
+	checkError(t, "Get", err)
+
+	_, err = node.doImport([]*pb.Statement{xstmt}, pkcache)
+	checkError(t, "doImport", err)
+
+	_, err = node.doRetract("foo.a", []string{sid})
+	checkBool(t, "doRetract foreign", err == BadRetraction)
+
+	// retractions only apply to statements in their namespace
+	sid, err = node.doPublish("foo.b", body)
+	checkError(t, "doPublish", err)
+
+	_, err = node.doRetract("foo.a", []string{sid})
+	checkBool(t, "doRetract namespace", err == BadRetractionNamespace)
+
+	stmt, err = node.db.Get(sid)
+	checkError(t, "Get", err)
+
+	retraction, err = node.makeStatement("foo.a", &pb.RetractStatement{Statements: []string{sid}})
+	checkError(t, "makeStatement", err)
+
+	_, err = other.doImport([]*pb.Statement{stmt, retraction}, pkcache)
+	checkError(t, "doImport", err)
+
+	_, err = other.db.Get(sid)
+	checkError(t, "Get retracted in other namespace", err)
+
+	third, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	_, err = third.doImport([]*pb.Statement{retraction}, pkcache)
+	checkError(t, "doImport", err)
+
+	count, err = third.doImport([]*pb.Statement{stmt}, pkcache)
+	checkError(t, "doImport", err)
+	checkBool(t, "doImport retracted in other namespace", count == 1)
+}
+
+func TestNamespaceStats(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	checkNamespaceStats(t, node)
+}
+
+func checkNamespaceStats(t *testing.T, node *Node) {
+	stmts := []*pb.Statement{
+		makeTestStatement("A:1:1", "foo.a", "A", 100),
+		makeTestStatement("A:2:2", "foo.a", "A", 200),
+		makeTestStatement("A:3:3", "foo.b", "A", 150)}
+
+	err := node.db.PutBatch(stmts)
+	checkError(t, "PutBatch", err)
+
+	nsStats := func() map[string]*pb.NamespaceStats {
+		stats := node.publicNamespaceStats([]string{"foo.a", "foo.b", "foo.c"})
+		checkBool(t, "publicNamespaceStats", len(stats) == 3)
+
+		xstats := make(map[string]*pb.NamespaceStats)
+		for _, nss := range stats {
+			xstats[nss.Namespace] = nss
+		}
+		return xstats
+	}
+
+	xstats := nsStats()
+	checkBool(t, "foo.a stats", xstats["foo.a"].Statements == 2 && xstats["foo.a"].LastUpdate == 200)
+	checkBool(t, "foo.b stats", xstats["foo.b"].Statements == 1 && xstats["foo.b"].LastUpdate == 150)
+	checkBool(t, "foo.c stats", xstats["foo.c"].Statements == 0 && xstats["foo.c"].LastUpdate == 0)
+
+	// stats are cached until the namespace is written by the node
+	err = node.db.PutBatch([]*pb.Statement{makeTestStatement("A:4:4", "foo.b", "A", 300)})
+	checkError(t, "PutBatch", err)
+
+	xstats = nsStats()
+	checkBool(t, "foo.b cached stats", xstats["foo.b"].Statements == 1 && xstats["foo.b"].LastUpdate == 150)
+
+	_, err = node.doPublish("foo.b", &pb.SimpleStatement{Object: "QmAAA"})
+	checkError(t, "doPublish", err)
+
+	xstats = nsStats()
+	checkBool(t, "foo.a stats after publish", xstats["foo.a"].Statements == 2 && xstats["foo.a"].LastUpdate == 200)
+	checkBool(t, "foo.b stats after publish", xstats["foo.b"].Statements == 3 && xstats["foo.b"].LastUpdate > 300)
+
+	// stats computed concurrently with a write are not cached
+	_, tok := node.nsscache.get("foo.c")
+	node.nsscache.touch([]*pb.Statement{makeTestStatement("A:5:5", "foo.c", "A", 400)})
+	node.nsscache.put(&pb.NamespaceStats{"foo.c", 0, 0}, tok)
+	cached, _ := node.nsscache.get("foo.c")
+	checkBool(t, "foo.c stale stats", cached == nil)
+}
+
+func TestManifestFilter(t *testing.T) {
+	now := time.Now()
+	body := &pb.ManifestBody{&pb.ManifestBody_Node{&pb.NodeManifest{"QmPeer", "QmPublisher"}}}
+	mf1 := &pb.Manifest{Entity: "keybase:a", KeyId: "QmKey", Body: body, Timestamp: now.Unix(), Signature: []byte("a")}
+	mf2 := &pb.Manifest{Entity: "keybase:a", KeyId: "QmKey", Body: body, Timestamp: now.Unix(), Signature: []byte("b"), Expires: now.Unix() - 1}
+	mf3 := &pb.Manifest{Entity: "keybase:a", KeyId: "QmKey", Body: body, Timestamp: now.Unix(), Signature: []byte("c"), Expires: now.Unix() + 60}
+	mf4 := &pb.Manifest{Entity: "keybase:a", KeyId: "QmKey", Body: body, Timestamp: now.Unix(), Signature: []byte("d")}
+
+	mfh1, err := mc.HashManifest(mf1)
+	checkError(t, "HashManifest", err)
+	mfh4, err := mc.HashManifest(mf4)
+	checkError(t, "HashManifest", err)
+
+	revs := []*pb.ManifestRevocation{
+		&pb.ManifestRevocation{Entity: "keybase:a", KeyId: "QmKey", Manifest: mfh1},
+		// revocations only apply to manifests of the same entity
+		&pb.ManifestRevocation{Entity: "keybase:b", KeyId: "QmKey", Manifest: mfh4}}
+
+	res := filterManifests([]*pb.Manifest{mf1, mf2, mf3, mf4}, revs, now)
+	checkBool(t, "filterManifests", len(res) == 2 && res[0] == mf3 && res[1] == mf4)
+
+	res = filterManifests([]*pb.Manifest{mf1, mf2, mf3, mf4}, nil, now.Add(time.Minute))
+	checkBool(t, "filterManifests expiry", len(res) == 2 && res[0] == mf1 && res[1] == mf4)
+}
+
+func TestNamespacePolicy(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	other, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	makeClaim := func(ns string, pubs ...string) *pb.Manifest {
+		body := &pb.ManifestBody{&pb.ManifestBody_Namespace{&pb.NamespaceManifest{ns, pubs}}}
+		return &pb.Manifest{Entity: "keybase:a", KeyId: "QmKey", Body: body, Timestamp: time.Now().Unix()}
+	}
+
+	// images.dpla is claimed for the node publisher, images.dpla.x for the other
+	other.nsmfs = []*pb.Manifest{
+		makeClaim("images.dpla", node.publisher.ID58),
+		makeClaim("images.dpla.x", other.publisher.ID58)}
+	other.updateNamespacePolicy()
+
+	body := &pb.SimpleStatement{Object: "QmAAA", Refs: []string{"wki:a"}}
+	stmts := make([]*pb.Statement, 0)
+	for _, ns := range []string{"images.dpla", "images.dpla.foo", "images.dpla.x", "images.dplax", "images"} {
+		sid, err := node.doPublish(ns, body)
+		checkError(t, "doPublish", err)
+
+		stmt, err := node.db.Get(sid)
+		checkError(t, "Get", err)
+
+		stmts = append(stmts, stmt)
+	}
+
+	pkcache := make(map[string]p2p_crypto.PubKey)
+	count, err := other.doImport(stmts, pkcache)
+	checkError(t, "doImport", err)
+	checkBool(t, "doImport count", count == 4)
+
+	_, err = other.db.Get(stmts[2].Id)
+	checkBool(t, "Get unauthorized", err == UnknownStatement)
+
+	// expired claims lapse
+	other.nsmfs[1].Expires = time.Now().Unix() - 1
+	other.updateNamespacePolicy()
+
+	count, err = other.doImport(stmts[2:3], pkcache)
+	checkError(t, "doImport", err)
+	checkBool(t, "doImport expired claim count", count == 1)
+
+	// no claims, no enforcement
+	other.nsmfs = nil
+	other.updateNamespacePolicy()
+	checkBool(t, "namespacePolicy", other.namespacePolicy() == nil)
+}
+
+func TestArchive(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	keys, err := node.ds.PutBatch([][]byte{[]byte("a"), []byte("b")})
+	checkError(t, "PutBatch", err)
+
+	key58 := multihash.Multihash(keys[0]).B58String()
+	dep58 := multihash.Multihash(keys[1]).B58String()
+	body := &pb.SimpleStatement{Object: key58, Deps: []string{dep58}, Refs: []string{"wki:a"}}
+	sid, err := node.doPublish("foo.a", body)
+	checkError(t, "doPublish", err)
+
+	// missing objects are listed in the index
+	missing58 := multihash.Multihash(mc.Hash([]byte("c"))).B58String()
+	body = &pb.SimpleStatement{Object: missing58, Refs: []string{"wki:b"}}
+	_, err = node.doPublish("foo.b", body)
+	checkError(t, "doPublish", err)
+
+	qs := "SELECT * FROM foo.*"
+	ctx := context.Background()
+	ch, err := node.db.QueryStream(ctx, parseQuery(t, qs))
+	checkError(t, "QueryStream", err)
+
+	var buf bytes.Buffer
+	index, err := node.doExport(ctx, qs, ch, &buf)
+	checkError(t, "doExport", err)
+	checkBool(t, "doExport statements", index.Statements == 2)
+	checkBool(t, "doExport objects", len(index.Objects) == 2)
+	checkBool(t, "doExport missing", len(index.Missing) == 1)
+
+	other, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	archive := buf.Bytes()
+	count, ocount, err := other.doImportArchive(ctx, bytes.NewReader(archive))
+	checkError(t, "doImportArchive", err)
+	checkBool(t, "doImportArchive count", count == 2)
+	checkBool(t, "doImportArchive ocount", ocount == 2)
+
+	for _, key := range keys {
+		have, err := other.ds.Has(key)
+		checkError(t, "Has", err)
+		checkBool(t, "Has imported", have)
+	}
+
+	// objects of statements skipped on import are not imported
+	retracted, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	retraction, err := node.makeStatement("foo.a", &pb.RetractStatement{Statements: []string{sid}})
+	checkError(t, "makeStatement", err)
+
+	pkcache := make(map[string]p2p_crypto.PubKey)
+	_, err = retracted.doImport([]*pb.Statement{retraction}, pkcache)
+	checkError(t, "doImport", err)
+
+	count, ocount, err = retracted.doImportArchive(ctx, bytes.NewReader(archive))
+	checkError(t, "doImportArchive", err)
+	checkBool(t, "doImportArchive retracted count", count == 1)
+	checkBool(t, "doImportArchive retracted ocount", ocount == 0)
+
+	for _, key := range keys {
+		have, err := retracted.ds.Has(key)
+		checkError(t, "Has", err)
+		checkBool(t, "Has retracted", !have)
+	}
+
+	// truncated archives are rejected without a partial import
+	empty, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	count, ocount, err = empty.doImportArchive(ctx, bytes.NewReader(archive[:len(archive)-2048]))
+	checkBool(t, "doImportArchive truncated", err != nil && count == 0 && ocount == 0)
+
+	res, err := empty.db.Query(parseQuery(t, "SELECT COUNT(*) FROM *"))
+	checkError(t, "Query", err)
+	checkBool(t, "Query truncated", len(res) == 1 && res[0] == 0)
+
+	for _, key := range keys {
+		have, err := empty.ds.Has(key)
+		checkError(t, "Has", err)
+		checkBool(t, "Has truncated", !have)
+	}
+}
+
+type testDHT struct {
+	mx       sync.Mutex
+	provided map[string]bool
+	active   int
+	peak     int
+}
+
+func (dht *testDHT) Bootstrap() error {
+	return nil
+}
+
+func (dht *testDHT) Lookup(ctx context.Context, pid p2p_peer.ID) (p2p_pstore.PeerInfo, error) {
+	return p2p_pstore.PeerInfo{}, UnknownPeer
+}
+
+func (dht *testDHT) Provide(ctx context.Context, key string) error {
+	dht.mx.Lock()
+	dht.provided[key] = true
+	dht.active += 1
+	if dht.active > dht.peak {
+		dht.peak = dht.active
+	}
+	dht.mx.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	dht.mx.Lock()
+	dht.active -= 1
+	dht.mx.Unlock()
+	return nil
+}
+
+func (dht *testDHT) FindProviders(ctx context.Context, key string) <-chan p2p_pstore.PeerInfo {
+	ch := make(chan p2p_pstore.PeerInfo)
+	close(ch)
+	return ch
+}
+
+func (dht *testDHT) Close() error {
+	return nil
+}
+
+func TestProvide(t *testing.T) {
+	node, err := newMemoryNode(nil)
+	checkError(t, "newMemoryNode", err)
+
+	dht := &testDHT{provided: make(map[string]bool)}
+	node.dht = dht
+
+	data := make([][]byte, 32)
+	for x, _ := range data {
+		data[x] = []byte(fmt.Sprintf("object %d", x))
+	}
+	keys, err := node.ds.PutBatch(data)
+	checkError(t, "PutBatch", err)
+
+	for _, key := range keys {
+		body := &pb.SimpleStatement{Object: multihash.Multihash(key).B58String()}
+		_, err = node.doPublish("foo", body)
+		checkError(t, "doPublish", err)
+	}
+
+	// objects we don't have are not announced
+	_, err = node.doPublish("foo", &pb.SimpleStatement{Object: "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"})
+	checkError(t, "doPublish", err)
+
+	ctx := context.Background()
+	count, err := node.provideObjectsImpl(ctx, &ProvideConfig{Namespaces: []string{"foo"}})
+	checkError(t, "provideObjectsImpl", err)
+	checkBool(t, "provideObjectsImpl count", count == len(keys))
+	checkBool(t, "provideObjectsImpl provided", len(dht.provided) == len(keys))
+	checkBool(t, "provideObjectsImpl concurrent", dht.peak > 1 && dht.peak <= 8)
+
+	for _, key := range keys {
+		key58 := multihash.Multihash(key).B58String()
+		checkBool(t, "provided "+key58, dht.provided[dhtObjectKey(key58)])
+	}
+
+	// config changes wake up the provider loop
+	node.provwake = make(chan struct{}, 1)
+	node.setProvideConfig(&ProvideConfig{Namespaces: []string{"bar"}})
+	node.setProvideConfig(&ProvideConfig{Namespaces: []string{"foo"}})
+	checkBool(t, "setProvideConfig wake", len(node.provwake) == 1)
+	checkBool(t, "provideConfig", node.provideConfig().Namespaces[0] == "foo")
+}
+
+func TestExcludePeerPage(t *testing.T) {
+	peers := make([]DirectoryPeer, 0)
+	for _, pid := range []string{"a", "b", "c", "d", "e"} {
+		peers = append(peers, DirectoryPeer{ID: pid})
+	}
+
+	peerIds := func(peers []DirectoryPeer) []string {
+		ids := make([]string, len(peers))
+		for x, peer := range peers {
+			ids[x] = peer.ID
+		}
+		return ids
+	}
+
+	checkPage := func(where string, self string, offset, limit int, xids []string) {
+		// the listing is fetched up to offset+limit+1
+		lst := peers
+		if limit > 0 && offset+limit+1 < len(lst) {
+			lst = lst[:offset+limit+1]
+		}
+
+		res := excludePeerPage(lst, self, offset, limit)
+		checkBool(t, where, reflect.DeepEqual(peerIds(res), xids))
+	}
+
+	checkPage("first page", "b", 0, 2, []string{"a", "c"})
+	checkPage("page at self", "c", 2, 2, []string{"d", "e"})
+	checkPage("page across self", "c", 1, 2, []string{"b", "d"})
+	checkPage("page after self", "a", 2, 2, []string{"d", "e"})
+	checkPage("page before self", "e", 0, 2, []string{"a", "b"})
+	checkPage("last page", "a", 3, 2, []string{"e"})
+	checkPage("past the end", "a", 4, 2, []string{})
+	checkPage("no limit", "c", 1, 0, []string{"b", "d", "e"})
+	checkPage("self not listed", "x", 1, 2, []string{"b", "c"})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+)
+
+// Object pins
+// Pinned objects are gc roots: they are not collected even if no statement
+// references them. Keys can be pinned before the objects are written.
+type PinDB struct {
+	db AuxDB // object key -> pin timestamp
+}
+
+func (node *Node) openPinDB() error {
+	node.pindb = &PinDB{}
+	return node.pindb.Open(node.home)
+}
+
+// doPin pins objects; the keys are touched first, so that a gc running
+// concurrently doesn't collect them before the pins are committed.
+func (node *Node) doPin(keys map[string]Key) (int, error) {
+	node.gcs.touchKeys(keys)
+	return node.pindb.Pin(pinKeyList(keys))
+}
+
+func (node *Node) doUnpin(keys map[string]Key) (int, error) {
+	return node.pindb.Unpin(pinKeyList(keys))
+}
+
+func pinKeyList(keys map[string]Key) []string {
+	lst := make([]string, 0, len(keys))
+	for key58, _ := range keys {
+		lst = append(lst, key58)
+	}
+	return lst
+}
+
+func (pdb *PinDB) Open(home string) error {
+	db, err := openAuxDB(home, "pin")
+	if err != nil {
+		return err
+	}
+
+	pdb.db = db
+	return nil
+}
+
+// Pin pins a batch of object keys, returning the number of new pins
+func (pdb *PinDB) Pin(keys []string) (int, error) {
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(time.Now().Unix()))
+
+	kvs := make([]AuxEntry, len(keys))
+	for x, key58 := range keys {
+		kvs[x] = AuxEntry{key58, ts}
+	}
+
+	return pdb.db.Put(kvs, false)
+}
+
+// Unpin removes the pins for a batch of object keys, returning the number
+// of pins removed
+func (pdb *PinDB) Unpin(keys []string) (int, error) {
+	return pdb.db.Delete(keys)
+}
+
+func (pdb *PinDB) Has(key58 string) (bool, error) {
+	return pdb.db.Has(key58)
+}
+
+// List streams the pinned keys in key order; errors are reported with a
+// StreamError at the end of the stream.
+func (pdb *PinDB) List(ctx context.Context) (<-chan interface{}, error) {
+	const batch = 1024
+
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+
+		start := ""
+		for {
+			res, err := pdb.db.Scan(start, batch)
+			if err != nil {
+				sendStreamError(ctx, ch, err.Error())
+				return
+			}
+
+			for _, kv := range res {
+				select {
+				case ch <- kv.Key:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if len(res) < batch {
+				return
+			}
+			start = res[len(res)-1].Key
+		}
+	}()
+
+	return ch, nil
+}
+
+func (pdb *PinDB) Close() error {
+	return pdb.db.Close()
+}
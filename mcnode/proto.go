@@ -13,6 +13,7 @@ import (
 	multihash "github.com/multiformats/go-multihash"
 	"log"
 	"runtime"
+	"time"
 )
 
 func (node *Node) pingHandler(s p2p_net.Stream) {
@@ -78,7 +79,7 @@ func (node *Node) manifestHandler(s p2p_net.Stream) {
 		return
 	}
 
-	res.Manifest = node.mfs
+	res.Manifest = node.validManifests()
 
 	w.WriteMsg(&res)
 }
@@ -197,8 +198,12 @@ func (node *Node) dataHandler(s p2p_net.Stream) {
 		return w.WriteMsg(&res)
 	}
 
-	writeData := func(key string, data []byte) error {
-		res.Result = &pb.DataResult_Data{&pb.DataObject{key, data}}
+	writeData := func(key string, data []byte, compress bool) error {
+		compressed := false
+		if compress {
+			data, compressed = deflateTransfer(data)
+		}
+		res.Result = &pb.DataResult_Data{&pb.DataObject{key, data, compressed}}
 		return w.WriteMsg(&res)
 	}
 
@@ -224,7 +229,7 @@ func (node *Node) dataHandler(s p2p_net.Stream) {
 			}
 
 			if data != nil {
-				err = writeData(key58, data)
+				err = writeData(key58, data, req.Compress)
 				if err != nil {
 					return
 				}
@@ -504,8 +509,16 @@ func (node *Node) doMergeStream(ctx context.Context, pid p2p_peer.ID, ch <-chan
 	// publisher key cache
 	pkcache := make(map[string]p2p_crypto.PubKey)
 
-	// background data merges
+	nspolicy := node.namespacePolicy()
+	now := time.Now()
+
+	// background data merges; in lazy mode, objects are only indexed by
+	// source and fetched on first access
+	lazy := node.lazy
 	workers := runtime.NumCPU()
+	if lazy {
+		workers = 0
+	}
 	workch := make(chan map[string]Key, 64*workers) // ~ 3MB/worker
 	resch := make(chan MergeResult, workers)
 	for x := 0; x < workers; x++ {
@@ -516,60 +529,109 @@ func (node *Node) doMergeStream(ctx context.Context, pid p2p_peer.ID, ch <-chan
 	stmts := make([]*pb.Statement, 0, batch)
 	keys := make(map[string]Key)
 
-loop:
-	for val := range ch {
-		switch val := val.(type) {
-		case *pb.Statement:
-			if !node.checkStatement(val) {
-				err = BadStatement
-				break loop
-			}
+	// statement batches are registered as gc writes one at a time, so that
+	// a gc only waits for the batch in flight and not the whole merge
+	mergeBatch := func() error {
+		defer node.gcs.beginWrite().Done()
+		node.gcs.touchStatements(stmts)
+		xcount, err := node.db.MergeBatch(stmts)
+		count += xcount
+		if err == nil {
+			_, err = node.applyRetractions(stmts)
+		}
+		node.nsscache.touch(stmts)
+		return err
+	}
 
-			var verify bool
-			verify, err = node.verifyStatementCacheKeys(val, pkcache)
-			if err != nil {
-				break loop
-			}
+	// verified statements are checked against the retraction db in batches
+	pending := make([]*pb.Statement, 0, batch)
+	mergePending := func() error {
+		// skip statements retracted by their publisher
+		xstmts, err := node.filterRetracted(pending)
+		pending = pending[:0]
+		if err != nil {
+			return err
+		}
 
-			// a verification failure taints the result set; abort the merge
-			if !verify {
-				err = BadStatement
-				break loop
+		for _, stmt := range xstmts {
+			// skip statements by publishers not authorized in the namespace
+			if !nspolicy.allow(stmt, now) {
+				log.Printf("Rejecting statement %s; publisher not authorized in %s", stmt.Id, stmt.Namespace)
+				continue
 			}
 
-			err = node.mergeStatementKeys(val, keys)
+			err = node.mergeStatementKeys(stmt, keys)
 			if err != nil {
-				break loop
+				return err
 			}
 
-			if len(keys) >= batch {
+			switch {
+			case len(keys) < batch:
+
+			case lazy:
+				err = node.lazydb.Put(pid, keys)
+				if err != nil {
+					return err
+				}
+				keys = make(map[string]Key)
+
+			default:
 				select {
 				case workch <- keys:
 					keys = make(map[string]Key)
 
 				case res := <-resch:
 					ocount += res.count
-					err = res.err
 					workers -= 1
-					break loop
+					return res.err
 
 				case <-ctx.Done():
-					err = ctx.Err()
-					break loop
+					return ctx.Err()
 				}
 			}
 
-			stmts = append(stmts, val)
+			stmts = append(stmts, stmt)
 
 			if len(stmts) >= batch {
-				var xcount int
-				xcount, err = node.db.MergeBatch(stmts)
-				count += xcount
+				err = mergeBatch()
 				if err != nil {
-					break loop
+					return err
 				}
 				stmts = stmts[:0]
 			}
+		}
+
+		return nil
+	}
+
+loop:
+	for val := range ch {
+		switch val := val.(type) {
+		case *pb.Statement:
+			if !node.checkStatement(val) {
+				err = BadStatement
+				break loop
+			}
+
+			var verify bool
+			verify, err = node.verifyStatementCacheKeys(val, pkcache)
+			if err != nil {
+				break loop
+			}
+
+			// a verification failure taints the result set; abort the merge
+			if !verify {
+				err = BadStatement
+				break loop
+			}
+
+			pending = append(pending, val)
+			if len(pending) >= batch {
+				err = mergePending()
+				if err != nil {
+					break loop
+				}
+			}
 
 		case StreamError:
 			err = val
@@ -581,7 +643,15 @@ loop:
 		}
 	}
 
-	if len(keys) > 0 && err == nil {
+	if len(pending) > 0 && err == nil {
+		err = mergePending()
+	}
+
+	if len(keys) > 0 && err == nil && lazy {
+		err = node.lazydb.Put(pid, keys)
+	}
+
+	if len(keys) > 0 && err == nil && !lazy {
 		select {
 		case workch <- keys:
 
@@ -596,9 +666,7 @@ loop:
 	}
 
 	if len(stmts) > 0 && err == nil {
-		var xcount int
-		xcount, err = node.db.MergeBatch(stmts)
-		count += xcount
+		err = mergeBatch()
 	}
 
 	close(workch)
@@ -644,6 +712,11 @@ func (node *Node) doMergeDataAsync(ctx context.Context, pid p2p_peer.ID,
 		var xcount int
 		xcount, err = node.doMergeDataImpl(s, keys)
 		count += xcount
+		if err == MissingData {
+			// the source doesn't have some objects; try other providers
+			xcount, err = node.doMergeDataProviders(ctx, pid, keys)
+			count += xcount
+		}
 		if err != nil {
 			break
 		}
@@ -653,6 +726,12 @@ func (node *Node) doMergeDataAsync(ctx context.Context, pid p2p_peer.ID,
 }
 
 func (node *Node) doMergeDataImpl(s p2p_net.Stream, keys map[string]Key) (count int, err error) {
+	// each data batch is a gc write; keys are live for gc before checking
+	// for the objects, so that objects we already have aren't collected
+	// under us
+	defer node.gcs.beginWrite().Done()
+	node.gcs.touchKeys(keys)
+
 	keys58 := make([]string, 0, len(keys))
 	for key58, key := range keys {
 		have, err := node.ds.Has(key)
@@ -676,6 +755,7 @@ func (node *Node) doMergeDataImpl(s p2p_net.Stream, keys map[string]Key) (count
 	w := ggio.NewDelimitedWriter(s)
 
 	req.Keys = keys58
+	req.Compress = node.compress
 	err = w.WriteMsg(&req)
 	if err != nil {
 		return 0, err
@@ -697,8 +777,15 @@ loop:
 				return count, UnexpectedData
 			}
 
-			// verify data hash
 			data := res.Data.Data
+			if res.Data.Compressed {
+				data, err = inflateTransfer(data)
+				if err != nil {
+					return count, err
+				}
+			}
+
+			// verify data hash
 			hash := mc.Hash(data)
 			if !bytes.Equal([]byte(key), []byte(hash)) {
 				return count, BadData
@@ -783,6 +870,10 @@ func (node *Node) mergeStatementKeys(stmt *pb.Statement, keys map[string]Key) er
 		}
 		return nil
 
+	case *pb.StatementBody_Retract:
+		// retractions reference no objects
+		return nil
+
 	default:
 		return BadStatementBody
 	}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	p2p_pstore "github.com/libp2p/go-libp2p-peerstore"
+	mcq "github.com/mediachain/concat/mc/query"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Object provider configuration
+// Namespaces are MCQL namespace selectors (eg "images.*") whose objects are
+// announced in the DHT; an empty list disables object announcements.
+// Sample announces 1 in Sample objects, picked deterministically by key
+// hash so that the same subset is announced by every cycle; 0 or 1 announces
+// everything.
+type ProvideConfig struct {
+	Namespaces []string `json:"namespaces,omitempty"`
+	Sample     int      `json:"sample,omitempty"`
+}
+
+func (cfg *ProvideConfig) enabled() bool {
+	return cfg != nil && len(cfg.Namespaces) > 0
+}
+
+func (cfg *ProvideConfig) sampleKey(key Key) bool {
+	if cfg.Sample <= 1 {
+		return true
+	}
+
+	return int(key[len(key)-1])%cfg.Sample == 0
+}
+
+// DHT keys for object providers
+func dhtObjectKey(key58 string) string {
+	return "/mediachain/data/" + key58
+}
+
+func (node *Node) provideConfig() *ProvideConfig {
+	node.mx.Lock()
+	defer node.mx.Unlock()
+	return node.provide
+}
+
+// setProvideConfig updates the provider configuration and wakes up the
+// provider loop, so that changes take effect without waiting for the next
+// cycle.
+func (node *Node) setProvideConfig(cfg *ProvideConfig) {
+	node.mx.Lock()
+	defer node.mx.Unlock()
+	node.provide = cfg
+	if node.provwake != nil {
+		select {
+		case node.provwake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (node *Node) provideObjects(ctx context.Context) {
+	node.mx.Lock()
+	if node.provwake == nil {
+		node.provwake = make(chan struct{}, 1)
+	}
+	wake := node.provwake
+	node.mx.Unlock()
+
+	for {
+		cfg := node.provideConfig()
+		if cfg.enabled() {
+			count, err := node.provideObjectsImpl(ctx, cfg)
+			if err != nil {
+				log.Printf("Error providing objects in the DHT: %s", err.Error())
+			} else {
+				log.Printf("Provided %d objects in the DHT", count)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-wake:
+			continue
+
+		case <-time.After(1 * time.Hour):
+			continue
+		}
+	}
+}
+
+func (node *Node) provideObjectsImpl(ctx context.Context, cfg *ProvideConfig) (count int, err error) {
+	const batch = 1024
+
+	for _, ns := range cfg.Namespaces {
+		var q *mcq.Query
+		q, err = mcq.ParseQuery(fmt.Sprintf("SELECT * FROM %s", ns))
+		if err != nil {
+			return
+		}
+
+		var ch <-chan interface{}
+		ch, err = node.db.QueryStream(ctx, q)
+		if err != nil {
+			return
+		}
+
+		keys := make(map[string]Key)
+		for val := range ch {
+			switch val := val.(type) {
+			case *pb.Statement:
+				err = node.mergeStatementKeys(val, keys)
+				if err != nil {
+					return
+				}
+
+				if len(keys) >= batch {
+					var xcount int
+					xcount, err = node.provideKeys(ctx, cfg, keys)
+					count += xcount
+					if err != nil {
+						return
+					}
+					keys = make(map[string]Key)
+				}
+
+			case StreamError:
+				return count, val
+
+			default:
+				return count, BadResult
+			}
+		}
+
+		if len(keys) > 0 {
+			var xcount int
+			xcount, err = node.provideKeys(ctx, cfg, keys)
+			count += xcount
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// provideKeys announces the sampled keys present in the datastore through a
+// bounded pool of workers, each announcement with its own timeout.
+func (node *Node) provideKeys(ctx context.Context, cfg *ProvideConfig, keys map[string]Key) (int, error) {
+	const workers = 8
+
+	var xkeys []string
+	for key58, key := range keys {
+		if !cfg.sampleKey(key) {
+			continue
+		}
+
+		// only announce objects we actually have
+		have, err := node.ds.Has(key)
+		if err != nil {
+			return 0, err
+		}
+		if have {
+			xkeys = append(xkeys, key58)
+		}
+	}
+
+	if len(xkeys) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	kch := make(chan string)
+	ech := make(chan error, workers)
+	var count int64
+	var wg sync.WaitGroup
+
+	for x := 0; x < workers && x < len(xkeys); x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key58 := range kch {
+				err := node.provideKey(ctx, key58)
+				if err != nil {
+					ech <- err
+					cancel()
+					return
+				}
+				atomic.AddInt64(&count, 1)
+			}
+		}()
+	}
+
+loop:
+	for _, key58 := range xkeys {
+		select {
+		case kch <- key58:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(kch)
+	wg.Wait()
+
+	select {
+	case err := <-ech:
+		return int(count), err
+	default:
+		return int(count), ctx.Err()
+	}
+}
+
+func (node *Node) provideKey(ctx context.Context, key58 string) error {
+	pctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return node.dht.Provide(pctx, dhtObjectKey(key58))
+}
+
+// doMergeDataProviders fetches objects missing from a merge source from other
+// peers that provide them in the DHT. Keys that are already present in the
+// datastore are skipped; successfully fetched keys are removed from keys.
+func (node *Node) doMergeDataProviders(ctx context.Context, src p2p_peer.ID, keys map[string]Key) (count int, err error) {
+	for key58, key := range keys {
+		var have bool
+		have, err = node.ds.Has(key)
+		if err != nil {
+			return
+		}
+		if have {
+			delete(keys, key58)
+			continue
+		}
+
+		ok := node.doMergeDataProvidersKey(ctx, src, key58, key)
+		if ok {
+			delete(keys, key58)
+			count++
+		}
+
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+	}
+
+	if len(keys) > 0 {
+		return count, MissingData
+	}
+
+	return count, nil
+}
+
+func (node *Node) doMergeDataProvidersKey(ctx context.Context, src p2p_peer.ID, key58 string, key Key) bool {
+	pctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for pinfo := range node.dht.FindProviders(pctx, dhtObjectKey(key58)) {
+		if pinfo.ID == node.ID || pinfo.ID == src {
+			continue
+		}
+
+		node.host.Peerstore().AddAddrs(pinfo.ID, pinfo.Addrs, p2p_pstore.ProviderAddrTTL)
+
+		count, err := node.doRawMerge(pctx, pinfo.ID, map[string]Key{key58: key})
+		if err != nil {
+			log.Printf("Error fetching %s from provider %s: %s", key58, pinfo.ID.Pretty(), err.Error())
+			continue
+		}
+
+		if count > 0 {
+			return true
+		}
+	}
+
+	return false
+}
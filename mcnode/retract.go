@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	mcq "github.com/mediachain/concat/mc/query"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+	"regexp"
+	"strings"
+)
+
+var (
+	BadRetraction          = errors.New("Bad retraction; statement not owned by publisher")
+	BadRetractionNamespace = errors.New("Bad retraction; statement not in namespace")
+)
+
+// Retractions
+// A retraction is a statement with a RetractStatement body, which lists the
+// ids of statements published in error by the same publisher in the same
+// namespace. Retractions only apply to statements by their publisher in
+// their namespace.
+// When a retraction is published or merged, the retracted statements are
+// deleted and recorded as tombstones in the retraction db, so that they are
+// skipped in subsequent merges and imports. The retractions themselves are
+// kept, and are propagated by merges and pushes like any other statement,
+// so that they reach the peers that hold the retracted statements.
+// Retractions are indexed by the statements they retract, so that they can be
+// found with `SELECT * FROM * WHERE retracts = <statement-id>`.
+type RetractDB struct {
+	db AuxDB // id/0x00/publisher -> namespace/0x00/retraction id
+}
+
+func (node *Node) openRetractDB() error {
+	node.retractdb = &RetractDB{}
+	return node.retractdb.Open(node.home)
+}
+
+func (rdb *RetractDB) Open(home string) error {
+	db, err := openAuxDB(home, "retract")
+	if err != nil {
+		return err
+	}
+
+	rdb.db = db
+	return nil
+}
+
+// tombstones are keyed by publisher too, so that retractions by other
+// publishers can't shadow legitimate ones
+func retractKey(id, publisher string) string {
+	return id + "\x00" + publisher
+}
+
+// Put records the tombstones for a retraction
+func (rdb *RetractDB) Put(stmt *pb.Statement, ids []string) error {
+	val := []byte(stmt.Namespace + "\x00" + stmt.Id)
+	kvs := make([]AuxEntry, len(ids))
+	for x, id := range ids {
+		kvs[x] = AuxEntry{retractKey(id, stmt.Publisher), val}
+	}
+
+	_, err := rdb.db.Put(kvs, false)
+	return err
+}
+
+// Retracted returns the statements in a batch that have been retracted by
+// their publisher in their namespace.
+func (rdb *RetractDB) Retracted(stmts []*pb.Statement) (map[string]bool, error) {
+	keys := make([]string, len(stmts))
+	for x, stmt := range stmts {
+		keys[x] = retractKey(stmt.Id, stmt.Publisher)
+	}
+
+	vals, err := rdb.db.GetBatch(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]bool)
+	for x, stmt := range stmts {
+		val, ok := vals[keys[x]]
+		if !ok {
+			continue
+		}
+
+		nsend := bytes.IndexByte(val, 0)
+		if nsend >= 0 && string(val[:nsend]) == stmt.Namespace {
+			res[stmt.Id] = true
+		}
+	}
+
+	return res, nil
+}
+
+func (rdb *RetractDB) Close() error {
+	return rdb.db.Close()
+}
+
+// filterRetracted removes retracted statements from a batch
+func (node *Node) filterRetracted(stmts []*pb.Statement) ([]*pb.Statement, error) {
+	retracted, err := node.retractdb.Retracted(stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(retracted) == 0 {
+		return stmts, nil
+	}
+
+	res := make([]*pb.Statement, 0, len(stmts)-len(retracted))
+	for _, stmt := range stmts {
+		if !retracted[stmt.Id] {
+			res = append(res, stmt)
+		}
+	}
+
+	return res, nil
+}
+
+// doRetract publishes a retraction for statements by the node publisher in
+// the namespace
+func (node *Node) doRetract(ns string, ids []string) (string, error) {
+	for _, id := range ids {
+		stmt, err := node.db.Get(id)
+		switch {
+		case err == UnknownStatement:
+			// already deleted; record the tombstone anyway
+			continue
+
+		case err != nil:
+			return "", err
+
+		case stmt.Publisher != node.publisher.ID58:
+			return "", BadRetraction
+
+		case stmt.Namespace != ns:
+			return "", BadRetractionNamespace
+		}
+	}
+
+	return node.doPublish(ns, &pb.RetractStatement{Statements: ids})
+}
+
+// applyRetractions applies the retractions in a batch of committed statements:
+// the tombstones are recorded and the retracted statements are deleted.
+// Returns the number of statements deleted.
+func (node *Node) applyRetractions(stmts []*pb.Statement) (count int, err error) {
+	defer node.gcs.beginWrite().Done()
+
+	for _, stmt := range stmts {
+		ids := mcq.StatementRetracts(stmt)
+		if len(ids) == 0 {
+			continue
+		}
+
+		err = node.retractdb.Put(stmt, ids)
+		if err != nil {
+			return
+		}
+
+		var xcount int
+		xcount, err = node.deleteRetracted(stmt, ids)
+		count += xcount
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+var retractIdrx *regexp.Regexp
+
+func init() {
+	// the statement ids addressable in MCQL
+	retractIdrx = regexp.MustCompile("^[a-zA-Z0-9:]+$")
+}
+
+func (node *Node) deleteRetracted(retraction *pb.Statement, ids []string) (int, error) {
+	del := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !retractIdrx.Match([]byte(id)) {
+			log.Printf("Ignoring retraction of unaddressable statement %s", id)
+			continue
+		}
+
+		stmt, err := node.db.Get(id)
+		switch {
+		case err == UnknownStatement:
+			continue
+
+		case err != nil:
+			return 0, err
+
+		case stmt.Publisher != retraction.Publisher:
+			continue
+
+		case stmt.Namespace != retraction.Namespace:
+			continue
+		}
+
+		del = append(del, fmt.Sprintf("id = %s", id))
+	}
+
+	const batch = 64
+	count := 0
+	for len(del) > 0 {
+		xdel := del
+		if len(xdel) > batch {
+			xdel = xdel[:batch]
+		}
+		del = del[len(xdel):]
+
+		q, err := mcq.ParseQuery(fmt.Sprintf("DELETE FROM * WHERE %s", strings.Join(xdel, " OR ")))
+		if err != nil {
+			return count, err
+		}
+
+		xcount, err := node.db.Delete(q)
+		count += xcount
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,499 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	ggproto "github.com/gogo/protobuf/proto"
+	mcq "github.com/mediachain/concat/mc/query"
+	pb "github.com/mediachain/concat/proto"
+	"log"
+)
+
+// Statement db schema versioning
+// The schema version is recorded in the Schema table, and Open applies all
+// migrations past the recorded version in order, each in its own
+// transaction. Databases created before versioning have no Schema table;
+// they are at version 1 if they have a Statement table.
+//
+// The Statement table is authoritative; Envelope, Refs, Retracts and the
+// Latest view are derived from the statement data and can be regenerated with Rebuild.
+// Migrations that change the derived tables can request a rebuild instead
+// of transforming the existing rows.
+type SQLMigration struct {
+	apply   func(tx *sql.Tx) error
+	rebuild bool
+}
+
+var sqlMigrations = []SQLMigration{
+	{apply: sqlSchemaV1},                // 1: initial schema
+	{apply: sqlSchemaV2, rebuild: true}, // 2: latest version view
+	{apply: sqlSchemaV3, rebuild: true}, // 3: retraction index
+}
+
+// the current schema of the derived tables, for Rebuild
+const (
+	sqlEnvelopeTable = "CREATE TABLE %s (counter INTEGER PRIMARY KEY AUTOINCREMENT, id VARCHAR(128), namespace VARCHAR, publisher VARCHAR, source VARCHAR, timestamp INTEGER)"
+	sqlRefsTable     = "CREATE TABLE %s (id VARCHAR(128), wki VARCHAR)"
+	sqlRetractsTable = "CREATE TABLE %s (id VARCHAR(128), retracts VARCHAR(128))"
+	sqlLatestTable   = "CREATE TABLE %s (wki VARCHAR, publisher VARCHAR, id VARCHAR(128), timestamp INTEGER, PRIMARY KEY (wki, publisher))"
+)
+
+// sqlLatestUpsert replaces the latest version of a (wki, publisher) unless
+// there is a later version in the view; see versionLess for the ordering.
+// Parameters are wki, publisher, id, timestamp.
+const sqlLatestUpsert = "INSERT OR REPLACE INTO %[1]s SELECT ?1, ?2, ?3, ?4 WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE wki = ?1 AND publisher = ?2 AND (timestamp > ?4 OR (timestamp = ?4 AND (LENGTH(id) > LENGTH(?3) OR (LENGTH(id) = LENGTH(?3) AND id >= ?3)))))"
+
+var sqlDerivedIndexes = []string{
+	"CREATE UNIQUE INDEX EnvelopeId ON Envelope (id)",
+	"CREATE INDEX EnvelopeNS ON Envelope (namespace)",
+	"CREATE INDEX RefsId ON Refs (id)",
+	"CREATE INDEX RefsWki ON Refs (wki)",
+	"CREATE INDEX RetractsId ON Retracts (id)",
+	"CREATE INDEX RetractsStmt ON Retracts (retracts)",
+	"CREATE INDEX LatestId ON Latest (id)"}
+
+func sqlSchemaV1(tx *sql.Tx) error {
+	return sqlExecAll(tx,
+		"CREATE TABLE Statement (id VARCHAR(128) PRIMARY KEY, data VARBINARY)",
+		"CREATE TABLE Envelope (counter INTEGER PRIMARY KEY AUTOINCREMENT, id VARCHAR(128), namespace VARCHAR, publisher VARCHAR, source VARCHAR, timestamp INTEGER)",
+		"CREATE UNIQUE INDEX EnvelopeId ON Envelope (id)",
+		"CREATE INDEX EnvelopeNS ON Envelope (namespace)",
+		"CREATE TABLE Refs (id VARCHAR(128), wki VARCHAR)",
+		"CREATE INDEX RefsId ON Refs (id)",
+		"CREATE INDEX RefsWki ON Refs (wki)")
+}
+
+// the view is populated by the rebuild that follows the migration
+func sqlSchemaV2(tx *sql.Tx) error {
+	return sqlExecAll(tx,
+		fmt.Sprintf(sqlLatestTable, "Latest"),
+		"CREATE INDEX LatestId ON Latest (id)")
+}
+
+// retractions used to be indexed as wkis in Refs; the rebuild that follows
+// the migration moves them to the Retracts table
+func sqlSchemaV3(tx *sql.Tx) error {
+	return sqlExecAll(tx,
+		fmt.Sprintf(sqlRetractsTable, "Retracts"),
+		"CREATE INDEX RetractsId ON Retracts (id)",
+		"CREATE INDEX RetractsStmt ON Retracts (retracts)")
+}
+
+func sqlExecAll(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sdb *SQLDB) schemaVersion() (int, error) {
+	_, err := sdb.db.Exec("CREATE TABLE IF NOT EXISTS Schema (version INTEGER)")
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+	row := sdb.db.QueryRow("SELECT version FROM Schema")
+	err = row.Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		// unversioned db; check for the initial schema
+		var count int
+		row = sdb.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'Statement'")
+		err = row.Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+		return count, nil
+
+	case err != nil:
+		return 0, err
+
+	default:
+		return version, nil
+	}
+}
+
+func (sdb *SQLDB) migrateSchema() error {
+	version, err := sdb.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if version > len(sqlMigrations) {
+		return fmt.Errorf("Statement db schema version %d is newer than supported version %d", version, len(sqlMigrations))
+	}
+
+	rebuild := false
+	for x := version; x < len(sqlMigrations); x++ {
+		mig := sqlMigrations[x]
+
+		tx, err := sdb.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		err = mig.apply(tx)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Statement db migration to version %d failed: %s", x+1, err.Error())
+		}
+
+		err = sqlExecAll(tx, "DELETE FROM Schema", fmt.Sprintf("INSERT INTO Schema VALUES (%d)", x+1))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+
+		if version > 0 {
+			log.Printf("Migrated statement db schema to version %d", x+1)
+			rebuild = rebuild || mig.rebuild
+		}
+	}
+
+	if rebuild {
+		log.Printf("Rebuilding statement db indexes")
+		count, err := sdb.rebuildTables()
+		if err != nil {
+			return err
+		}
+		log.Printf("Rebuilt indexes for %d statements", count)
+	}
+
+	return nil
+}
+
+// Rebuild regenerates the Envelope, Refs, Retracts and Latest tables from the
+// statement data. Envelope counters are preserved for statements that have an envelope;
+// statements without one are appended in the order of their ids.
+// Derived rows without a matching statement are dropped.
+func (sdb *SQLDB) Rebuild() (int, error) {
+	sdb.wlock.Lock()
+	defer sdb.wlock.Unlock()
+
+	return sdb.rebuildTables()
+}
+
+func (sdb *SQLDB) rebuildTables() (count int, err error) {
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	count, err = sdb.rebuildTablesTx(tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (sdb *SQLDB) rebuildTablesTx(tx *sql.Tx) (count int, err error) {
+	// carry over the counter sequence so that counters are never reused
+	var seq int64
+	row := tx.QueryRow("SELECT seq FROM sqlite_sequence WHERE name = 'Envelope'")
+	err = row.Scan(&seq)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	err = sqlExecAll(tx,
+		"DROP TABLE IF EXISTS EnvelopeRebuild",
+		"DROP TABLE IF EXISTS RefsRebuild",
+		"DROP TABLE IF EXISTS RetractsRebuild",
+		"DROP TABLE IF EXISTS LatestRebuild",
+		fmt.Sprintf(sqlEnvelopeTable, "EnvelopeRebuild"),
+		fmt.Sprintf(sqlRefsTable, "RefsRebuild"),
+		fmt.Sprintf(sqlRetractsTable, "RetractsRebuild"),
+		fmt.Sprintf(sqlLatestTable, "LatestRebuild"))
+	if err != nil {
+		return 0, err
+	}
+
+	// statements without an envelope get new counters past the old sequence
+	_, err = tx.Exec("INSERT INTO sqlite_sequence VALUES ('EnvelopeRebuild', ?)", seq)
+	if err != nil {
+		return 0, err
+	}
+
+	insertEnvelope, err := tx.Prepare("INSERT INTO EnvelopeRebuild VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer insertEnvelope.Close()
+
+	insertRefs, err := tx.Prepare("INSERT INTO RefsRebuild VALUES (?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer insertRefs.Close()
+
+	insertRetracts, err := tx.Prepare("INSERT INTO RetractsRebuild VALUES (?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer insertRetracts.Close()
+
+	insertLatest, err := tx.Prepare(fmt.Sprintf(sqlLatestUpsert, "LatestRebuild"))
+	if err != nil {
+		return 0, err
+	}
+	defer insertLatest.Close()
+
+	rows, err := tx.Query("SELECT Statement.id, Statement.data, Envelope.counter FROM Statement LEFT JOIN Envelope ON Statement.id = Envelope.id ORDER BY Envelope.counter IS NULL, Envelope.counter, Statement.id")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var data []byte
+		var counter sql.NullInt64
+		err = rows.Scan(&id, &data, &counter)
+		if err != nil {
+			return 0, err
+		}
+
+		stmt := new(pb.Statement)
+		err = ggproto.Unmarshal(data, stmt)
+		if err != nil {
+			return 0, fmt.Errorf("Error decoding statement %s: %s", id, err.Error())
+		}
+
+		if stmt.Id != id {
+			return 0, fmt.Errorf("Statement id mismatch: %s", id)
+		}
+
+		_, err = insertEnvelope.Exec(counter, stmt.Id, stmt.Namespace, stmt.Publisher, mcq.StatementSource(stmt), stmt.Timestamp)
+		if err != nil {
+			return 0, err
+		}
+
+		for wki, _ := range mcq.StatementRefs(stmt) {
+			_, err = insertRefs.Exec(stmt.Id, wki)
+			if err != nil {
+				return 0, err
+			}
+
+			if isVersion(stmt) {
+				_, err = insertLatest.Exec(wki, stmt.Publisher, stmt.Id, stmt.Timestamp)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		err = sqlInsertRetracts(insertRetracts, stmt)
+		if err != nil {
+			return 0, err
+		}
+
+		count += 1
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	err = sqlExecAll(tx,
+		"DROP TABLE Envelope",
+		"DROP TABLE Refs",
+		"DROP TABLE Retracts",
+		"DROP TABLE Latest",
+		"ALTER TABLE EnvelopeRebuild RENAME TO Envelope",
+		"ALTER TABLE RefsRebuild RENAME TO Refs",
+		"ALTER TABLE RetractsRebuild RENAME TO Retracts",
+		"ALTER TABLE LatestRebuild RENAME TO Latest")
+	if err != nil {
+		return 0, err
+	}
+
+	err = sqlExecAll(tx, sqlDerivedIndexes...)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Check verifies that every statement has an envelope and refs that agree
+// with the statement data, and that there are no derived rows without a
+// statement. Signatures are verified if verify is not nil.
+func (sdb *SQLDB) Check(verify StatementVerifier) (*DBCheckReport, error) {
+	// read within a transaction for a consistent snapshot
+	tx, err := sdb.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := new(DBCheckReport)
+	err = sqlCheckStatements(tx, report, verify)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sqlCheckOrphans(tx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func sqlCheckStatements(tx *sql.Tx, report *DBCheckReport, verify StatementVerifier) error {
+	selectRefs, err := tx.Prepare("SELECT wki FROM Refs WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer selectRefs.Close()
+
+	selectRetracts, err := tx.Prepare("SELECT retracts FROM Retracts WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer selectRetracts.Close()
+
+	rows, err := tx.Query("SELECT Statement.id, Statement.data, Envelope.namespace, Envelope.publisher, Envelope.source, Envelope.timestamp FROM Statement LEFT JOIN Envelope ON Statement.id = Envelope.id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var data []byte
+		var ns, pub, src sql.NullString
+		var ts sql.NullInt64
+		err = rows.Scan(&id, &data, &ns, &pub, &src, &ts)
+		if err != nil {
+			return err
+		}
+
+		report.Statements += 1
+
+		stmt := new(pb.Statement)
+		err = ggproto.Unmarshal(data, stmt)
+		if err != nil || stmt.Id != id {
+			report.BadData = append(report.BadData, id)
+			continue
+		}
+
+		if !ns.Valid ||
+			ns.String != stmt.Namespace ||
+			pub.String != stmt.Publisher ||
+			src.String != mcq.StatementSource(stmt) ||
+			ts.Int64 != stmt.Timestamp {
+			report.BadEnvelope = append(report.BadEnvelope, id)
+		}
+
+		ok, err := sqlCheckRefs(selectRefs, stmt.Id, mcq.StatementRefs(stmt))
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			ok, err = sqlCheckRefs(selectRetracts, stmt.Id, sqlRetractsSet(stmt))
+			if err != nil {
+				return err
+			}
+		}
+
+		if !ok {
+			report.BadRefs = append(report.BadRefs, id)
+		}
+
+		report.checkSignature(stmt, verify)
+	}
+
+	return rows.Err()
+}
+
+// sqlCheckRefs checks that the index rows of a statement are exactly refs
+func sqlCheckRefs(selectRefs *sql.Stmt, id string, refs map[string]bool) (bool, error) {
+	rows, err := selectRefs.Query(id)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	ok := true
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var ref string
+		err = rows.Scan(&ref)
+		if err != nil {
+			return false, err
+		}
+
+		if !refs[ref] || seen[ref] {
+			ok = false
+		}
+		seen[ref] = true
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return false, err
+	}
+
+	return ok && len(seen) == len(refs), nil
+}
+
+func sqlCheckOrphans(tx *sql.Tx, report *DBCheckReport) error {
+	rows, err := tx.Query("SELECT id FROM Envelope WHERE id NOT IN (SELECT id FROM Statement) UNION SELECT id FROM Refs WHERE id NOT IN (SELECT id FROM Statement) UNION SELECT id FROM Retracts WHERE id NOT IN (SELECT id FROM Statement) UNION SELECT id FROM Latest WHERE id NOT IN (SELECT id FROM Statement)")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return err
+		}
+		report.Orphans = append(report.Orphans, id)
+	}
+
+	return rows.Err()
+}
+
+// retractions may list a statement more than once, but it is indexed once
+func sqlRetractsSet(stmt *pb.Statement) map[string]bool {
+	ids := make(map[string]bool)
+	for _, id := range mcq.StatementRetracts(stmt) {
+		ids[id] = true
+	}
+	return ids
+}
+
+func sqlInsertRetracts(insertRetracts *sql.Stmt, stmt *pb.Statement) error {
+	for id, _ := range sqlRetractsSet(stmt) {
+		_, err := insertRetracts.Exec(stmt.Id, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
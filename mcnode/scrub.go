@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	p2p_peer "github.com/libp2p/go-libp2p-peer"
+	mc "github.com/mediachain/concat/mc"
+	mcq "github.com/mediachain/concat/mc/query"
+	pb "github.com/mediachain/concat/proto"
+	multihash "github.com/multiformats/go-multihash"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+)
+
+// Datastore scrubbing
+// A scrub rehashes every object in the datastore and reports the objects
+// whose content doesn't match their key. Corrupt objects can be moved out
+// of the datastore into the quarantine directory (home/quarantine), where
+// they are named by their key, and then refetched from peers.
+type ScrubReport struct {
+	Objects     int      `json:"objects"`
+	Corrupt     []string `json:"corrupt,omitempty"`
+	Quarantined int      `json:"quarantined"`
+	Refetched   []string `json:"refetched,omitempty"`
+}
+
+// scrubDatastore rehashes all objects in the datastore, returning the
+// keys of corrupt objects. Objects that can't be read are also corrupt.
+func scrubDatastore(ctx context.Context, ds Datastore, report *ScrubReport) (map[string]Key, error) {
+	keys, errc, err := ds.IterKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	corrupt := make(map[string]Key)
+	for key := range keys {
+		key58 := multihash.Multihash(key).B58String()
+
+		data, err := ds.Get(key)
+		switch {
+		case err != nil:
+			log.Printf("scrub: Error reading %s: %s", key58, err.Error())
+
+		case data == nil:
+			// deleted since the iterator was created
+			continue
+
+		case bytes.Equal([]byte(key), []byte(mc.Hash(data))):
+			report.Objects += 1
+			continue
+		}
+
+		report.Objects += 1
+		report.Corrupt = append(report.Corrupt, key58)
+		corrupt[key58] = key
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = <-errc
+	if err != nil {
+		return nil, err
+	}
+
+	return corrupt, nil
+}
+
+// quarantineObjects moves objects out of the datastore and into the
+// quarantine directory. If qdir is empty, the objects are just deleted.
+func quarantineObjects(ds Datastore, qdir string, keys map[string]Key) (count int, err error) {
+	if qdir != "" {
+		err = os.MkdirAll(qdir, 0755)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for key58, key := range keys {
+		if qdir != "" {
+			data, err := ds.Get(key)
+			if err != nil {
+				// unreadable; there is nothing to save
+				log.Printf("scrub: Error reading %s: %s", key58, err.Error())
+			}
+
+			if data != nil {
+				err = ioutil.WriteFile(path.Join(qdir, key58), data, 0644)
+				if err != nil {
+					return count, err
+				}
+			}
+		}
+
+		err = ds.Delete(key)
+		if err != nil {
+			return count, err
+		}
+
+		log.Printf("scrub: Quarantined %s", key58)
+		count += 1
+	}
+
+	return count, nil
+}
+
+func quarantineDir(home string) string {
+	if home == ":memory:" {
+		return ""
+	}
+	return path.Join(home, "quarantine")
+}
+
+// doScrub scrubs the node datastore; with refetch, corrupt objects are
+// quarantined and fetched again from the network. Refetching first tries the
+// peers of the publishers of the statements referencing each object, as
+// listed in the directories, and then falls back to the lazy source and DHT
+// providers. Refetching requires the node to be online.
+func (node *Node) doScrub(ctx context.Context, quarantine, refetch bool) (*ScrubReport, error) {
+	if refetch && node.status == StatusOffline {
+		return nil, NodeOffline
+	}
+
+	report := new(ScrubReport)
+	corrupt, err := scrubDatastore(ctx, node.ds, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(corrupt) == 0 || !(quarantine || refetch) {
+		return report, nil
+	}
+
+	count, err := quarantineObjects(node.ds, quarantineDir(node.home), corrupt)
+	report.Quarantined = count
+	if err != nil {
+		return report, err
+	}
+
+	if !refetch {
+		return report, nil
+	}
+
+	sources, err := node.scrubSources(ctx, corrupt)
+	if err != nil {
+		return report, err
+	}
+
+	for key58, key := range corrupt {
+		data, err := node.doScrubFetch(ctx, key58, key, sources[key58])
+		switch {
+		case err != nil:
+			log.Printf("scrub: Error fetching %s: %s", key58, err.Error())
+
+		case data != nil:
+			report.Refetched = append(report.Refetched, key58)
+		}
+	}
+
+	return report, nil
+}
+
+// scrubPublishers scans the statement db for statements referencing the
+// corrupt objects, and returns the publishers and sources of the referencing
+// statements for each object.
+func (node *Node) scrubPublishers(ctx context.Context, corrupt map[string]Key) (map[string][]string, error) {
+	q, err := mcq.ParseQuery("SELECT * FROM *")
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := node.db.QueryStream(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	pubs := make(map[string]map[string]bool)
+	addPublisher := func(key58, pub string) {
+		xpubs, ok := pubs[key58]
+		if !ok {
+			xpubs = make(map[string]bool)
+			pubs[key58] = xpubs
+		}
+		xpubs[pub] = true
+	}
+
+	for val := range ch {
+		switch val := val.(type) {
+		case *pb.Statement:
+			keys := make(map[string]Key)
+			err = node.mergeStatementKeys(val, keys)
+			if err != nil {
+				return nil, err
+			}
+
+			for key58, _ := range keys {
+				if _, ok := corrupt[key58]; ok {
+					addPublisher(key58, mcq.StatementSource(val))
+					addPublisher(key58, val.Publisher)
+				}
+			}
+
+		case StreamError:
+			return nil, val
+
+		default:
+			return nil, BadResult
+		}
+	}
+
+	res := make(map[string][]string)
+	for key58, xpubs := range pubs {
+		lst := make([]string, 0, len(xpubs))
+		for pub, _ := range xpubs {
+			lst = append(lst, pub)
+		}
+		sort.Strings(lst)
+		res[key58] = lst
+	}
+
+	return res, nil
+}
+
+// scrubSources returns the peers of the publishers of the statements
+// referencing the corrupt objects, as listed in the directories.
+func (node *Node) scrubSources(ctx context.Context, corrupt map[string]Key) (map[string][]p2p_peer.ID, error) {
+	pubs, err := node.scrubPublishers(ctx, corrupt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pubs) == 0 || len(node.dir) == 0 {
+		return nil, nil
+	}
+
+	peers, err := node.doDirListVerbose(ctx, "", 0, 0)
+	if err != nil {
+		// not fatal; we can still fetch from providers
+		log.Printf("scrub: Error listing directory peers: %s", err.Error())
+		return nil, nil
+	}
+
+	ppeers := make(map[string][]p2p_peer.ID)
+	for _, peer := range peers {
+		if peer.Publisher == "" {
+			continue
+		}
+
+		pid, err := p2p_peer.IDB58Decode(peer.ID)
+		if err != nil || pid == node.ID {
+			continue
+		}
+
+		ppeers[peer.Publisher] = append(ppeers[peer.Publisher], pid)
+	}
+
+	res := make(map[string][]p2p_peer.ID)
+	for key58, lst := range pubs {
+		for _, pub := range lst {
+			res[key58] = append(res[key58], ppeers[pub]...)
+		}
+	}
+
+	return res, nil
+}
+
+// doScrubFetch refetches a quarantined object from the source peers of the
+// referencing statements, falling back to doFetchData.
+func (node *Node) doScrubFetch(ctx context.Context, key58 string, key Key, pids []p2p_peer.ID) ([]byte, error) {
+	keys := map[string]Key{key58: key}
+	for _, pid := range pids {
+		count, err := node.doRawMerge(ctx, pid, keys)
+		if err != nil {
+			log.Printf("scrub: Error fetching %s from %s: %s", key58, pid.Pretty(), err.Error())
+			continue
+		}
+
+		if count > 0 {
+			return node.ds.Get(key)
+		}
+	}
+
+	return node.doFetchData(ctx, key58, key)
+}
+
+// scrubDatastoreOffline scrubs the datastore of a node that is not running;
+// with quarantine, corrupt objects are moved to the quarantine directory.
+func scrubDatastoreOffline(home string, backend string, quarantine bool) (*ScrubReport, error) {
+	ds, err := newDatastore(backend, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ds.Open(home)
+	if err != nil {
+		return nil, err
+	}
+	defer ds.Close()
+
+	report := new(ScrubReport)
+	corrupt, err := scrubDatastore(context.Background(), ds, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if quarantine && len(corrupt) > 0 {
+		count, err := quarantineObjects(ds, quarantineDir(home), corrupt)
+		report.Quarantined = count
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
@@ -23,9 +23,19 @@ It has these top-level messages:
 	ListNamespacesResponse
 	ListManifestRequest
 	ListManifestResponse
+	SyncRequest
+	SyncRecord
+	ListPeersVerboseRequest
+	ListPeersVerboseResponse
+	PeerListing
+	NamespaceStats
+	NamespaceListing
+	NamespacePeer
 	Manifest
 	ManifestBody
 	NodeManifest
+	ManifestRevocation
+	NamespaceManifest
 	StreamEnd
 	StreamError
 	NodeInfoRequest
@@ -84,8 +94,9 @@ func (*PeerInfo) ProtoMessage()               {}
 func (*PeerInfo) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{0} }
 
 type PublisherInfo struct {
-	Id         string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Namespaces []string `protobuf:"bytes,2,rep,name=namespaces" json:"namespaces,omitempty"`
+	Id         string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Namespaces []string          `protobuf:"bytes,2,rep,name=namespaces" json:"namespaces,omitempty"`
+	Stats      []*NamespaceStats `protobuf:"bytes,3,rep,name=stats" json:"stats,omitempty"`
 }
 
 func (m *PublisherInfo) Reset()                    { *m = PublisherInfo{} }
@@ -93,11 +104,20 @@ func (m *PublisherInfo) String() string            { return proto1.CompactTextSt
 func (*PublisherInfo) ProtoMessage()               {}
 func (*PublisherInfo) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{1} }
 
+func (m *PublisherInfo) GetStats() []*NamespaceStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
 // /mediachain/dir/register
 type RegisterPeer struct {
-	Info      *PeerInfo      `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
-	Publisher *PublisherInfo `protobuf:"bytes,2,opt,name=publisher" json:"publisher,omitempty"`
-	Manifest  []*Manifest    `protobuf:"bytes,3,rep,name=manifest" json:"manifest,omitempty"`
+	Info       *PeerInfo             `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+	Publisher  *PublisherInfo        `protobuf:"bytes,2,opt,name=publisher" json:"publisher,omitempty"`
+	Manifest   []*Manifest           `protobuf:"bytes,3,rep,name=manifest" json:"manifest,omitempty"`
+	NodeInfo   string                `protobuf:"bytes,4,opt,name=nodeInfo,proto3" json:"nodeInfo,omitempty"`
+	Revocation []*ManifestRevocation `protobuf:"bytes,5,rep,name=revocation" json:"revocation,omitempty"`
 }
 
 func (m *RegisterPeer) Reset()                    { *m = RegisterPeer{} }
@@ -126,6 +146,13 @@ func (m *RegisterPeer) GetManifest() []*Manifest {
 	return nil
 }
 
+func (m *RegisterPeer) GetRevocation() []*ManifestRevocation {
+	if m != nil {
+		return m.Revocation
+	}
+	return nil
+}
+
 // /mediachain/dir/lookup
 type LookupPeerRequest struct {
 	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -173,6 +200,7 @@ func (*ListPeersResponse) Descriptor() ([]byte, []int) { return fileDescriptorDi
 
 // /mediachain/dir/listns
 type ListNamespacesRequest struct {
+	Stats bool `protobuf:"varint,1,opt,name=stats,proto3" json:"stats,omitempty"`
 }
 
 func (m *ListNamespacesRequest) Reset()                    { *m = ListNamespacesRequest{} }
@@ -181,7 +209,8 @@ func (*ListNamespacesRequest) ProtoMessage()               {}
 func (*ListNamespacesRequest) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{7} }
 
 type ListNamespacesResponse struct {
-	Namespaces []string `protobuf:"bytes,1,rep,name=namespaces" json:"namespaces,omitempty"`
+	Namespaces []string            `protobuf:"bytes,1,rep,name=namespaces" json:"namespaces,omitempty"`
+	Stats      []*NamespaceListing `protobuf:"bytes,2,rep,name=stats" json:"stats,omitempty"`
 }
 
 func (m *ListNamespacesResponse) Reset()                    { *m = ListNamespacesResponse{} }
@@ -189,6 +218,13 @@ func (m *ListNamespacesResponse) String() string            { return proto1.Comp
 func (*ListNamespacesResponse) ProtoMessage()               {}
 func (*ListNamespacesResponse) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{8} }
 
+func (m *ListNamespacesResponse) GetStats() []*NamespaceListing {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
 // /mediachain/dir/listmf
 type ListManifestRequest struct {
 	Entity string `protobuf:"bytes,1,opt,name=entity,proto3" json:"entity,omitempty"`
@@ -215,6 +251,143 @@ func (m *ListManifestResponse) GetManifest() []*Manifest {
 	return nil
 }
 
+// /mediachain/dir/sync (v1.8)
+// The response is a stream of SyncRecords, terminated by closing the stream.
+type SyncRequest struct {
+}
+
+func (m *SyncRequest) Reset()                    { *m = SyncRequest{} }
+func (m *SyncRequest) String() string            { return proto1.CompactTextString(m) }
+func (*SyncRequest) ProtoMessage()               {}
+func (*SyncRequest) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{11} }
+
+type SyncRecord struct {
+	Registration *RegisterPeer `protobuf:"bytes,1,opt,name=registration" json:"registration,omitempty"`
+	Origin       string        `protobuf:"bytes,2,opt,name=origin,proto3" json:"origin,omitempty"`
+	Expires      int64         `protobuf:"varint,3,opt,name=expires,proto3" json:"expires,omitempty"`
+	LastSeen     int64         `protobuf:"varint,4,opt,name=lastSeen,proto3" json:"lastSeen,omitempty"`
+}
+
+func (m *SyncRecord) Reset()                    { *m = SyncRecord{} }
+func (m *SyncRecord) String() string            { return proto1.CompactTextString(m) }
+func (*SyncRecord) ProtoMessage()               {}
+func (*SyncRecord) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{12} }
+
+func (m *SyncRecord) GetRegistration() *RegisterPeer {
+	if m != nil {
+		return m.Registration
+	}
+	return nil
+}
+
+// /mediachain/dir/listpeers (v1.8)
+// Verbose peer listing, ordered by peer id and paginated with offset and
+// limit; the directory caps the limit to bound the response size.
+type ListPeersVerboseRequest struct {
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Offset    int64  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Limit     int64  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (m *ListPeersVerboseRequest) Reset()                    { *m = ListPeersVerboseRequest{} }
+func (m *ListPeersVerboseRequest) String() string            { return proto1.CompactTextString(m) }
+func (*ListPeersVerboseRequest) ProtoMessage()               {}
+func (*ListPeersVerboseRequest) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{13} }
+
+type ListPeersVerboseResponse struct {
+	Peers []*PeerListing `protobuf:"bytes,1,rep,name=peers" json:"peers,omitempty"`
+	Total int64          `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+}
+
+func (m *ListPeersVerboseResponse) Reset()                    { *m = ListPeersVerboseResponse{} }
+func (m *ListPeersVerboseResponse) String() string            { return proto1.CompactTextString(m) }
+func (*ListPeersVerboseResponse) ProtoMessage()               {}
+func (*ListPeersVerboseResponse) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{14} }
+
+func (m *ListPeersVerboseResponse) GetPeers() []*PeerListing {
+	if m != nil {
+		return m.Peers
+	}
+	return nil
+}
+
+type PeerListing struct {
+	Info      *PeerInfo      `protobuf:"bytes,1,opt,name=info" json:"info,omitempty"`
+	Publisher *PublisherInfo `protobuf:"bytes,2,opt,name=publisher" json:"publisher,omitempty"`
+	NodeInfo  string         `protobuf:"bytes,3,opt,name=nodeInfo,proto3" json:"nodeInfo,omitempty"`
+	Manifest  []*Manifest    `protobuf:"bytes,4,rep,name=manifest" json:"manifest,omitempty"`
+	LastSeen  int64          `protobuf:"varint,5,opt,name=lastSeen,proto3" json:"lastSeen,omitempty"`
+	Latency   int64          `protobuf:"varint,6,opt,name=latency,proto3" json:"latency,omitempty"`
+}
+
+func (m *PeerListing) Reset()                    { *m = PeerListing{} }
+func (m *PeerListing) String() string            { return proto1.CompactTextString(m) }
+func (*PeerListing) ProtoMessage()               {}
+func (*PeerListing) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{15} }
+
+func (m *PeerListing) GetInfo() *PeerInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *PeerListing) GetPublisher() *PublisherInfo {
+	if m != nil {
+		return m.Publisher
+	}
+	return nil
+}
+
+func (m *PeerListing) GetManifest() []*Manifest {
+	if m != nil {
+		return m.Manifest
+	}
+	return nil
+}
+
+// namespace stats (v1.8)
+type NamespaceStats struct {
+	Namespace  string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Statements int64  `protobuf:"varint,2,opt,name=statements,proto3" json:"statements,omitempty"`
+	LastUpdate int64  `protobuf:"varint,3,opt,name=lastUpdate,proto3" json:"lastUpdate,omitempty"`
+}
+
+func (m *NamespaceStats) Reset()                    { *m = NamespaceStats{} }
+func (m *NamespaceStats) String() string            { return proto1.CompactTextString(m) }
+func (*NamespaceStats) ProtoMessage()               {}
+func (*NamespaceStats) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{16} }
+
+type NamespaceListing struct {
+	Namespace  string           `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Statements int64            `protobuf:"varint,2,opt,name=statements,proto3" json:"statements,omitempty"`
+	LastUpdate int64            `protobuf:"varint,3,opt,name=lastUpdate,proto3" json:"lastUpdate,omitempty"`
+	Peers      []*NamespacePeer `protobuf:"bytes,4,rep,name=peers" json:"peers,omitempty"`
+}
+
+func (m *NamespaceListing) Reset()                    { *m = NamespaceListing{} }
+func (m *NamespaceListing) String() string            { return proto1.CompactTextString(m) }
+func (*NamespaceListing) ProtoMessage()               {}
+func (*NamespaceListing) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{17} }
+
+func (m *NamespaceListing) GetPeers() []*NamespacePeer {
+	if m != nil {
+		return m.Peers
+	}
+	return nil
+}
+
+type NamespacePeer struct {
+	Id         string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Statements int64  `protobuf:"varint,2,opt,name=statements,proto3" json:"statements,omitempty"`
+	LastUpdate int64  `protobuf:"varint,3,opt,name=lastUpdate,proto3" json:"lastUpdate,omitempty"`
+}
+
+func (m *NamespacePeer) Reset()                    { *m = NamespacePeer{} }
+func (m *NamespacePeer) String() string            { return proto1.CompactTextString(m) }
+func (*NamespacePeer) ProtoMessage()               {}
+func (*NamespacePeer) Descriptor() ([]byte, []int) { return fileDescriptorDir, []int{18} }
+
 func init() {
 	proto1.RegisterType((*PeerInfo)(nil), "proto.PeerInfo")
 	proto1.RegisterType((*PublisherInfo)(nil), "proto.PublisherInfo")
@@ -227,32 +400,59 @@ func init() {
 	proto1.RegisterType((*ListNamespacesResponse)(nil), "proto.ListNamespacesResponse")
 	proto1.RegisterType((*ListManifestRequest)(nil), "proto.ListManifestRequest")
 	proto1.RegisterType((*ListManifestResponse)(nil), "proto.ListManifestResponse")
+	proto1.RegisterType((*SyncRequest)(nil), "proto.SyncRequest")
+	proto1.RegisterType((*SyncRecord)(nil), "proto.SyncRecord")
+	proto1.RegisterType((*ListPeersVerboseRequest)(nil), "proto.ListPeersVerboseRequest")
+	proto1.RegisterType((*ListPeersVerboseResponse)(nil), "proto.ListPeersVerboseResponse")
+	proto1.RegisterType((*PeerListing)(nil), "proto.PeerListing")
+	proto1.RegisterType((*NamespaceStats)(nil), "proto.NamespaceStats")
+	proto1.RegisterType((*NamespaceListing)(nil), "proto.NamespaceListing")
+	proto1.RegisterType((*NamespacePeer)(nil), "proto.NamespacePeer")
 }
 
 func init() { proto1.RegisterFile("dir.proto", fileDescriptorDir) }
 
 var fileDescriptorDir = []byte{
-	// 339 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x84, 0x51, 0x4f, 0x4f, 0x3a, 0x31,
-	0x10, 0xcd, 0x2e, 0x7f, 0xc2, 0xce, 0x8f, 0x1f, 0x4a, 0x45, 0xdc, 0x18, 0x63, 0x48, 0xb9, 0x60,
-	0x8c, 0xc4, 0xe0, 0x45, 0x4f, 0x1e, 0x3c, 0x99, 0xa0, 0x21, 0xfd, 0x06, 0x8b, 0x3b, 0x68, 0xa3,
-	0x6c, 0x6b, 0x5b, 0x0e, 0x7e, 0x0f, 0x3f, 0xb0, 0x69, 0xe9, 0x2e, 0x05, 0x43, 0x3c, 0xed, 0xf6,
-	0xcd, 0x9b, 0xf7, 0xde, 0xcc, 0x40, 0x92, 0x73, 0x35, 0x96, 0x4a, 0x18, 0x41, 0x1a, 0xee, 0x73,
-	0xda, 0x59, 0x66, 0x05, 0x5f, 0xa0, 0x36, 0x6b, 0x98, 0x8e, 0xa1, 0x35, 0x43, 0x54, 0x8f, 0xc5,
-	0x42, 0x90, 0x0e, 0xc4, 0x3c, 0x4f, 0xa3, 0x41, 0x34, 0x4a, 0x58, 0xcc, 0x73, 0x42, 0xa0, 0x9e,
-	0xe5, 0xb9, 0x4a, 0xe3, 0x41, 0x6d, 0xd4, 0x66, 0xee, 0x9f, 0xde, 0xc3, 0xff, 0xd9, 0x6a, 0xfe,
-	0xc1, 0xf5, 0xdb, 0x9e, 0xa6, 0x73, 0x80, 0x22, 0x5b, 0xa2, 0x96, 0xd9, 0x0b, 0x6a, 0xd7, 0x9a,
-	0xb0, 0x00, 0xa1, 0xdf, 0x11, 0xb4, 0x19, 0xbe, 0x72, 0x6d, 0x50, 0x59, 0x67, 0x32, 0x84, 0x3a,
-	0x2f, 0x16, 0xc2, 0x49, 0xfc, 0x9b, 0x1c, 0xac, 0x73, 0x8d, 0xcb, 0x50, 0xcc, 0x15, 0xc9, 0x04,
-	0x12, 0x59, 0xda, 0xa6, 0xb1, 0x63, 0xf6, 0x4a, 0x66, 0x18, 0x87, 0x6d, 0x68, 0xe4, 0x12, 0x5a,
-	0xe5, 0xb0, 0x69, 0x6d, 0x50, 0x0b, 0xc4, 0x9f, 0x3c, 0xcc, 0x2a, 0x02, 0x1d, 0x42, 0x77, 0x2a,
-	0xc4, 0xfb, 0x4a, 0x5a, 0x63, 0x86, 0x9f, 0x2b, 0xd4, 0x66, 0x77, 0x36, 0x7a, 0x07, 0x24, 0x24,
-	0x69, 0x29, 0x0a, 0x8d, 0x76, 0x00, 0x89, 0xa8, 0xf6, 0x0e, 0x60, 0x8b, 0xf4, 0x1a, 0x0e, 0xa7,
-	0x5c, 0x1b, 0x8b, 0xea, 0x52, 0xfe, 0x0c, 0x92, 0x6a, 0x31, 0xde, 0x65, 0x03, 0xd0, 0x0b, 0xe8,
-	0x06, 0x1d, 0xde, 0xab, 0x07, 0x0d, 0x2b, 0xa7, 0xd3, 0xc8, 0x2d, 0x76, 0xfd, 0xa0, 0x27, 0x70,
-	0x6c, 0xa9, 0xcf, 0xd5, 0x96, 0xbd, 0x03, 0xbd, 0x85, 0xfe, 0x6e, 0xc1, 0x0b, 0x6d, 0x9f, 0x29,
-	0xfa, 0x75, 0xa6, 0x2b, 0x38, 0xb2, 0x9d, 0xd5, 0xa6, 0x7c, 0xe4, 0x3e, 0x34, 0xb1, 0x30, 0xdc,
-	0x7c, 0xf9, 0xbc, 0xfe, 0x45, 0x1f, 0xa0, 0xb7, 0x4d, 0xf7, 0x36, 0xe1, 0x0d, 0xa2, 0x3f, 0x6e,
-	0x30, 0x6f, 0xba, 0xca, 0xcd, 0x4f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x20, 0x7a, 0x27, 0x5b, 0xb6,
-	0x02, 0x00, 0x00,
+	// 648 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xb4, 0x55, 0xc1, 0x4e, 0x1b, 0x3d,
+	0x10, 0xd6, 0x66, 0x13, 0xfe, 0xec, 0x04, 0xf8, 0xc1, 0x04, 0xd8, 0xa2, 0x0a, 0x21, 0x73, 0x49,
+	0x8b, 0x40, 0x55, 0x7a, 0xa8, 0x38, 0xf7, 0x54, 0x89, 0x56, 0xc8, 0xa8, 0x3d, 0xf4, 0x52, 0x2d,
+	0xd9, 0x49, 0x6a, 0x35, 0xb1, 0xb7, 0xb6, 0xa9, 0xca, 0x73, 0xf4, 0xda, 0x37, 0xea, 0xa3, 0xf4,
+	0x25, 0x2a, 0x7b, 0xed, 0xc5, 0x59, 0x8a, 0x40, 0xaa, 0x38, 0x25, 0x33, 0xfe, 0xfc, 0xcd, 0x78,
+	0xbe, 0xcf, 0x5e, 0xc8, 0x4a, 0xae, 0x4e, 0x2a, 0x25, 0x8d, 0x24, 0x3d, 0xf7, 0xb3, 0xb7, 0xbe,
+	0x28, 0x04, 0x9f, 0xa2, 0x36, 0x75, 0x9a, 0x9e, 0x40, 0xff, 0x1c, 0x51, 0xbd, 0x11, 0x53, 0x49,
+	0xd6, 0xa1, 0xc3, 0xcb, 0x3c, 0x39, 0x48, 0x46, 0x19, 0xeb, 0xf0, 0x92, 0x10, 0xe8, 0x16, 0x65,
+	0xa9, 0xf2, 0xce, 0x41, 0x3a, 0x5a, 0x65, 0xee, 0x3f, 0x9d, 0xc3, 0xda, 0xf9, 0xd5, 0xe5, 0x9c,
+	0xeb, 0xcf, 0x77, 0x6c, 0xda, 0x07, 0x10, 0xc5, 0x02, 0x75, 0x55, 0x4c, 0x50, 0xbb, 0xad, 0x19,
+	0x8b, 0x32, 0xe4, 0x08, 0x7a, 0xda, 0x14, 0x46, 0xe7, 0xe9, 0x41, 0x3a, 0x1a, 0x8c, 0xb7, 0xeb,
+	0x3e, 0x4e, 0xde, 0x05, 0xc4, 0x85, 0x5d, 0x64, 0x35, 0x86, 0xfe, 0x4e, 0x60, 0x95, 0xe1, 0x8c,
+	0x6b, 0x83, 0xca, 0xb6, 0x49, 0x0e, 0xa1, 0xcb, 0xc5, 0x54, 0xba, 0x7a, 0x83, 0xf1, 0xff, 0x7e,
+	0x73, 0x38, 0x01, 0x73, 0x8b, 0x64, 0x0c, 0x59, 0x15, 0x7a, 0xcc, 0x3b, 0x0e, 0x39, 0x0c, 0xc8,
+	0xb8, 0x77, 0x76, 0x03, 0x23, 0x47, 0xd0, 0x0f, 0x93, 0xf1, 0x9d, 0x05, 0xf2, 0xb7, 0x3e, 0xcd,
+	0x1a, 0x00, 0xd9, 0x83, 0xbe, 0x90, 0x25, 0x5a, 0x8e, 0xbc, 0xeb, 0x4e, 0xde, 0xc4, 0xe4, 0x14,
+	0x40, 0xe1, 0x37, 0x39, 0x29, 0x0c, 0x97, 0x22, 0xef, 0x39, 0xaa, 0x27, 0x6d, 0xaa, 0x06, 0xc0,
+	0x22, 0x30, 0x3d, 0x84, 0xcd, 0x33, 0x29, 0xbf, 0x5c, 0x55, 0xf6, 0x3c, 0x0c, 0xbf, 0x5e, 0xd9,
+	0x5a, 0xad, 0xf9, 0xd2, 0x53, 0x20, 0x31, 0x48, 0x57, 0x52, 0x68, 0xb4, 0x73, 0xa9, 0x10, 0xd5,
+	0x9d, 0x73, 0xb1, 0x8b, 0xf4, 0x05, 0x6c, 0x9c, 0x71, 0x6d, 0x6c, 0x56, 0x07, 0xfa, 0xa7, 0x90,
+	0x35, 0xe2, 0xf8, 0x2a, 0x37, 0x09, 0xfa, 0x0c, 0x36, 0xa3, 0x1d, 0xbe, 0xd6, 0x10, 0x7a, 0x96,
+	0x4e, 0xe7, 0x89, 0x13, 0xb7, 0x0e, 0xe8, 0x31, 0x6c, 0x5b, 0x68, 0xa3, 0x63, 0x53, 0x61, 0x18,
+	0x04, 0xb7, 0xec, 0xfd, 0xa0, 0xec, 0x0c, 0x76, 0xda, 0x70, 0x4f, 0xbf, 0x6c, 0xa0, 0xe4, 0x96,
+	0x81, 0x8e, 0x03, 0x5f, 0xc7, 0xcd, 0x76, 0xb7, 0x6d, 0x20, 0x4b, 0xcb, 0xc5, 0x2c, 0x14, 0x3a,
+	0x86, 0x2d, 0x9b, 0xb9, 0x19, 0x7d, 0xdd, 0xd5, 0x0e, 0xac, 0xa0, 0x30, 0xdc, 0x5c, 0xfb, 0x43,
+	0xfb, 0x88, 0xbe, 0x86, 0xe1, 0x32, 0xdc, 0x77, 0x15, 0xfb, 0x23, 0xb9, 0xc7, 0x1f, 0x74, 0x0d,
+	0x06, 0x17, 0xd7, 0x62, 0xe2, 0x6b, 0xd1, 0x1f, 0x09, 0x40, 0x1d, 0x4f, 0xa4, 0x2a, 0xc9, 0x2b,
+	0x58, 0x55, 0xce, 0xd3, 0xaa, 0xf6, 0x48, 0xad, 0xd9, 0x96, 0xa7, 0x8b, 0xed, 0xce, 0x96, 0x80,
+	0xb6, 0x67, 0xa9, 0xf8, 0x8c, 0x0b, 0x67, 0xea, 0x8c, 0xf9, 0x88, 0xe4, 0xf0, 0x1f, 0x7e, 0xaf,
+	0xb8, 0x42, 0x7b, 0xa9, 0x92, 0x51, 0xca, 0x42, 0x68, 0x8d, 0x3a, 0x2f, 0xb4, 0xb9, 0x40, 0x14,
+	0xce, 0xa8, 0x29, 0x6b, 0x62, 0x8a, 0xb0, 0xdb, 0x68, 0xfb, 0x01, 0xd5, 0xa5, 0xd4, 0xf8, 0x20,
+	0x53, 0xb8, 0x36, 0xa6, 0x53, 0x8d, 0xc6, 0xb5, 0x91, 0x32, 0x1f, 0x59, 0xa1, 0xe7, 0x7c, 0xc1,
+	0x8d, 0x6f, 0xa2, 0x0e, 0xe8, 0x47, 0xc8, 0x6f, 0x97, 0xf1, 0x43, 0x1d, 0xc5, 0x4e, 0x1a, 0x8c,
+	0x49, 0x64, 0xdb, 0x46, 0x45, 0x07, 0xb0, 0xdc, 0x46, 0x9a, 0x62, 0xee, 0x4b, 0xd6, 0x01, 0xfd,
+	0x95, 0xc0, 0x20, 0x02, 0x3f, 0xde, 0xeb, 0x10, 0x5f, 0xf8, 0xb4, 0x75, 0xe1, 0x63, 0x67, 0x74,
+	0x1f, 0xf0, 0x72, 0x34, 0x82, 0xf4, 0x5a, 0x82, 0x08, 0x58, 0x5f, 0x7e, 0x05, 0xef, 0xd1, 0x61,
+	0x1f, 0xc0, 0x5a, 0x1c, 0x17, 0x28, 0xdc, 0x6d, 0xb0, 0x6c, 0x51, 0xc6, 0xae, 0x5b, 0xee, 0xf7,
+	0x55, 0x59, 0x18, 0xf4, 0xa2, 0x44, 0x19, 0xfa, 0x33, 0x81, 0x8d, 0xf6, 0xad, 0x79, 0xdc, 0x92,
+	0xe4, 0x79, 0x10, 0xbc, 0x1e, 0xd4, 0xb0, 0x7d, 0x77, 0x9d, 0xe9, 0xfd, 0x83, 0xf2, 0x09, 0xd6,
+	0x96, 0xf2, 0x7f, 0xfb, 0xd2, 0xfc, 0x4b, 0x33, 0x97, 0x2b, 0xae, 0xf8, 0xcb, 0x3f, 0x03, 0x00,
+	0x41, 0xf4, 0x06, 0x81, 0x25, 0x07, 0x00, 0x00,
 }
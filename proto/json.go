@@ -46,3 +46,11 @@ func (m *Manifest) UnmarshalJSON(data []byte) error {
 func (m *ManifestBody) UnmarshalJSON(data []byte) error {
 	return unmarshalJSON(data, m)
 }
+
+func (m *ManifestRevocation) MarshalJSON() ([]byte, error) {
+	return marshalJSON(m)
+}
+
+func (m *ManifestRevocation) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, m)
+}
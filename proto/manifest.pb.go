@@ -19,6 +19,7 @@ type Manifest struct {
 	Body      *ManifestBody `protobuf:"bytes,3,opt,name=body" json:"body,omitempty"`
 	Timestamp int64         `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Signature []byte        `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+	Expires   int64         `protobuf:"varint,6,opt,name=expires,proto3" json:"expires,omitempty"`
 }
 
 func (m *Manifest) Reset()                    { *m = Manifest{} }
@@ -36,6 +37,7 @@ func (m *Manifest) GetBody() *ManifestBody {
 type ManifestBody struct {
 	// Types that are valid to be assigned to Body:
 	//	*ManifestBody_Node
+	//	*ManifestBody_Namespace
 	Body isManifestBody_Body `protobuf_oneof:"body"`
 }
 
@@ -52,7 +54,12 @@ type ManifestBody_Node struct {
 	Node *NodeManifest `protobuf:"bytes,1,opt,name=node,oneof"`
 }
 
-func (*ManifestBody_Node) isManifestBody_Body() {}
+type ManifestBody_Namespace struct {
+	Namespace *NamespaceManifest `protobuf:"bytes,2,opt,name=namespace,oneof"`
+}
+
+func (*ManifestBody_Node) isManifestBody_Body()      {}
+func (*ManifestBody_Namespace) isManifestBody_Body() {}
 
 func (m *ManifestBody) GetBody() isManifestBody_Body {
 	if m != nil {
@@ -68,10 +75,18 @@ func (m *ManifestBody) GetNode() *NodeManifest {
 	return nil
 }
 
+func (m *ManifestBody) GetNamespace() *NamespaceManifest {
+	if x, ok := m.GetBody().(*ManifestBody_Namespace); ok {
+		return x.Namespace
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*ManifestBody) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer) error, func(msg proto1.Message, tag, wire int, b *proto1.Buffer) (bool, error), func(msg proto1.Message) (n int), []interface{}) {
 	return _ManifestBody_OneofMarshaler, _ManifestBody_OneofUnmarshaler, _ManifestBody_OneofSizer, []interface{}{
 		(*ManifestBody_Node)(nil),
+		(*ManifestBody_Namespace)(nil),
 	}
 }
 
@@ -84,6 +99,11 @@ func _ManifestBody_OneofMarshaler(msg proto1.Message, b *proto1.Buffer) error {
 		if err := b.EncodeMessage(x.Node); err != nil {
 			return err
 		}
+	case *ManifestBody_Namespace:
+		_ = b.EncodeVarint(2<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.Namespace); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("ManifestBody.Body has unexpected type %T", x)
@@ -102,6 +122,14 @@ func _ManifestBody_OneofUnmarshaler(msg proto1.Message, tag, wire int, b *proto1
 		err := b.DecodeMessage(msg)
 		m.Body = &ManifestBody_Node{msg}
 		return true, err
+	case 2: // body.namespace
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(NamespaceManifest)
+		err := b.DecodeMessage(msg)
+		m.Body = &ManifestBody_Namespace{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -116,6 +144,11 @@ func _ManifestBody_OneofSizer(msg proto1.Message) (n int) {
 		n += proto1.SizeVarint(1<<3 | proto1.WireBytes)
 		n += proto1.SizeVarint(uint64(s))
 		n += s
+	case *ManifestBody_Namespace:
+		s := proto1.Size(x.Namespace)
+		n += proto1.SizeVarint(2<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -133,29 +166,65 @@ func (m *NodeManifest) String() string            { return proto1.CompactTextStr
 func (*NodeManifest) ProtoMessage()               {}
 func (*NodeManifest) Descriptor() ([]byte, []int) { return fileDescriptorManifest, []int{2} }
 
+// Manifest revocation (v1.8)
+// Revokes a manifest identified by its hash; must be signed by the entity
+// that signed the manifest.
+type ManifestRevocation struct {
+	Entity    string `protobuf:"bytes,1,opt,name=entity,proto3" json:"entity,omitempty"`
+	KeyId     string `protobuf:"bytes,2,opt,name=keyId,proto3" json:"keyId,omitempty"`
+	Manifest  []byte `protobuf:"bytes,3,opt,name=manifest,proto3" json:"manifest,omitempty"`
+	Timestamp int64  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Signature []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *ManifestRevocation) Reset()                    { *m = ManifestRevocation{} }
+func (m *ManifestRevocation) String() string            { return proto1.CompactTextString(m) }
+func (*ManifestRevocation) ProtoMessage()               {}
+func (*ManifestRevocation) Descriptor() ([]byte, []int) { return fileDescriptorManifest, []int{3} }
+
+// Namespace ownership manifest (v1.8)
+// Claims a namespace prefix for the signing entity and lists the publishers
+// authorized to publish in it.
+type NamespaceManifest struct {
+	Namespace  string   `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Publishers []string `protobuf:"bytes,2,rep,name=publishers" json:"publishers,omitempty"`
+}
+
+func (m *NamespaceManifest) Reset()                    { *m = NamespaceManifest{} }
+func (m *NamespaceManifest) String() string            { return proto1.CompactTextString(m) }
+func (*NamespaceManifest) ProtoMessage()               {}
+func (*NamespaceManifest) Descriptor() ([]byte, []int) { return fileDescriptorManifest, []int{4} }
+
 func init() {
 	proto1.RegisterType((*Manifest)(nil), "proto.Manifest")
 	proto1.RegisterType((*ManifestBody)(nil), "proto.ManifestBody")
 	proto1.RegisterType((*NodeManifest)(nil), "proto.NodeManifest")
+	proto1.RegisterType((*ManifestRevocation)(nil), "proto.ManifestRevocation")
+	proto1.RegisterType((*NamespaceManifest)(nil), "proto.NamespaceManifest")
 }
 
 func init() { proto1.RegisterFile("manifest.proto", fileDescriptorManifest) }
 
 var fileDescriptorManifest = []byte{
-	// 225 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x4c, 0x8f, 0x31, 0x4e, 0xc4, 0x30,
-	0x10, 0x45, 0x31, 0x9b, 0x44, 0xec, 0x10, 0x51, 0x0c, 0x08, 0xb9, 0xa0, 0x88, 0xd2, 0x10, 0x9a,
-	0x2d, 0xe0, 0x02, 0xb0, 0x15, 0x14, 0x50, 0xf8, 0x06, 0x89, 0x3c, 0x80, 0x05, 0xb1, 0x2d, 0xdb,
-	0x5b, 0xf8, 0x30, 0xdc, 0x15, 0xad, 0xe3, 0x95, 0x53, 0xd9, 0xf3, 0xfe, 0xd7, 0x9f, 0x3f, 0x70,
-	0x35, 0x8f, 0x5a, 0x7d, 0x92, 0x0f, 0x3b, 0xeb, 0x4c, 0x30, 0x58, 0xa7, 0xa7, 0xff, 0x63, 0x70,
-	0xf1, 0x9e, 0x15, 0xbc, 0x85, 0x86, 0x74, 0x50, 0x21, 0x72, 0xd6, 0xb1, 0x61, 0x2b, 0xf2, 0x84,
-	0x37, 0x50, 0xff, 0x50, 0x7c, 0x93, 0xfc, 0x3c, 0xe1, 0x65, 0xc0, 0x7b, 0xa8, 0x26, 0x23, 0x23,
-	0xdf, 0x74, 0x6c, 0xb8, 0x7c, 0xbc, 0x5e, 0x72, 0x77, 0xa7, 0xb0, 0xbd, 0x91, 0x51, 0x24, 0x03,
-	0xde, 0xc1, 0x36, 0xa8, 0x99, 0x7c, 0x18, 0x67, 0xcb, 0xab, 0x8e, 0x0d, 0x1b, 0x51, 0xc0, 0x51,
-	0xf5, 0xea, 0x4b, 0x8f, 0xe1, 0xe0, 0x88, 0xd7, 0x1d, 0x1b, 0x5a, 0x51, 0x40, 0xff, 0x02, 0xed,
-	0x3a, 0x11, 0x1f, 0xa0, 0xd2, 0x46, 0x52, 0x2a, 0x58, 0x96, 0x7e, 0x18, 0x49, 0x27, 0xdb, 0xeb,
-	0x99, 0x48, 0x96, 0x7d, 0xb3, 0xf4, 0xeb, 0x9f, 0xa1, 0x5d, 0xeb, 0x88, 0x50, 0x59, 0x22, 0x97,
-	0x6f, 0x4c, 0xff, 0x63, 0x09, 0x7b, 0x98, 0x7e, 0x95, 0xff, 0x26, 0x97, 0xaf, 0x2c, 0x60, 0x6a,
-	0xd2, 0x96, 0xa7, 0xff, 0x00, 0x00, 0x00, 0xff, 0xff, 0xcd, 0x51, 0xb9, 0xd1, 0x44, 0x01, 0x00,
-	0x00,
+	// 316 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x51, 0xc1, 0x4e, 0xeb, 0x30,
+	0x10, 0x7c, 0x6e, 0xd3, 0xbc, 0x66, 0x1b, 0x21, 0xb1, 0x20, 0x64, 0x21, 0x84, 0xa2, 0x5c, 0x08,
+	0x97, 0x1e, 0xca, 0x85, 0x23, 0xea, 0x09, 0x0e, 0x20, 0xe1, 0x3f, 0x48, 0x9b, 0x05, 0x2c, 0x48,
+	0x1c, 0xc5, 0x2e, 0x22, 0xe2, 0x4f, 0xf8, 0x11, 0x7e, 0x0f, 0xd5, 0x89, 0xeb, 0x20, 0x4e, 0x88,
+	0x53, 0x32, 0xe3, 0x99, 0xb5, 0x67, 0x16, 0xf6, 0xca, 0xbc, 0x92, 0x0f, 0xa4, 0xcd, 0xbc, 0x6e,
+	0x94, 0x51, 0x38, 0xb1, 0x9f, 0xf4, 0x93, 0xc1, 0xf4, 0xb6, 0x3f, 0xc1, 0x23, 0x08, 0xa9, 0x32,
+	0xd2, 0xb4, 0x9c, 0x25, 0x2c, 0x8b, 0x44, 0x8f, 0xf0, 0x10, 0x26, 0xcf, 0xd4, 0xde, 0x14, 0x7c,
+	0x64, 0xe9, 0x0e, 0xe0, 0x19, 0x04, 0x2b, 0x55, 0xb4, 0x7c, 0x9c, 0xb0, 0x6c, 0xb6, 0x38, 0xe8,
+	0xe6, 0xce, 0xdd, 0xb0, 0xa5, 0x2a, 0x5a, 0x61, 0x05, 0x78, 0x02, 0x91, 0x91, 0x25, 0x69, 0x93,
+	0x97, 0x35, 0x0f, 0x12, 0x96, 0x8d, 0x85, 0x27, 0xb6, 0xa7, 0x5a, 0x3e, 0x56, 0xb9, 0xd9, 0x34,
+	0xc4, 0x27, 0x09, 0xcb, 0x62, 0xe1, 0x09, 0xe4, 0xf0, 0x9f, 0xde, 0x6a, 0xd9, 0x90, 0xe6, 0xa1,
+	0x75, 0x3a, 0x98, 0xbe, 0x43, 0x3c, 0xbc, 0x0b, 0xcf, 0x21, 0xa8, 0x54, 0x41, 0x9c, 0x7d, 0x7b,
+	0xce, 0x9d, 0x2a, 0xc8, 0xc9, 0xae, 0xff, 0x09, 0x2b, 0xc1, 0x4b, 0x88, 0xaa, 0xbc, 0x24, 0x5d,
+	0xe7, 0x6b, 0xb2, 0x99, 0x66, 0x0b, 0xee, 0xf4, 0x8e, 0x1f, 0x98, 0xbc, 0x78, 0x19, 0x76, 0x99,
+	0xd3, 0x2b, 0x88, 0x87, 0x93, 0x11, 0x21, 0xa8, 0x89, 0x9a, 0xbe, 0x37, 0xfb, 0xbf, 0x0d, 0x56,
+	0x6f, 0x56, 0x2f, 0x52, 0x3f, 0x51, 0xd3, 0x37, 0xe7, 0x89, 0xf4, 0x83, 0x01, 0x3a, 0xbb, 0xa0,
+	0x57, 0xb5, 0xce, 0x8d, 0x54, 0xd5, 0x2f, 0x57, 0x70, 0x0c, 0x53, 0xb7, 0x56, 0xbb, 0x86, 0x58,
+	0xec, 0xf0, 0x5f, 0x5a, 0x4f, 0xef, 0x61, 0xff, 0x47, 0x11, 0x5b, 0x8b, 0x6f, 0xad, 0x7b, 0x9d,
+	0x27, 0xf0, 0x14, 0x60, 0x17, 0x4e, 0xf3, 0x51, 0x32, 0xce, 0x22, 0x31, 0x60, 0x56, 0xa1, 0xed,
+	0xf7, 0xe2, 0x6b, 0x00, 0xb0, 0x5e, 0x3d, 0xe7, 0x88, 0x02, 0x00, 0x00,
 }
@@ -586,7 +586,8 @@ func (m *KeyValuePair) GetValue() *SimpleValue {
 
 // /mediachain/node/data
 type DataRequest struct {
-	Keys []string `protobuf:"bytes,1,rep,name=keys" json:"keys,omitempty"`
+	Keys     []string `protobuf:"bytes,1,rep,name=keys" json:"keys,omitempty"`
+	Compress bool     `protobuf:"varint,2,opt,name=compress,proto3" json:"compress,omitempty"`
 }
 
 func (m *DataRequest) Reset()                    { *m = DataRequest{} }
@@ -747,8 +748,9 @@ func _DataResult_OneofSizer(msg proto1.Message) (n int) {
 }
 
 type DataObject struct {
-	Key  string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Key        string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Data       []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Compressed bool   `protobuf:"varint,3,opt,name=compressed,proto3" json:"compressed,omitempty"`
 }
 
 func (m *DataObject) Reset()                    { *m = DataObject{} }
@@ -1065,47 +1067,48 @@ func init() {
 func init() { proto1.RegisterFile("node.proto", fileDescriptorNode) }
 
 var fileDescriptorNode = []byte{
-	// 664 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xb4, 0x94, 0x4d, 0x6f, 0xd3, 0x4c,
-	0x10, 0xc7, 0xe3, 0xda, 0x4d, 0x93, 0x71, 0x9e, 0xa7, 0xe9, 0x3e, 0x95, 0x9e, 0xa8, 0xaa, 0x50,
-	0x59, 0x2a, 0x5a, 0xf1, 0x52, 0xa4, 0x70, 0xe1, 0x86, 0x08, 0x54, 0x0a, 0x20, 0x20, 0x18, 0x09,
-	0x89, 0xa3, 0x13, 0x4f, 0x5b, 0xd3, 0x78, 0xd7, 0xf5, 0xda, 0x48, 0x39, 0xf0, 0x21, 0xb8, 0xf2,
-	0x39, 0xf8, 0x80, 0x68, 0xf6, 0xc5, 0x76, 0x2a, 0x2a, 0xb8, 0x70, 0xf2, 0xce, 0xcc, 0x6f, 0x67,
-	0xfe, 0x33, 0x3b, 0x09, 0x80, 0x90, 0x09, 0x9e, 0xe4, 0x85, 0x2c, 0x25, 0xdb, 0xd4, 0x9f, 0x3d,
-	0x50, 0x65, 0x56, 0x1a, 0xd7, 0xde, 0xbf, 0x59, 0x2c, 0xd2, 0x33, 0x54, 0xd6, 0xe6, 0x21, 0xf4,
-	0x3f, 0x94, 0x05, 0xc6, 0xd9, 0xa9, 0x48, 0xf8, 0x1d, 0x08, 0xad, 0x51, 0x14, 0xb2, 0x60, 0xbb,
-	0xb0, 0x89, 0x74, 0x18, 0x79, 0x07, 0xde, 0x71, 0x3f, 0x32, 0x06, 0xdf, 0x81, 0xed, 0xb7, 0x32,
-	0xc1, 0x97, 0xe2, 0x4c, 0x46, 0x78, 0x55, 0xa1, 0x2a, 0xf9, 0x0c, 0x7a, 0xce, 0xc5, 0x18, 0x04,
-	0x39, 0xa2, 0xbb, 0xa3, 0xcf, 0x6c, 0x1f, 0xfa, 0x79, 0x35, 0x5f, 0xa6, 0xea, 0x02, 0x8b, 0xd1,
-	0x86, 0x0e, 0x34, 0x0e, 0xba, 0x91, 0x8a, 0x33, 0x39, 0xf2, 0xcd, 0x0d, 0x3a, 0x53, 0x91, 0x37,
-	0x56, 0xa8, 0x2b, 0xf2, 0x14, 0x86, 0x8d, 0x4b, 0xe5, 0x52, 0x28, 0x64, 0xf7, 0xa1, 0xe7, 0xfa,
-	0x19, 0x79, 0x07, 0xfe, 0x71, 0x38, 0xde, 0x36, 0x7d, 0x9d, 0xd4, 0x68, 0x0d, 0xf0, 0x2e, 0x04,
-	0xb3, 0x54, 0x9c, 0xeb, 0xaf, 0x14, 0xe7, 0xfc, 0x10, 0x06, 0xef, 0x2b, 0x2c, 0x56, 0xb6, 0x00,
-	0xb5, 0x7b, 0x45, 0xb6, 0x6b, 0x57, 0x1b, 0xfc, 0xbb, 0x07, 0xa1, 0xc5, 0x54, 0xb5, 0x2c, 0xd9,
-	0x23, 0xd8, 0xfc, 0x12, 0x2f, 0x2b, 0xd4, 0x54, 0x38, 0xfe, 0xdf, 0xd6, 0x6b, 0x21, 0x1f, 0x29,
-	0x3c, 0xed, 0x44, 0x86, 0x63, 0x87, 0xe0, 0xa3, 0x48, 0x74, 0xdb, 0xe1, 0x78, 0x68, 0xf1, 0x7a,
-	0xe6, 0xd3, 0x4e, 0x44, 0x61, 0x76, 0xcf, 0xcd, 0xda, 0xd7, 0x1c, 0x5b, 0xe7, 0x28, 0x42, 0x19,
-	0x35, 0x32, 0xe9, 0x41, 0xb7, 0xd0, 0x95, 0xf8, 0x57, 0x18, 0x5e, 0x2f, 0xcc, 0x1e, 0x40, 0x57,
-	0xa5, 0x59, 0xbe, 0x74, 0x0a, 0xeb, 0x54, 0xda, 0xe9, 0xc4, 0x59, 0x86, 0x8d, 0xa1, 0xb7, 0x90,
-	0x59, 0x2e, 0xab, 0x5a, 0xe2, 0xae, 0xe5, 0x9f, 0x5b, 0xb7, 0xbb, 0x51, 0x73, 0x93, 0x2d, 0x3b,
-	0x02, 0xfe, 0xc3, 0x83, 0xb0, 0x95, 0x96, 0xed, 0x43, 0x2f, 0x15, 0x46, 0x86, 0x2e, 0xee, 0xd3,
-	0x35, 0xe7, 0x61, 0x1c, 0x42, 0x55, 0x16, 0xa9, 0x38, 0x37, 0x80, 0xde, 0x83, 0x69, 0x27, 0x6a,
-	0x3b, 0xd9, 0x5d, 0x08, 0x68, 0x59, 0xed, 0x14, 0x9a, 0x69, 0xc5, 0x25, 0x66, 0x28, 0xca, 0x69,
-	0x27, 0xd2, 0x71, 0x92, 0x4d, 0xdf, 0x89, 0x4c, 0x56, 0xa3, 0x60, 0x4d, 0x76, 0xcd, 0x52, 0x8c,
-	0xea, 0x3b, 0xae, 0x91, 0xfd, 0x04, 0xfe, 0x59, 0x6b, 0x8e, 0x1d, 0x41, 0x30, 0xa7, 0x4c, 0x66,
-	0x85, 0xfe, 0xb3, 0x99, 0x5e, 0xe3, 0x4a, 0x87, 0x67, 0x71, 0x5a, 0x44, 0x1a, 0xe0, 0xaf, 0x60,
-	0xd0, 0xf6, 0xb2, 0x21, 0xf8, 0x97, 0xe8, 0x16, 0x86, 0x8e, 0xec, 0xd8, 0xad, 0xc7, 0xc6, 0x4d,
-	0xc3, 0xb7, 0x7b, 0xc1, 0x6f, 0x43, 0xf8, 0x22, 0x2e, 0x63, 0xb7, 0x7d, 0x0c, 0x82, 0x4b, 0x5c,
-	0x29, 0xad, 0xa1, 0x1f, 0xe9, 0x33, 0xff, 0xe6, 0x01, 0x18, 0x46, 0xaf, 0xde, 0x11, 0x04, 0x49,
-	0x5c, 0xc6, 0xf6, 0x5d, 0x77, 0x6c, 0x6a, 0x02, 0xde, 0xcd, 0x3f, 0xe3, 0x42, 0x4f, 0x87, 0x80,
-	0xbf, 0xba, 0x72, 0x63, 0x23, 0xc9, 0x54, 0xfc, 0xc5, 0x00, 0x98, 0x15, 0x49, 0xc5, 0x07, 0x46,
-	0x0f, 0x7f, 0x08, 0xe1, 0xac, 0x52, 0x17, 0xae, 0xd5, 0x5b, 0x00, 0x22, 0xce, 0x50, 0xe5, 0xf1,
-	0x02, 0x5d, 0xc3, 0x2d, 0x0f, 0xcf, 0x61, 0x60, 0xf0, 0xfa, 0x57, 0xde, 0x8d, 0x17, 0x0b, 0xcc,
-	0xcb, 0x6b, 0x9d, 0x13, 0xf4, 0x4c, 0x07, 0x68, 0xa1, 0x0d, 0x42, 0x70, 0x81, 0xa4, 0xcd, 0xb6,
-	0xdf, 0x86, 0x23, 0xb4, 0x63, 0xb2, 0xc8, 0xa4, 0x6b, 0x1e, 0x9e, 0x0f, 0x00, 0x9a, 0x64, 0x9c,
-	0x1b, 0xcb, 0xd0, 0x37, 0xfc, 0x0b, 0xce, 0xa1, 0x4f, 0xcc, 0xfa, 0xd6, 0x7a, 0xbf, 0xd9, 0xda,
-	0x3f, 0x7a, 0x97, 0x66, 0x4f, 0x3f, 0xc1, 0x16, 0xd5, 0x38, 0x15, 0x09, 0x8d, 0x4c, 0xb9, 0x74,
-	0xca, 0xfc, 0xb6, 0xa2, 0x96, 0x87, 0x8d, 0x60, 0x4b, 0xea, 0x17, 0x51, 0x3a, 0xbb, 0x1f, 0x39,
-	0xb3, 0x91, 0xef, 0xb7, 0xe4, 0xcf, 0xbb, 0xba, 0xf6, 0xe3, 0x9f, 0x01, 0x00, 0x00, 0xff, 0xff,
-	0xfa, 0xff, 0x2a, 0xed, 0x2e, 0x06, 0x00, 0x00,
+	// 680 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xb4, 0x54, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0x4d, 0xea, 0x34, 0x4d, 0xc6, 0x81, 0xa6, 0x4b, 0x25, 0xac, 0xaa, 0xaa, 0xaa, 0xa5, 0xa2,
+	0x15, 0x1f, 0x45, 0x0a, 0x17, 0x2e, 0x08, 0x11, 0xa8, 0x14, 0x40, 0x40, 0x58, 0x24, 0x24, 0x8e,
+	0x4e, 0x3c, 0x6d, 0x4d, 0xe3, 0xb5, 0xeb, 0xb5, 0x91, 0x72, 0xe0, 0x47, 0x70, 0xe5, 0x77, 0xf0,
+	0x03, 0xd1, 0xec, 0x87, 0xed, 0x14, 0x2a, 0xb8, 0x70, 0xf2, 0xce, 0xcc, 0xdb, 0x79, 0x6f, 0x66,
+	0x5f, 0x02, 0x20, 0xd3, 0x08, 0x8f, 0xb3, 0x3c, 0x2d, 0x52, 0xb6, 0xae, 0x3f, 0x3b, 0xa0, 0x8a,
+	0xa4, 0x30, 0xa9, 0x9d, 0x9b, 0x49, 0x28, 0xe3, 0x53, 0x54, 0x36, 0xe6, 0x3e, 0xf4, 0x3f, 0x16,
+	0x39, 0x86, 0xc9, 0x89, 0x8c, 0xf8, 0x1d, 0xf0, 0x6d, 0x90, 0xe7, 0x69, 0xce, 0xb6, 0x61, 0x1d,
+	0xe9, 0x10, 0xb4, 0xf7, 0xdb, 0x47, 0x7d, 0x61, 0x02, 0xbe, 0x05, 0x9b, 0xef, 0xd2, 0x08, 0x5f,
+	0xc9, 0xd3, 0x54, 0xe0, 0x65, 0x89, 0xaa, 0xe0, 0x53, 0xe8, 0xb9, 0x14, 0x63, 0xd0, 0xc9, 0x10,
+	0xdd, 0x1d, 0x7d, 0x66, 0xbb, 0xd0, 0xcf, 0xca, 0xd9, 0x22, 0x56, 0xe7, 0x98, 0x07, 0x6b, 0xba,
+	0x50, 0x27, 0xe8, 0x46, 0x2c, 0x4f, 0xd3, 0xc0, 0x33, 0x37, 0xe8, 0x4c, 0x24, 0x6f, 0xad, 0x50,
+	0x47, 0xf2, 0x0c, 0x86, 0x75, 0x4a, 0x65, 0xa9, 0x54, 0xc8, 0xee, 0x43, 0xcf, 0xcd, 0x13, 0xb4,
+	0xf7, 0xbd, 0x23, 0x7f, 0xb4, 0x69, 0xe6, 0x3a, 0xae, 0xa0, 0x15, 0x80, 0x77, 0xa1, 0x33, 0x8d,
+	0xe5, 0x99, 0xfe, 0xa6, 0xf2, 0x8c, 0x1f, 0xc0, 0xe0, 0x43, 0x89, 0xf9, 0xd2, 0x12, 0xd0, 0xb8,
+	0x97, 0x14, 0xbb, 0x71, 0x75, 0xc0, 0x7f, 0xb4, 0xc1, 0xb7, 0x30, 0x55, 0x2e, 0x0a, 0xf6, 0x08,
+	0xd6, 0xbf, 0x86, 0x8b, 0x12, 0x35, 0xca, 0x1f, 0xdd, 0xb6, 0x7c, 0x0d, 0xc8, 0x27, 0x2a, 0x4f,
+	0x5a, 0xc2, 0xe0, 0xd8, 0x01, 0x78, 0x28, 0x23, 0x3d, 0xb6, 0x3f, 0x1a, 0x5a, 0x78, 0xb5, 0xf3,
+	0x49, 0x4b, 0x50, 0x99, 0xdd, 0x73, 0xbb, 0xf6, 0x34, 0x8e, 0xad, 0xe2, 0xa8, 0x42, 0x1d, 0x35,
+	0x64, 0xdc, 0x83, 0x6e, 0xae, 0x99, 0xf8, 0x37, 0x18, 0x5e, 0x25, 0x66, 0x0f, 0xa0, 0xab, 0xe2,
+	0x24, 0x5b, 0x38, 0x85, 0x55, 0x2b, 0x9d, 0x74, 0xe2, 0x2c, 0x86, 0x8d, 0xa0, 0x37, 0x4f, 0x93,
+	0x2c, 0x2d, 0x2b, 0x89, 0xdb, 0x16, 0xff, 0xc2, 0xa6, 0xdd, 0x8d, 0x0a, 0x37, 0xde, 0xb0, 0x2b,
+	0xe0, 0x3f, 0xdb, 0xe0, 0x37, 0xda, 0xb2, 0x5d, 0xe8, 0xc5, 0xd2, 0xc8, 0xd0, 0xe4, 0x1e, 0x5d,
+	0x73, 0x19, 0xc6, 0xc1, 0x57, 0x45, 0x1e, 0xcb, 0x33, 0x03, 0xd0, 0x3e, 0x98, 0xb4, 0x44, 0x33,
+	0xc9, 0xee, 0x42, 0x87, 0xcc, 0x1a, 0x78, 0x57, 0xb6, 0x15, 0x16, 0x98, 0xa0, 0x2c, 0x26, 0x2d,
+	0xa1, 0xeb, 0x24, 0x9b, 0xbe, 0xe3, 0x34, 0x5a, 0x06, 0x9d, 0x15, 0xd9, 0x15, 0x96, 0x6a, 0xc4,
+	0xef, 0x70, 0xb5, 0xec, 0x27, 0x70, 0x63, 0x65, 0x38, 0x76, 0x08, 0x9d, 0x19, 0x75, 0x32, 0x16,
+	0xba, 0x65, 0x3b, 0xbd, 0xc1, 0xa5, 0x2e, 0x4f, 0xc3, 0x38, 0x17, 0x1a, 0xc0, 0x5f, 0xc3, 0xa0,
+	0x99, 0x65, 0x43, 0xf0, 0x2e, 0xd0, 0x19, 0x86, 0x8e, 0xec, 0xc8, 0xd9, 0x63, 0xed, 0xba, 0xe5,
+	0x5b, 0x5f, 0xf0, 0xa7, 0xe0, 0xbf, 0x0c, 0x8b, 0xd0, 0xb9, 0x8f, 0x41, 0xe7, 0x02, 0x97, 0x4a,
+	0x6b, 0xe8, 0x0b, 0x7d, 0x66, 0x3b, 0xe6, 0x71, 0x72, 0x54, 0x4a, 0xf7, 0xeb, 0x89, 0x2a, 0xe6,
+	0xdf, 0xdb, 0x00, 0xe6, 0xbe, 0xb6, 0xe5, 0x21, 0x74, 0xa2, 0xb0, 0x08, 0xed, 0x9b, 0x6f, 0x59,
+	0x5a, 0x02, 0xbc, 0x9f, 0x7d, 0xc1, 0xb9, 0xde, 0x1c, 0x01, 0xfe, 0xab, 0x1d, 0x05, 0x40, 0xcd,
+	0xf8, 0x87, 0xe5, 0x30, 0x2b, 0x92, 0xc8, 0x07, 0x56, 0xcf, 0x1e, 0x80, 0x9b, 0x09, 0x23, 0x4d,
+	0xd7, 0x13, 0x8d, 0x0c, 0x7f, 0x08, 0xfe, 0xb4, 0x54, 0xe7, 0x6e, 0x4d, 0x7b, 0x00, 0x32, 0x4c,
+	0x50, 0x65, 0xe1, 0x1c, 0xdd, 0xb2, 0x1a, 0x19, 0x9e, 0xc1, 0xc0, 0xc0, 0xab, 0x7f, 0x88, 0x6e,
+	0x38, 0x9f, 0x63, 0x56, 0x5c, 0xd9, 0x0c, 0x81, 0x9e, 0xeb, 0x02, 0xfd, 0x18, 0x0c, 0x84, 0xc0,
+	0x39, 0x92, 0xf6, 0x60, 0xed, 0x37, 0xb0, 0x40, 0xbb, 0x46, 0x0b, 0x19, 0x77, 0x8d, 0x69, 0xf8,
+	0x00, 0xa0, 0x6e, 0xc6, 0x39, 0x40, 0x8d, 0xbe, 0xe6, 0x1f, 0x74, 0x06, 0x7d, 0xc2, 0xac, 0x3a,
+	0xbe, 0xfd, 0x17, 0xc7, 0xff, 0xd3, 0xbb, 0xd5, 0x1e, 0xff, 0x0c, 0x1b, 0xc4, 0x71, 0x22, 0x23,
+	0x5a, 0x99, 0x72, 0xed, 0x94, 0xf9, 0x5d, 0x8a, 0x46, 0x86, 0x05, 0xb0, 0x91, 0xea, 0x17, 0x33,
+	0x26, 0xf3, 0x84, 0x0b, 0x6b, 0xf9, 0x5e, 0x43, 0xfe, 0xac, 0xab, 0xb9, 0x1f, 0xff, 0x1a, 0x00,
+	0xfe, 0xcb, 0x53, 0x2d, 0x6a, 0x06, 0x00, 0x00,
 }
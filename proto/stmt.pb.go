@@ -40,6 +40,7 @@ type StatementBody struct {
 	//	*StatementBody_Compound
 	//	*StatementBody_Envelope
 	//	*StatementBody_Archive
+	//	*StatementBody_Retract
 	Body isStatementBody_Body `protobuf_oneof:"body"`
 }
 
@@ -64,11 +65,15 @@ type StatementBody_Envelope struct {
 type StatementBody_Archive struct {
 	Archive *ArchiveStatement `protobuf:"bytes,4,opt,name=archive,oneof"`
 }
+type StatementBody_Retract struct {
+	Retract *RetractStatement `protobuf:"bytes,5,opt,name=retract,oneof"`
+}
 
 func (*StatementBody_Simple) isStatementBody_Body()   {}
 func (*StatementBody_Compound) isStatementBody_Body() {}
 func (*StatementBody_Envelope) isStatementBody_Body() {}
 func (*StatementBody_Archive) isStatementBody_Body()  {}
+func (*StatementBody_Retract) isStatementBody_Body()  {}
 
 func (m *StatementBody) GetBody() isStatementBody_Body {
 	if m != nil {
@@ -105,6 +110,13 @@ func (m *StatementBody) GetArchive() *ArchiveStatement {
 	return nil
 }
 
+func (m *StatementBody) GetRetract() *RetractStatement {
+	if x, ok := m.GetBody().(*StatementBody_Retract); ok {
+		return x.Retract
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*StatementBody) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer) error, func(msg proto1.Message, tag, wire int, b *proto1.Buffer) (bool, error), func(msg proto1.Message) (n int), []interface{}) {
 	return _StatementBody_OneofMarshaler, _StatementBody_OneofUnmarshaler, _StatementBody_OneofSizer, []interface{}{
@@ -112,6 +124,7 @@ func (*StatementBody) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffe
 		(*StatementBody_Compound)(nil),
 		(*StatementBody_Envelope)(nil),
 		(*StatementBody_Archive)(nil),
+		(*StatementBody_Retract)(nil),
 	}
 }
 
@@ -139,6 +152,11 @@ func _StatementBody_OneofMarshaler(msg proto1.Message, b *proto1.Buffer) error {
 		if err := b.EncodeMessage(x.Archive); err != nil {
 			return err
 		}
+	case *StatementBody_Retract:
+		_ = b.EncodeVarint(5<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.Retract); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("StatementBody.Body has unexpected type %T", x)
@@ -181,6 +199,14 @@ func _StatementBody_OneofUnmarshaler(msg proto1.Message, tag, wire int, b *proto
 		err := b.DecodeMessage(msg)
 		m.Body = &StatementBody_Archive{msg}
 		return true, err
+	case 5: // body.retract
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(RetractStatement)
+		err := b.DecodeMessage(msg)
+		m.Body = &StatementBody_Retract{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -210,6 +236,11 @@ func _StatementBody_OneofSizer(msg proto1.Message) (n int) {
 		n += proto1.SizeVarint(4<<3 | proto1.WireBytes)
 		n += proto1.SizeVarint(uint64(s))
 		n += s
+	case *StatementBody_Retract:
+		s := proto1.Size(x.Retract)
+		n += proto1.SizeVarint(5<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -269,6 +300,15 @@ func (m *ArchiveStatement) String() string            { return proto1.CompactTex
 func (*ArchiveStatement) ProtoMessage()               {}
 func (*ArchiveStatement) Descriptor() ([]byte, []int) { return fileDescriptorStmt, []int{5} }
 
+type RetractStatement struct {
+	Statements []string `protobuf:"bytes,1,rep,name=statements" json:"statements,omitempty"`
+}
+
+func (m *RetractStatement) Reset()                    { *m = RetractStatement{} }
+func (m *RetractStatement) String() string            { return proto1.CompactTextString(m) }
+func (*RetractStatement) ProtoMessage()               {}
+func (*RetractStatement) Descriptor() ([]byte, []int) { return fileDescriptorStmt, []int{6} }
+
 func init() {
 	proto1.RegisterType((*Statement)(nil), "proto.Statement")
 	proto1.RegisterType((*StatementBody)(nil), "proto.StatementBody")
@@ -276,33 +316,36 @@ func init() {
 	proto1.RegisterType((*CompoundStatement)(nil), "proto.CompoundStatement")
 	proto1.RegisterType((*EnvelopeStatement)(nil), "proto.EnvelopeStatement")
 	proto1.RegisterType((*ArchiveStatement)(nil), "proto.ArchiveStatement")
+	proto1.RegisterType((*RetractStatement)(nil), "proto.RetractStatement")
 }
 
 func init() { proto1.RegisterFile("stmt.proto", fileDescriptorStmt) }
 
 var fileDescriptorStmt = []byte{
-	// 363 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x92, 0xcd, 0x6a, 0xdb, 0x40,
-	0x10, 0xc7, 0xbb, 0x92, 0xac, 0x56, 0xe3, 0x7e, 0xd8, 0x4b, 0x71, 0xf7, 0xd0, 0x83, 0x10, 0x3d,
-	0x88, 0x1e, 0x4c, 0xb1, 0xa1, 0x90, 0x53, 0x88, 0x43, 0x20, 0x67, 0xe5, 0x09, 0xf4, 0x31, 0xb1,
-	0x15, 0x2c, 0xed, 0xa2, 0x5d, 0x1b, 0xfc, 0x70, 0x79, 0xa5, 0x3c, 0x43, 0xd8, 0x0f, 0x4b, 0xfe,
-	0x20, 0x27, 0x8d, 0x7e, 0xf3, 0xff, 0xcf, 0x30, 0x33, 0x0b, 0x20, 0x55, 0xa3, 0xe6, 0xa2, 0xe3,
-	0x8a, 0xd3, 0x91, 0xf9, 0x24, 0xaf, 0x04, 0xa2, 0x27, 0x95, 0x2b, 0x6c, 0xb0, 0x55, 0xf4, 0x3b,
-	0x78, 0x75, 0xc5, 0x48, 0x4c, 0xd2, 0x28, 0xf3, 0xea, 0x8a, 0xfe, 0x86, 0x48, 0xec, 0x8a, 0x6d,
-	0x2d, 0x37, 0xd8, 0x31, 0xcf, 0xe0, 0x01, 0xe8, 0x6c, 0x9b, 0x37, 0x28, 0x45, 0x5e, 0x22, 0xf3,
-	0x6d, 0xb6, 0x07, 0x34, 0x85, 0xa0, 0xe0, 0xd5, 0x81, 0x05, 0x31, 0x49, 0xc7, 0x8b, 0x9f, 0xb6,
-	0xed, 0xbc, 0xef, 0xb5, 0xe2, 0xd5, 0x21, 0x33, 0x0a, 0x5d, 0x47, 0xd5, 0x0d, 0x4a, 0x95, 0x37,
-	0x82, 0x8d, 0x62, 0x92, 0xfa, 0xd9, 0x00, 0x74, 0x56, 0xd6, 0xeb, 0x36, 0x57, 0xbb, 0x0e, 0x59,
-	0x18, 0x93, 0xf4, 0x6b, 0x36, 0x80, 0xe4, 0x8d, 0xc0, 0xb7, 0xb3, 0x9a, 0xf4, 0x1f, 0x84, 0xb2,
-	0x6e, 0xc4, 0x16, 0xcd, 0x1c, 0xe3, 0xc5, 0xec, 0xd8, 0xd9, 0xc0, 0x5e, 0xfb, 0xf8, 0x29, 0x73,
-	0x3a, 0xfa, 0x1f, 0xbe, 0x94, 0xbc, 0x11, 0x7c, 0xd7, 0x56, 0x66, 0xc8, 0xf1, 0x82, 0x39, 0xcf,
-	0xbd, 0xc3, 0xa7, 0xae, 0x5e, 0xab, 0x7d, 0xd8, 0xee, 0x71, 0xcb, 0x85, 0x1d, 0x7f, 0xf0, 0x3d,
-	0x38, 0x7c, 0xe6, 0x3b, 0x6a, 0xe9, 0x12, 0x3e, 0xe7, 0x5d, 0xb9, 0xa9, 0xf7, 0xe8, 0x96, 0xf3,
-	0xcb, 0xd9, 0xee, 0x2c, 0x3d, 0x75, 0x1d, 0x95, 0xab, 0xd0, 0xae, 0x33, 0x41, 0xf8, 0x71, 0x31,
-	0x09, 0x9d, 0x41, 0xc8, 0x8b, 0x17, 0x2c, 0x95, 0xbb, 0x9c, 0xfb, 0xa3, 0x14, 0x82, 0x0e, 0x9f,
-	0x25, 0xf3, 0x62, 0x3f, 0x8d, 0x32, 0x13, 0x6b, 0xa6, 0xf2, 0xb5, 0x64, 0xbe, 0x65, 0x3a, 0xd6,
-	0xac, 0x42, 0x21, 0x59, 0x60, 0x99, 0x8e, 0x93, 0x5b, 0x98, 0x5e, 0x0d, 0x4f, 0xff, 0xba, 0x93,
-	0x92, 0xd8, 0xff, 0x78, 0xb1, 0xf6, 0xa8, 0xc9, 0x0d, 0x4c, 0xaf, 0xb6, 0x40, 0xff, 0x9c, 0x15,
-	0x98, 0x5c, 0xbe, 0x09, 0x67, 0xa5, 0x30, 0xb9, 0xdc, 0x44, 0x11, 0x1a, 0xe9, 0xf2, 0x3d, 0x00,
-	0x00, 0xff, 0xff, 0xc4, 0xae, 0x82, 0x5f, 0xc3, 0x02, 0x00, 0x00,
+	// 392 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x74, 0x93, 0xdb, 0xaa, 0xd3, 0x40,
+	0x14, 0x86, 0xcd, 0xa1, 0xd1, 0xac, 0x7a, 0xe8, 0x1e, 0x64, 0x3b, 0x17, 0x22, 0x21, 0x78, 0x11,
+	0xbc, 0xd8, 0x48, 0x36, 0x08, 0x5e, 0x89, 0x15, 0xc1, 0xeb, 0xf1, 0x09, 0x26, 0xc9, 0xb2, 0x8d,
+	0x34, 0x99, 0x21, 0x33, 0x2d, 0xf4, 0x79, 0x7c, 0x0e, 0xdf, 0x4d, 0xe6, 0xd0, 0xa4, 0x49, 0xf1,
+	0x2a, 0x33, 0xdf, 0xfc, 0xff, 0x5a, 0xac, 0x43, 0x00, 0x94, 0xee, 0xf4, 0x83, 0x1c, 0x84, 0x16,
+	0x64, 0x65, 0x3f, 0xf9, 0xdf, 0x00, 0xd2, 0x9f, 0x9a, 0x6b, 0xec, 0xb0, 0xd7, 0xe4, 0x25, 0x84,
+	0x6d, 0x43, 0x83, 0x2c, 0x28, 0x52, 0x16, 0xb6, 0x0d, 0x79, 0x0b, 0xa9, 0x3c, 0x56, 0x87, 0x56,
+	0xed, 0x71, 0xa0, 0xa1, 0xc5, 0x13, 0x30, 0xaf, 0x3d, 0xef, 0x50, 0x49, 0x5e, 0x23, 0x8d, 0xdc,
+	0xeb, 0x08, 0x48, 0x01, 0x71, 0x25, 0x9a, 0x33, 0x8d, 0xb3, 0xa0, 0x58, 0x97, 0xaf, 0x5d, 0xda,
+	0x87, 0x31, 0xd7, 0x56, 0x34, 0x67, 0x66, 0x15, 0x26, 0x8e, 0x6e, 0x3b, 0x54, 0x9a, 0x77, 0x92,
+	0xae, 0xb2, 0xa0, 0x88, 0xd8, 0x04, 0xcc, 0xab, 0x6a, 0x77, 0x3d, 0xd7, 0xc7, 0x01, 0x69, 0x92,
+	0x05, 0xc5, 0x73, 0x36, 0x81, 0xfc, 0x4f, 0x08, 0x2f, 0x66, 0x31, 0xc9, 0x47, 0x48, 0x54, 0xdb,
+	0xc9, 0x03, 0xda, 0x3a, 0xd6, 0xe5, 0xfd, 0x25, 0xb3, 0x85, 0xa3, 0xf6, 0xc7, 0x13, 0xe6, 0x75,
+	0xe4, 0x13, 0x3c, 0xab, 0x45, 0x27, 0xc5, 0xb1, 0x6f, 0x6c, 0x91, 0xeb, 0x92, 0x7a, 0xcf, 0x37,
+	0x8f, 0xaf, 0x5d, 0xa3, 0xd6, 0xf8, 0xb0, 0x3f, 0xe1, 0x41, 0x48, 0x57, 0xfe, 0xe4, 0xfb, 0xee,
+	0xf1, 0xcc, 0x77, 0xd1, 0x92, 0x47, 0x78, 0xca, 0x87, 0x7a, 0xdf, 0x9e, 0xd0, 0x37, 0xe7, 0x8d,
+	0xb7, 0x7d, 0x75, 0xf4, 0xda, 0x75, 0x51, 0x1a, 0xd3, 0x80, 0x7a, 0xe0, 0xb5, 0xa6, 0xab, 0x99,
+	0x89, 0x39, 0x3a, 0x33, 0x79, 0xe5, 0x36, 0x71, 0x33, 0xc8, 0x11, 0x5e, 0x2d, 0xca, 0x27, 0xf7,
+	0x90, 0x88, 0xea, 0x37, 0xd6, 0xda, 0x8f, 0xdb, 0xdf, 0x08, 0x81, 0x78, 0xc0, 0x5f, 0x8a, 0x86,
+	0x59, 0x54, 0xa4, 0xcc, 0x9e, 0x0d, 0xd3, 0x7c, 0xa7, 0x68, 0xe4, 0x98, 0x39, 0x1b, 0xd6, 0xa0,
+	0x54, 0x34, 0x76, 0xcc, 0x9c, 0xf3, 0x2f, 0x70, 0x77, 0xd3, 0x31, 0xf2, 0xc1, 0xef, 0x41, 0x90,
+	0x45, 0xff, 0x9f, 0x86, 0xdb, 0x84, 0xfc, 0x33, 0xdc, 0xdd, 0xb4, 0x8e, 0xbc, 0x9f, 0x05, 0xd8,
+	0x2c, 0x17, 0xc9, 0x5b, 0x09, 0x6c, 0x96, 0xed, 0xcb, 0x4b, 0xd8, 0x2c, 0xbb, 0x43, 0xde, 0x99,
+	0xbf, 0xc0, 0x5f, 0x94, 0x8d, 0x99, 0xb2, 0x2b, 0x52, 0x25, 0x36, 0xfc, 0xe3, 0xbf, 0x01, 0x00,
+	0x6a, 0x21, 0x3c, 0x64, 0x2c, 0x03, 0x00, 0x00,
 }
@@ -15,7 +15,7 @@ echo "Installing x/crypto deps"
 go get golang.org/x/crypto/scrypt golang.org/x/crypto/nacl/secretbox || die
 
 echo "Installing unvendored deps"
-go get github.com/gorilla/mux github.com/mattn/go-sqlite3 github.com/mitchellh/go-homedir github.com/howeyc/gopass gopkg.in/alecthomas/kingpin.v2 || die
+go get github.com/gorilla/mux github.com/mattn/go-sqlite3 github.com/boltdb/bolt github.com/mitchellh/go-homedir github.com/howeyc/gopass gopkg.in/alecthomas/kingpin.v2 || die
 
 echo "Installing gorocksdb; this can take a while!"
 go get -tags=embed github.com/mediachain/gorocksdb || die